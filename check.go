@@ -0,0 +1,232 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// Schema declares the types of params, e.g. age: number, region: list<number>
+type Schema map[string]function.Type
+
+// ParseSchema parses the schema declared in the form of `name: type`, separated by comma or newline
+func ParseSchema(s string) (Schema, error) {
+	schema := make(Schema)
+	depth, start := 0, 0
+	fields := make([]string, 0)
+	for i, r := range s {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',', '\n':
+			if depth == 0 {
+				fields = append(fields, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	fields = append(fields, s[start:])
+	for _, field := range fields {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		kv := strings.SplitN(field, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("schema: illegal field %q", strings.TrimSpace(field))
+		}
+		name := strings.TrimSpace(kv[0])
+		t, err := function.ParseType(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s: %v", name, err)
+		}
+		schema[name] = t
+	}
+	return schema, nil
+}
+
+// CheckError is the error found by static type checking
+type CheckError struct {
+	Pos Position
+	Msg string
+}
+
+func (e CheckError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// CheckErrors collects all the CheckError found
+type CheckErrors []CheckError
+
+func (es CheckErrors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// Check does static type checking of the Expression against the schema of params.
+// Type mismatches, unknown variables and arity errors are all reported as CheckErrors.
+// Params of functions without Signature are not checked, neither is the result of them
+func (e Expression) Check(schema Schema) error {
+	c := checker{e: e, schema: schema}
+	c.typeOf(e.exp)
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs
+}
+
+type checker struct {
+	e      Expression
+	schema Schema
+	errs   CheckErrors
+}
+
+func (c *checker) errorf(pos int, format string, a ...interface{}) {
+	c.errs = append(c.errs, CheckError{Pos: c.e.position(pos), Msg: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) typeOf(exp sexp) function.Type {
+	switch v := exp.i.(type) {
+	case folded:
+		return c.typeOf(v.src)
+	case float64:
+		return function.NumberType
+	case string:
+		return function.StringType
+	case bool:
+		return function.BoolType
+	case varString:
+		name := string(v)
+		t, ok := c.schema[name]
+		if _, err := function.Get(name); err == nil && !ok {
+			return function.FuncType
+		}
+		if !ok {
+			c.errorf(exp.pos, "unknown variable %q", name)
+			return function.AnyType
+		}
+		return t
+	case list:
+		if len(v) > 0 {
+			if head, ok := v[0].i.(varString); ok {
+				if _, err := function.Get(string(head)); err == nil {
+					return c.typeOfCall(string(head), exp.pos, v[1:])
+				}
+			}
+		}
+		var elem function.Type
+		for _, e := range v {
+			t := c.typeOf(e)
+			if elem == "" {
+				elem = t
+			} else if elem != t {
+				elem = function.AnyType
+			}
+		}
+		if elem == "" {
+			elem = function.AnyType
+		}
+		return function.ListOf(elem)
+	}
+	return function.AnyType
+}
+
+func (c *checker) typeOfCall(name string, pos int, args list) function.Type {
+	spec, _ := function.Describe(name)
+	if err := spec.CheckArity(len(args)); err != nil {
+		c.errorf(pos, "%v", err)
+	}
+	if !spec.HasSignature() {
+		for _, arg := range args {
+			c.typeOf(arg)
+		}
+		return function.AnyType
+	}
+
+	sig := spec.Signature
+	bindings := make(map[function.Type]function.Type)
+	broadcast := len(args) > len(sig.Params)
+	for i, arg := range args {
+		actual := c.typeOf(arg)
+		if i >= len(sig.Params) && !sig.Variadic {
+			continue
+		}
+		expected := sig.Params[len(sig.Params)-1]
+		if i < len(sig.Params) {
+			expected = sig.Params[i]
+		}
+		if _, ok := expected.Elem(); sig.Broadcast && !ok {
+			for elem, ok := actual.Elem(); ok; elem, ok = actual.Elem() {
+				actual, broadcast = elem, true
+			}
+		}
+		if !match(expected, actual, bindings) {
+			c.errorf(arg.pos, "%s: param %d expects %s, but got %s", name, i+1, describe(bind(expected, bindings)), actual)
+		}
+	}
+	res := concrete(bind(sig.Return, bindings))
+	if sig.Broadcast && broadcast {
+		return function.ListOf(res)
+	}
+	return res
+}
+
+// match returns whether actual type matches the expected, the generic types are bound during matching
+func match(expected, actual function.Type, bindings map[function.Type]function.Type) bool {
+	if expected == function.AnyType || actual == function.AnyType {
+		return true
+	}
+	if expected == function.GenericType || expected == function.OrderedType {
+		if bound, ok := bindings[expected]; ok {
+			return match(bound, actual, bindings)
+		}
+		if expected == function.OrderedType && !actual.Ordered() {
+			return false
+		}
+		bindings[expected] = actual
+		return true
+	}
+	if e, ok := expected.Elem(); ok {
+		a, ok := actual.Elem()
+		return ok && match(e, a, bindings)
+	}
+	return expected == actual
+}
+
+// bind replaces the generic types with the bound ones
+func bind(t function.Type, bindings map[function.Type]function.Type) function.Type {
+	if elem, ok := t.Elem(); ok {
+		return function.ListOf(bind(elem, bindings))
+	}
+	if bound, ok := bindings[t]; ok {
+		return bound
+	}
+	return t
+}
+
+// concrete replaces the unbound generic types with AnyType
+func concrete(t function.Type) function.Type {
+	if elem, ok := t.Elem(); ok {
+		return function.ListOf(concrete(elem))
+	}
+	if t == function.GenericType || t == function.OrderedType {
+		return function.AnyType
+	}
+	return t
+}
+
+// describe returns the readable name of type t within error messages
+func describe(t function.Type) string {
+	switch t {
+	case function.OrderedType:
+		return "ordered type"
+	case function.GenericType:
+		return string(function.AnyType)
+	}
+	return string(t)
+}
@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/nullne/evaluator/function"
+)
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema(`age: number, gender: string, app_version: version, region: list<number>
+		flags: list<list<boolean>>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schema{
+		"age":         function.NumberType,
+		"gender":      function.StringType,
+		"app_version": function.VersionType,
+		"region":      function.ListOf(function.NumberType),
+		"flags":       function.ListOf(function.ListOf(function.BoolType)),
+	}
+	if len(schema) != len(want) {
+		t.Errorf("wanna: %v, got: %v", want, schema)
+	}
+	for k, v := range want {
+		if schema[k] != v {
+			t.Errorf("%s wanna: %v, got: %v", k, v, schema[k])
+		}
+	}
+
+	for _, s := range []string{`age number`, `age: integer`, `age: list<int>`} {
+		if _, err := ParseSchema(s); err == nil {
+			t.Errorf("schema `%s` should have errors", s)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	schema, err := ParseSchema(`years: number, gender: string, app_version: version, region: list<number>, now: string`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type input struct {
+		expr string
+		errs []string
+	}
+	inputs := []input{
+		{`(or
+	(and
+	(between years 18 80)
+	(eq gender "male")
+	(between app_version (t_version "2.7.1") (t_version "2.9.1"))
+	)
+	(overlap region (2890 3780))
+ )`, nil},
+		{`(in gender ("female" "male"))`, nil},
+		{`(in gender ())`, nil},
+		{`(eq (mod years 5) 3.0)`, nil},
+		{`(in (t_version "1.0") (t_version ("1.0" "2.0")))`, nil},
+		{`(between (td_time now) (td_time "2017-01-02 12:00:00") (td_time "2017-12-02 12:00:00"))`, nil},
+		{`(eq region (1 2))`, nil},
+		{`(eq (+ 1 years) 15)`, nil},
+		{`(eq (get {"CN" 1.2} gender 1.0) 1.2)`, nil},
+		{`(in years (union (set (1 2)) region))`, nil},
+
+		{`(eq years "18")`, []string{`1:11: eq: param 2 expects number, but got string`}},
+		{`(and
+  (between app_version 1 2)
+  (in gender region))`, []string{
+			`2:24: between: param 2 expects version, but got number`,
+			`2:26: between: param 3 expects version, but got number`,
+			`3:14: in: param 2 expects list<string>, but got list<number>`,
+		}},
+		{`(between years 1)`, []string{`1:1: between: need 3 params, but got 2`}},
+		{`(and (gt city 1) true_or_false)`, []string{`1:10: unknown variable "city"`, `1:18: unknown variable "true_or_false"`}},
+		{`(not (+ 1 2))`, []string{`1:6: not: param 1 expects bool, but got number`}},
+		{`(has_key region "a")`, []string{`1:10: has_key: param 1 expects map, but got list<number>`}},
+		{`(gt (eq years 1) (eq years 2))`, []string{`1:5: gt: param 1 expects ordered type, but got bool`, `1:18: gt: param 2 expects ordered type, but got bool`}},
+		{`(and (eq gender 1))`, []string{`1:1: and: need at least 2 params, but got 1`, `1:17: eq: param 2 expects string, but got number`}},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = e.Check(schema)
+		if input.errs == nil {
+			if err != nil {
+				t.Errorf("expression `%s` shoud not have error but got %s", input.expr, err.Error())
+			}
+			continue
+		}
+		errs, ok := err.(CheckErrors)
+		if !ok {
+			t.Errorf("expression `%s` shoud have CheckErrors but got %v", input.expr, err)
+			continue
+		}
+		if len(errs) != len(input.errs) {
+			t.Errorf("expression `%s` wanna: %v, got: %v", input.expr, input.errs, errs)
+			continue
+		}
+		for i, e := range errs {
+			if e.Error() != input.errs[i] {
+				t.Errorf("expression `%s` wanna: %s, got: %s", input.expr, input.errs[i], e.Error())
+			}
+		}
+	}
+}
+
+func TestCheckInfix(t *testing.T) {
+	e, err := NewInfix(`gender = "male" and
+years between "18" and 80`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Check(Schema{"gender": function.StringType, "years": function.NumberType})
+	if err == nil || err.Error() != `2:15: between: param 2 expects number, but got string` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package evaluator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// HitPolicy decides the result of DecisionTable when multiple rows match
+type HitPolicy string
+
+const (
+	// HitFirst returns the output of the first row matched
+	HitFirst HitPolicy = "first"
+	// HitUnique returns the output of the only row matched, ErrNotUnique is returned if multiple rows match
+	HitUnique HitPolicy = "unique"
+	// HitCollect returns the outputs of all the rows matched
+	HitCollect HitPolicy = "collect"
+	// HitPriority returns the output of the row matched with the highest priority
+	HitPriority HitPolicy = "priority"
+)
+
+var (
+	// ErrNotUnique means multiple rows match under the hit policy unique
+	ErrNotUnique = errors.New("multiple rows matched")
+	// ErrUnknownHitPolicy means the hit policy is not supported
+	ErrUnknownHitPolicy = errors.New("unknown hit policy")
+)
+
+// placeholder stands for the input within the cells of DecisionTable
+const placeholder = "_"
+
+// DecisionInput is the input column of DecisionTable
+type DecisionInput struct {
+	Name string
+	// Domain optionally restricts the valid values of the input in the form of cell, e.g. (in _ ("gold" "silver")).
+	// It's only used by Validate to rule out the impossible inputs
+	Domain string
+}
+
+// DecisionRow is a row of DecisionTable
+type DecisionRow struct {
+	// Conditions are the cells of the inputs, such as (between _ 18 30) where _ stands for the input.
+	// A cell not starting with parenthesis is compared by eq, e.g. 18 is the same as (eq _ 18) and a bare word US is the same as (eq _ "US").
+	// "-" or empty cell matches anything
+	Conditions []string
+	Output     interface{}
+	// Priority is used by the hit policy priority, the higher the earlier
+	Priority int
+}
+
+// DecisionTable maps the conditions on the inputs onto the output row by row
+type DecisionTable struct {
+	Inputs []DecisionInput
+	Output string
+	Policy HitPolicy
+	Rows   []DecisionRow
+}
+
+// ReadDecisionTable reads the DecisionTable from CSV. The header names the inputs followed by the output,
+// and a trailing column of priority is required by the hit policy priority.
+// Outputs of number are converted to float64, and the others are kept as string with the quotes stripped
+func ReadDecisionTable(r io.Reader, policy HitPolicy) (*DecisionTable, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("decision: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("decision: header is missing")
+	}
+	header := records[0]
+	inputs := len(header) - 1
+	if policy == HitPriority {
+		inputs--
+	}
+	if inputs < 1 {
+		return nil, fmt.Errorf("decision: need at least one input and the output, but got %d columns", len(header))
+	}
+	t := &DecisionTable{Output: strings.TrimSpace(header[inputs]), Policy: policy}
+	for _, name := range header[:inputs] {
+		t.Inputs = append(t.Inputs, DecisionInput{Name: strings.TrimSpace(name)})
+	}
+	for i, record := range records[1:] {
+		row := DecisionRow{Conditions: record[:inputs], Output: output(strings.TrimSpace(record[inputs]))}
+		if policy == HitPriority {
+			if _, err := fmt.Sscan(record[inputs+1], &row.Priority); err != nil {
+				return nil, fmt.Errorf("decision: row %d: illegal priority %q", i+1, record[inputs+1])
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// output returns the number or string of literal, or the text itself
+func output(text string) interface{} {
+	exp, err := parse(text)
+	if err != nil {
+		return text
+	}
+	switch v := exp.i.(type) {
+	case float64, string:
+		return v
+	}
+	return text
+}
+
+// CompiledTable is the DecisionTable compiled into Expressions
+type CompiledTable struct {
+	Table *DecisionTable
+	// Expressions are the conditions of rows
+	Expressions []Expression
+	domains     []sexp
+}
+
+// Compile compiles every row of the table into Expression, in which the placeholder _ is replaced by the input
+func (t *DecisionTable) Compile() (*CompiledTable, error) {
+	switch t.Policy {
+	case HitFirst, HitUnique, HitCollect, HitPriority:
+	default:
+		return nil, fmt.Errorf("decision: %w %q", ErrUnknownHitPolicy, t.Policy)
+	}
+	c := &CompiledTable{Table: t}
+	for _, input := range t.Inputs {
+		domain, err := compileCell(input.Domain, input.Name)
+		if err != nil {
+			return nil, fmt.Errorf("decision: domain of %q: %v", input.Name, err)
+		}
+		if domain != nil {
+			c.domains = append(c.domains, *domain)
+		}
+	}
+	for i, row := range t.Rows {
+		if len(row.Conditions) != len(t.Inputs) {
+			return nil, fmt.Errorf("decision: row %d: need %d conditions, but got %d", i+1, len(t.Inputs), len(row.Conditions))
+		}
+		var conditions []string
+		for j, cell := range row.Conditions {
+			exp, err := compileCell(cell, t.Inputs[j].Name)
+			if err != nil {
+				return nil, fmt.Errorf("decision: row %d, column %q: %v", i+1, t.Inputs[j].Name, err)
+			}
+			if exp != nil {
+				conditions = append(conditions, exp.source())
+			}
+		}
+		var e Expression
+		var err error
+		switch len(conditions) {
+		case 0:
+			e = Expression{exp: sexp{i: true}, src: "true"}
+		case 1:
+			e, err = New(conditions[0])
+		default:
+			e, err = New("(and " + strings.Join(conditions, " ") + ")")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decision: row %d: %v", i+1, err)
+		}
+		c.Expressions = append(c.Expressions, e)
+	}
+	return c, nil
+}
+
+// compileCell returns the condition of cell on the input, nil means matching anything
+func compileCell(cell, input string) (*sexp, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" || cell == "-" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(cell, "(") {
+		v, err := parse(cell)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := v.i.(varString); ok {
+			v = sexp{i: string(s), pos: v.pos}
+		}
+		exp := call(0, function.FuncEqual, sexp{i: varString(input)}, v)
+		return &exp, nil
+	}
+	exp, err := parse(cell)
+	if err != nil {
+		return nil, err
+	}
+	exp = exp.replace(placeholder, input)
+	return &exp, nil
+}
+
+// replace replaces the variable from with to
+func (exp sexp) replace(from, to string) sexp {
+	switch v := exp.i.(type) {
+	case varString:
+		if string(v) == from {
+			return sexp{i: varString(to), pos: exp.pos}
+		}
+	case list:
+		l := make(list, len(v))
+		for i, e := range v {
+			l[i] = e.replace(from, to)
+		}
+		return sexp{i: l, pos: exp.pos}
+	}
+	return exp
+}
+
+// Match returns the rows matched under the hit policy, starting at 0
+func (c *CompiledTable) Match(params Params) ([]int, error) {
+	memo := newMemoParams(params)
+	var rows []int
+	for i, e := range c.Expressions {
+		ok, err := e.EvalBool(memo)
+		if err != nil {
+			return nil, fmt.Errorf("decision: row %d: %w", i+1, err)
+		}
+		if !ok {
+			continue
+		}
+		rows = append(rows, i)
+		if c.Table.Policy == HitFirst {
+			break
+		}
+	}
+	switch c.Table.Policy {
+	case HitUnique:
+		if len(rows) > 1 {
+			return nil, fmt.Errorf("decision: %w: %s", ErrNotUnique, rowNumbers(rows))
+		}
+	case HitPriority:
+		if len(rows) > 1 {
+			sort.SliceStable(rows, func(i, j int) bool {
+				return c.Table.Rows[rows[i]].Priority > c.Table.Rows[rows[j]].Priority
+			})
+			rows = rows[:1]
+		}
+	}
+	return rows, nil
+}
+
+// Decide returns the outputs of rows matched under the hit policy
+func (c *CompiledTable) Decide(params Params) ([]interface{}, error) {
+	rows, err := c.Match(params)
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]interface{}, len(rows))
+	for i, row := range rows {
+		outputs[i] = c.Table.Rows[row].Output
+	}
+	return outputs, nil
+}
+
+// TableIssue is the problem found by validating DecisionTable
+type TableIssue struct {
+	// Rows are the overlapping rows starting at 0, empty means no row matches the Witness
+	Rows []int
+	// Witness is the params showing the issue
+	Witness MapParams
+}
+
+func (i TableIssue) String() string {
+	if len(i.Rows) == 0 {
+		return fmt.Sprintf("no row matches %v", i.Witness)
+	}
+	return fmt.Sprintf("%s overlap on %v", rowNumbers(i.Rows), i.Witness)
+}
+
+// rowNumbers returns the readable row numbers
+func rowNumbers(rows []int) string {
+	ss := make([]string, len(rows))
+	for i, row := range rows {
+		ss[i] = fmt.Sprint(row + 1)
+	}
+	return "rows " + strings.Join(ss, ", ")
+}
+
+// Validate finds the pairs of overlapping rows and the inputs matching no row, within the domains of inputs.
+// Overlapping rows are expected by the hit policies other than unique, so the issues are up to the caller.
+// ErrNotAnalyzable is returned if the conditions are beyond the analysis of IsSatisfiable
+func (c *CompiledTable) Validate() ([]TableIssue, error) {
+	var issues []TableIssue
+	for i := range c.Expressions {
+		for j := i + 1; j < len(c.Expressions); j++ {
+			conditions := append(append(list(nil), c.domains...), c.Expressions[i].exp, c.Expressions[j].exp)
+			witness, ok, err := satisfy(call(0, function.FuncAnd, conditions...))
+			if err != nil {
+				return nil, fmt.Errorf("decision: %w", err)
+			}
+			if ok {
+				issues = append(issues, TableIssue{Rows: []int{i, j}, Witness: witness})
+			}
+		}
+	}
+	rows := make(list, len(c.Expressions))
+	for i, e := range c.Expressions {
+		rows[i] = e.exp
+	}
+	conditions := append(append(list(nil), c.domains...), call(0, function.FuncNot, call(0, function.FuncOr, rows...)))
+	witness, ok, err := satisfy(call(0, function.FuncAnd, conditions...))
+	if err != nil {
+		return nil, fmt.Errorf("decision: %w", err)
+	}
+	if ok {
+		issues = append(issues, TableIssue{Witness: witness})
+	}
+	return issues, nil
+}
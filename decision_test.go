@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readDecisionTable(t *testing.T, policy HitPolicy) *CompiledTable {
+	f, err := os.Open("testdata/pricing.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	table, err := ReadDecisionTable(f, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := table.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDecisionTable(t *testing.T) {
+	c := readDecisionTable(t, HitCollect)
+	wanna := []string{
+		`(and (eq country "US") (eq tier "gold"))`,
+		`(and (eq country "US") (eq tier "silver") (between years 18 30))`,
+		`(and (in country ("CA" "MX")) (lt years 18))`,
+		`(and (ne tier "gold") (gt years 60))`,
+	}
+	for i, e := range c.Expressions {
+		if e.String() != wanna[i] {
+			t.Errorf("row %d wanna: %s, got: %s", i+1, wanna[i], e.String())
+		}
+	}
+	if c.Table.Output != "discount" || len(c.Table.Inputs) != 3 || c.Table.Inputs[2].Name != "years" {
+		t.Errorf("wrong header: %+v", c.Table)
+	}
+
+	type input struct {
+		params  MapParams
+		outputs []interface{}
+	}
+	inputs := []input{
+		{MapParams{"country": "US", "tier": "gold", "years": 70}, []interface{}{0.2}},
+		{MapParams{"country": "US", "tier": "silver", "years": 20}, []interface{}{0.1}},
+		{MapParams{"country": "US", "tier": "silver", "years": 70}, []interface{}{0.05}},
+		{MapParams{"country": "MX", "tier": "silver", "years": 10}, []interface{}{0.15}},
+		{MapParams{"country": "CN", "tier": "silver", "years": 20}, []interface{}{}},
+	}
+	for _, input := range inputs {
+		outputs, err := c.Decide(input.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(outputs, input.outputs) {
+			t.Errorf("params %v wanna: %v, got: %v", input.params, input.outputs, outputs)
+		}
+	}
+}
+
+func TestDecisionTableHitPolicy(t *testing.T) {
+	table := &DecisionTable{
+		Inputs: []DecisionInput{{Name: "years"}},
+		Output: "group",
+		Rows: []DecisionRow{
+			{Conditions: []string{"(lt _ 18)"}, Output: "minor", Priority: 1},
+			{Conditions: []string{"(lt _ 60)"}, Output: "adult", Priority: 2},
+			{Conditions: []string{"-"}, Output: "anyone"},
+		},
+	}
+	type input struct {
+		policy  HitPolicy
+		years   int
+		outputs []interface{}
+		err     error
+	}
+	inputs := []input{
+		{HitFirst, 10, []interface{}{"minor"}, nil},
+		{HitFirst, 70, []interface{}{"anyone"}, nil},
+		{HitCollect, 10, []interface{}{"minor", "adult", "anyone"}, nil},
+		{HitPriority, 10, []interface{}{"adult"}, nil},
+		{HitPriority, 70, []interface{}{"anyone"}, nil},
+		{HitUnique, 10, nil, ErrNotUnique},
+	}
+	for _, input := range inputs {
+		table.Policy = input.policy
+		c, err := table.Compile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := c.Decide(MapParams{"years": input.years})
+		if !errors.Is(err, input.err) {
+			t.Errorf("policy %s wanna: %v, got: %v", input.policy, input.err, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(outputs, input.outputs) {
+			t.Errorf("policy %s with %d wanna: %v, got: %v", input.policy, input.years, input.outputs, outputs)
+		}
+	}
+
+	table.Policy = "any"
+	if _, err := table.Compile(); !errors.Is(err, ErrUnknownHitPolicy) {
+		t.Errorf("wanna: %v, got: %v", ErrUnknownHitPolicy, err)
+	}
+	table.Policy = HitFirst
+	table.Rows[1].Conditions[0] = "(lt _ 60"
+	if _, err := table.Compile(); err == nil || !strings.Contains(err.Error(), `row 2, column "years"`) {
+		t.Errorf("wanna error at row 2, got: %v", err)
+	}
+}
+
+func TestDecisionTableValidate(t *testing.T) {
+	table := &DecisionTable{
+		Inputs: []DecisionInput{{Name: "tier", Domain: `(in _ ("gold" "silver"))`}, {Name: "years"}},
+		Output: "discount",
+		Policy: HitUnique,
+		Rows: []DecisionRow{
+			{Conditions: []string{"gold", "(ge _ 18)"}, Output: 0.2},
+			{Conditions: []string{"silver", "(between _ 18 30)"}, Output: 0.1},
+			{Conditions: []string{"-", "(lt _ 18)"}, Output: 0.0},
+			{Conditions: []string{"silver", "(ge _ 30)"}, Output: 0.05},
+		},
+	}
+	c, err := table.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := c.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || !reflect.DeepEqual(issues[0].Rows, []int{1, 3}) {
+		t.Fatalf("wanna rows 2, 4 overlap, got: %v", issues)
+	}
+	if r, _ := c.Expressions[1].EvalBool(issues[0].Witness); !r {
+		t.Errorf("wrong witness: %v", issues[0].Witness)
+	}
+
+	table.Rows[3].Conditions[1] = "(gt _ 30)"
+	table.Rows[0].Conditions[1] = "(gt _ 18)"
+	c, err = table.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err = c.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || len(issues[0].Rows) != 0 || fmt.Sprint(issues[0]) != "no row matches map[tier:gold years:18]" {
+		t.Errorf("wanna gold of 18 matches no row, got: %v", issues)
+	}
+}
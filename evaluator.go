@@ -1,7 +1,11 @@
 // Package evaluator evaluates an expression in the form of s-expression
 package evaluator
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	// ErrNotFound means the unknow string within the expression cannot be Get from neither functions or params
@@ -13,16 +17,44 @@ var (
 // Expression stands for an expression which can be evaluated by passing required params
 type Expression struct {
 	exp sexp
+	// src is the source which exp is parsed from
+	src string
+}
+
+// SyntaxError is the error of parsing along with the position where it occurs
+type SyntaxError struct {
+	Pos Position
+	Err error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at %s", e.Err, e.Pos)
 }
 
-// New will return a Expression by parsing the given expression string
+// Unwrap returns the underlying error, e.g. ErrUnmatchedParenthesis
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// syntaxError returns SyntaxError at offset within src
+func syntaxError(src string, offset int, err error) error {
+	return &SyntaxError{Pos: Expression{src: src}.position(offset), Err: err}
+}
+
+// New will return a Expression by parsing the given expression string.
+// The error of parsing is *SyntaxError, including the constant params failed to be prepared, e.g. illegal regular expressions
 func New(expr string) (Expression, error) {
-	exp, err := parse(expr)
+	exp, offset, err := parseAt(expr)
+	if err != nil {
+		return Expression{}, syntaxError(expr, offset, err)
+	}
+	exp, offset, err = exp.compile()
 	if err != nil {
-		return Expression{}, err
+		return Expression{}, syntaxError(expr, offset, err)
 	}
 	return Expression{
 		exp: exp,
+		src: expr,
 	}, nil
 }
 
@@ -44,6 +76,11 @@ func (e Expression) EvalBool(params Params) (bool, error) {
 	return b, nil
 }
 
+// String returns the Expression in the form of s-expression
+func (e Expression) String() string {
+	return e.exp.source()
+}
+
 // Properties returns the field names in an Expression.
 // e.g. Expression constructed by `(or (and (between age 18 80) (eq gender "male") )`
 // returns "age", "gender" by calling Properties.
@@ -51,6 +88,31 @@ func (e Expression) Properties() []string {
 	return e.exp.properties()
 }
 
+// Position describes a location within the source of an Expression
+type Position struct {
+	// Offset is the byte offset, starting at 0
+	Offset int `json:"offset"`
+	// Line is the line number, starting at 1
+	Line int `json:"line"`
+	// Column is the byte count within the line, starting at 1
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// position converts the byte offset within source to Position
+func (e Expression) position(offset int) Position {
+	if offset > len(e.src) {
+		offset = len(e.src)
+	}
+	before := e.src[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return Position{Offset: offset, Line: line, Column: column}
+}
+
 // MapParams is a simple map implementation of Params interface
 type MapParams map[string]interface{}
 
@@ -66,6 +66,62 @@ func TestBasicIncorrect(t *testing.T) {
 	}
 }
 
+func TestSyntaxError(t *testing.T) {
+	type input struct {
+		expr  string
+		infix bool
+		err   error
+		pos   string
+	}
+	inputs := []input{
+		{expr: "(and\n  (eq a 1))\n  (eq b 2)", err: ErrLeftOverText, pos: "3:3"},
+		{expr: "(and\n  (eq a \"1))", err: ErrUnexpectedEnd, pos: "2:9"},
+		{expr: "(eq a 1))", err: ErrUnmatchedParenthesis, pos: "1:9"},
+		{expr: "(or (eq a 1)", err: ErrLeftOverText, pos: "1:1"},
+		{expr: "a = 1 and\n  b >", infix: true, err: ErrUnexpectedEnd, pos: "2:6"},
+		{expr: "a = 1 b", infix: true, err: ErrLeftOverText, pos: "1:7"},
+		{expr: "(time_sub (now)\n  \"7 days\")", err: function.ErrIllegalFormat, pos: "2:3"},
+		{expr: "(cron_match (now) \"*/15 9-17 * *\")", err: function.ErrIllegalFormat, pos: "1:19"},
+		{expr: "(in_cidr ip (\"10.0.0.0/8\" \"10.0.0.0/33\"))", err: function.ErrIllegalFormat, pos: "1:13"},
+		{expr: "(in_polygon lat lng \"{\\\"type\\\": \\\"Point\\\"}\")", err: function.ErrIllegalFormat, pos: "1:21"},
+		{expr: `(get {"CN" 1.2 "US"} country)`, err: ErrUnpairedKey, pos: "1:16"},
+		{expr: `(get {"CN" 1.2 1 1.0} country)`, err: function.ErrParamsInvalid, pos: "1:16"},
+	}
+	for _, input := range inputs {
+		var err error
+		if input.infix {
+			_, err = NewInfix(input.expr)
+		} else {
+			_, err = New(input.expr)
+		}
+		var se *SyntaxError
+		if !errors.As(err, &se) || !errors.Is(err, input.err) || se.Pos.String() != input.pos {
+			t.Errorf("expression `%s` wanna: %v at %s, got: %v", input.expr, input.err, input.pos, err)
+		}
+	}
+}
+
+func TestNow(t *testing.T) {
+	signup := time.Date(2017, 9, 1, 12, 0, 0, 0, time.UTC)
+	defer func(clock func() time.Time) { function.Clock = clock }(function.Clock)
+	e, err := New(`(le (time_diff (now) signup) (t_duration "7d"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []struct {
+		now time.Time
+		res bool
+	}{
+		{signup.Add(3 * 24 * time.Hour), true},
+		{signup.Add(8 * 24 * time.Hour), false},
+	} {
+		function.Clock = func() time.Time { return input.now }
+		if r, err := e.Eval(MapParams{"signup": signup}); err != nil || r != input.res {
+			t.Errorf("now %v wanna: %v, got: %v, %v", input.now, input.res, r, err)
+		}
+	}
+}
+
 func TestComplicated(t *testing.T) {
 	appVersion, err := function.TypeVersion{}.Eval("2.7.1")
 	if err != nil {
@@ -151,14 +207,42 @@ func TestCorrectFuncs(t *testing.T) {
 		res  interface{}
 	}
 	vvf := MapParams{
-		"gender": "male",
-		"age":    18,
-		"price":  16.7,
+		"gender":  "male",
+		"age":     18,
+		"price":   16.7,
+		"ua":      "Mozilla/5.0 (iPhone; CPU iPhone OS 14_0)",
+		"labels":  map[string]string{"env": "prod"},
+		"buckets": 4294967296,
 	}
 	inputs := []input{
 		{`(eq (mod age 5) 3.0)`, true},
 		{`(eq (+ 10 5) 15)`, true},
 		{`(eq (/ 10 5) 2)`, true},
+		{`(contains ua "iPhone")`, true},
+		{`(eq (lower (substr ua 0 7)) "mozilla")`, true},
+		{`(in "male" (split (concat gender "," "female") ","))`, true},
+		{`(eq (len (split "a,b" ",")) 2)`, true},
+		{`(and (ilike ua "%iphone%") (glob ua "Mozilla/*") (not (like gender "fe%")))`, true},
+		{`(eq (round (* price 1.1) 1) 18.4)`, true},
+		{`(eq (max (1 5 3) age) 18)`, true},
+		{`(between (t_semver "v2.7.1-beta.2") (t_semver "2.7.0") (t_semver "2.7.1"))`, true},
+		{`(in (t_semver "3.0.0+build.7") (t_semver ("2.7.1" "3.0.0")))`, true},
+		{`(semver_satisfies "2.9.1" "^2.7 || >=3.1.0 <4")`, true},
+		{`(eq (hour (in_zone (t_rfc3339 "2017-09-10T20:30:00Z") "Asia/Shanghai")) 4)`, true},
+		{`(gt (time_diff (t_unix 1505075400) (td_time "2017-09-10 00:00:00")) (t_duration "20h"))`, true},
+		{`(in_window (t_rfc3339 "2017-09-18T00:30:00Z") "Mon-Fri 09:00-18:00" "Asia/Tokyo")`, true},
+		{`(cron_match (t_rfc3339 "2017-09-18T00:30:00Z") "*/15 9-17 * * 1-5" "Asia/Tokyo")`, true},
+		{`(and (in_cidr "192.168.3.4" ("10.0.0.0/8" "192.168.0.0/16")) (is_private "10.1.1.1") (eq (ip_version "::1") 6))`, true},
+		{`(and (in (t_ip "::1") (t_ip ("10.0.0.1" "::1"))) (not (in (t_ip "::ffff:10.0.0.1") (t_ip ("10.0.0.1" "::1")))))`, true},
+		{`(and (within_km 31.3 121.5 31.23 121.47 50) (geohash_prefix 31.2304 121.4737 "wtw3"))`, true},
+		{`(eq (get {"CN" 1.2 "US" 1.0} (upper "cn") 1.0) 1.2)`, true},
+		{`(eq {"a" (1 2) "b" {"c" age}} {"b" {"c" 18} "a" (1.0 2)})`, true},
+		{`(and (has_key (merge {"a" 1} labels) "env") (eq (keys {"b" 1 "a" 2}) ("a" "b")))`, true},
+		{`(and (eq (get labels "env") "prod") (eq labels {"env" "prod"}) (in labels ({"env" "dev"} {"env" "prod"})))`, true},
+		{`(and (in 1 (set (1 2))) (subset (intersect (1 2 3) (set (2 3 4))) (2 3)) (eq (len (union (1 2) (2 3))) 3))`, true},
+		{`(eq (difference (set ("a" "b" "c")) ("b")) ("c" "a"))`, true},
+		{`(eq (bucket "u1" "s" 4294967296) (bucket "u1" "s" buckets) (hash "u1" "s"))`, true},
+		{`(in_polygon 31.5 121.5 "{\"type\": \"Polygon\", \"coordinates\": [[[121, 31], [122, 31], [122, 32], [121, 31]]]}")`, true},
 	}
 	for _, input := range inputs {
 		e, err := New(input.expr)
@@ -238,6 +322,30 @@ func TestAdvancedFunc(t *testing.T) {
 	if r.(float64) != 2 {
 		t.Errorf("expression `%s` wanna: %+v, got: %+v", exp, 2, r)
 	}
+
+	// the symbol passed to function is read from params first, and it's the function if params are nil or miss it
+	multiply, err := function.Get("*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		params Params
+		res    float64
+	}{
+		{nil, 2},
+		{MapParams{}, 2},
+		{MapParams{"+": multiply}, 1},
+	}
+	for _, input := range inputs {
+		r, err := e.Eval(input.params)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if r != input.res {
+			t.Errorf("expression `%s` with params %v wanna: %+v, got: %+v", exp, input.params, input.res, r)
+		}
+	}
 }
 
 func TestDIVFunc(t *testing.T) {
@@ -311,6 +419,10 @@ func TestProperties(t *testing.T) {
 	)`,
 			res: []string{"os", "app_version", "os", "affiliate", "os", "language"},
 		},
+		{
+			expr: `(and (eq day 3) (eq country "US"))`,
+			res:  []string{"day", "country"},
+		},
 		{
 			expr: `(eq 1 1)`,
 			res:  nil,
@@ -0,0 +1,207 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// Trace is the evaluated result of a node within Expression, it mirrors the tree of Expression
+type Trace struct {
+	// Expr is the source of the node
+	Expr string   `json:"expr"`
+	Pos  Position `json:"pos"`
+	// Func is the function called by the node
+	Func string `json:"func,omitempty"`
+	// Param is the name of param read by the node
+	Param string      `json:"param,omitempty"`
+	Value interface{} `json:"value"`
+	Err   error       `json:"-"`
+	// Decisive marks the clauses which decide the final result
+	Decisive bool `json:"decisive,omitempty"`
+	// Params collects all the params read, only set on the root
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Children []*Trace               `json:"children,omitempty"`
+}
+
+// Explain evaluates the Expression with params and returns the trace of every node,
+// the clauses which decide the final boolean are marked as Decisive
+func (e Expression) Explain(params Params) *Trace {
+	x := explainer{e: e, ps: params, read: make(map[string]interface{})}
+	t, _ := x.explain(e.exp, false)
+	if len(x.read) > 0 {
+		t.Params = x.read
+	}
+	t.decide()
+	return t
+}
+
+type explainer struct {
+	e    Expression
+	ps   Params
+	read map[string]interface{}
+}
+
+// explain returns the trace of exp and the value used to evaluate the parent, arg tells whether exp is the argument of function
+func (x *explainer) explain(exp sexp, arg bool) (*Trace, interface{}) {
+	t := &Trace{Expr: exp.source(), Pos: x.e.position(exp.pos)}
+	switch v := exp.i.(type) {
+	case folded:
+		t.Value = exp.value()
+		return t, v.v
+	case varString:
+		name := string(v)
+		var p interface{}
+		err := ErrNotFound
+		if x.ps != nil && arg {
+			p, err = x.ps.Get(name)
+		}
+		if err != nil {
+			if fn, e := function.Get(name); e == nil {
+				return t, fn
+			}
+			if x.ps != nil && !arg {
+				p, err = x.ps.Get(name)
+			}
+		}
+		t.Param = name
+		if err != nil {
+			t.Err = err
+			return t, nil
+		}
+		x.read[name] = p
+		t.Value = p
+		return t, p
+	case list:
+		name, isCall := exp.function()
+		args := v
+		if isCall {
+			t.Func = name
+			args = v[1:]
+		}
+		values := make([]interface{}, 0, len(args))
+		for i, arg := range args {
+			c, value := x.explain(arg, isCall || i > 0)
+			t.Children = append(t.Children, c)
+			if c.Err != nil && t.Err == nil {
+				t.Err = c.Err
+			}
+			values = append(values, value)
+		}
+		if t.Err != nil {
+			return t, nil
+		}
+		if !isCall {
+			t.Value = values
+			return t, values
+		}
+		fn, _ := function.Get(name)
+		r, err := fn(values...)
+		if err != nil {
+			t.Err = err
+			return t, nil
+		}
+		t.Value = r
+		return t, r
+	}
+	t.Value = exp.i
+	return t, exp.i
+}
+
+// decide marks t and the clauses deciding the boolean value of t as Decisive
+func (t *Trace) decide() {
+	t.Decisive = true
+	if t.Err != nil {
+		for _, c := range t.Children {
+			if c.Err != nil {
+				c.decide()
+			}
+		}
+		return
+	}
+	b, ok := t.Value.(bool)
+	if !ok {
+		return
+	}
+	switch canonicalName(t.Func) {
+	case function.FuncAnd, function.FuncOr:
+		// the value which decides the result by itself, false for and, true for or
+		decisive := canonicalName(t.Func) == function.FuncOr
+		for _, c := range t.Children {
+			if b != decisive || c.Value == decisive {
+				c.decide()
+			}
+		}
+	case function.FuncNot:
+		for _, c := range t.Children {
+			c.decide()
+		}
+	}
+}
+
+// format returns the value in the form of literal if possible
+func format(v interface{}) string {
+	if l := literal(v, sexp{i: varString("")}); l.i != varString("") {
+		return l.source()
+	}
+	return fmt.Sprint(v)
+}
+
+// String renders the trace as indented text, the decisive clauses are marked with *
+func (t *Trace) String() string {
+	var b strings.Builder
+	t.write(&b, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (t *Trace) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("    ", depth))
+	if t.Decisive {
+		b.WriteString("* ")
+	} else {
+		b.WriteString("  ")
+	}
+	b.WriteString(t.Expr)
+	if t.Err != nil {
+		fmt.Fprintf(b, " = error: %v", t.Err)
+	} else if t.Func != "" || t.Param != "" || t.Children != nil {
+		fmt.Fprintf(b, " = %s", format(t.Value))
+	} else if v := format(t.Value); v != t.Expr && t.Value != nil {
+		fmt.Fprintf(b, " = %s", v)
+	}
+	b.WriteString("\n")
+	for _, c := range t.Children {
+		c.write(b, depth+1)
+	}
+}
+
+// MarshalJSON implements the interface json.Marshaler, Err is rendered as the message
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	type trace Trace
+	var msg string
+	if t.Err != nil {
+		msg = t.Err.Error()
+	}
+	return t.encode(struct {
+		*trace
+		Error string `json:"error,omitempty"`
+	}{(*trace)(t), msg})
+}
+
+// JSON renders the trace as JSON
+func (t *Trace) JSON() ([]byte, error) {
+	return t.encode(t)
+}
+
+func (t *Trace) encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	type input struct {
+		expr   string
+		params MapParams
+		trace  string
+	}
+	inputs := []input{
+		{
+			`(and (eq os "ios") (between years 18 30) (ne gender "male"))`,
+			MapParams{"os": "android", "years": 20, "gender": "male"},
+			`* (and (eq os "ios") (between years 18 30) (ne gender "male")) = false
+    * (eq os "ios") = false
+          os = "android"
+          "ios"
+      (between years 18 30) = true
+          years = 20
+          18
+          30
+    * (ne gender "male") = false
+          gender = "male"
+          "male"`,
+		},
+		{
+			`(| (eq os "ios") (not (eq years 18)))`,
+			MapParams{"os": "android", "years": 20},
+			`* (| (eq os "ios") (not (eq years 18))) = true
+      (eq os "ios") = false
+          os = "android"
+          "ios"
+    * (not (eq years 18)) = true
+        * (eq years 18) = false
+              years = 20
+              18`,
+		},
+		{
+			`(or (eq os "ios") (eq os "android"))`,
+			MapParams{"os": "web"},
+			`* (or (eq os "ios") (eq os "android")) = false
+    * (eq os "ios") = false
+          os = "web"
+          "ios"
+    * (eq os "android") = false
+          os = "web"
+          "android"`,
+		},
+		{
+			`(and (eq os "ios") (gt app_version (t_version "2.7.1")))`,
+			MapParams{"os": "ios"},
+			`* (and (eq os "ios") (gt app_version (t_version "2.7.1"))) = error: neither function not variable found
+      (eq os "ios") = true
+          os = "ios"
+          "ios"
+    * (gt app_version (t_version "2.7.1")) = error: neither function not variable found
+        * app_version = error: neither function not variable found
+          (t_version "2.7.1") = 200070001000000000000`,
+		},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace := e.Explain(input.params)
+		if got := trace.String(); got != input.trace {
+			t.Errorf("expression `%s` wanna:\n%s\ngot:\n%s", input.expr, input.trace, got)
+		}
+		r, err := e.Eval(input.params)
+		if r != trace.Value || err != trace.Err {
+			t.Errorf("expression `%s` wanna: %v, %v, got: %v, %v", input.expr, r, err, trace.Value, trace.Err)
+		}
+	}
+}
+
+func TestExplainJSON(t *testing.T) {
+	e, err := New(`(and (< years 18) (eq os missing))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := e.Explain(MapParams{"years": 20, "os": "ios"}).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Expr     string
+		Pos      Position
+		Value    interface{}
+		Error    string
+		Decisive bool
+		Params   map[string]interface{}
+		Children []struct {
+			Expr     string
+			Func     string
+			Decisive bool
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Expr != `(and (< years 18) (eq os missing))` || got.Value != nil || got.Error != ErrNotFound.Error() || !got.Decisive {
+		t.Errorf("got: %s", b)
+	}
+	if len(got.Params) != 2 || got.Params["years"] != float64(20) || got.Params["os"] != "ios" {
+		t.Errorf("wanna params: years, os, got: %v", got.Params)
+	}
+	if len(got.Children) != 2 || got.Children[0].Func != "<" || got.Children[0].Decisive || !got.Children[1].Decisive {
+		t.Errorf("got: %s", b)
+	}
+	if got.Pos != (Position{Offset: 0, Line: 1, Column: 1}) {
+		t.Errorf("got: %v", got.Pos)
+	}
+}
+
+func ExampleExpression_Explain() {
+	e, err := New(`(and (in gender ("female" "male")) (between years 18 30))`)
+	if err != nil {
+		return
+	}
+	fmt.Println(e.Explain(MapParams{"gender": "male", "years": 16}))
+	// Output:
+	// * (and (in gender ("female" "male")) (between years 18 30)) = false
+	//       (in gender ("female" "male")) = true
+	//           gender = "male"
+	//           ("female" "male")
+	//     * (between years 18 30) = false
+	//           years = 16
+	//           18
+	//           30
+}
@@ -1,9 +1,11 @@
 package function
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
+	"math/bits"
 	"reflect"
 	"strconv"
 	"strings"
@@ -61,6 +63,15 @@ const (
 	// OperatorModulo is the function/operator keyword %
 	OperatorModulo = "%"
 
+	// FuncHash is the function/operator keyword hash
+	FuncHash = "hash"
+	// FuncBucket is the function/operator keyword bucket
+	FuncBucket = "bucket"
+	// FuncRollout is the function/operator keyword rollout
+	FuncRollout = "rollout"
+	// FuncVariant is the function/operator keyword variant
+	FuncVariant = "variant"
+
 	// OperatorAdd is the function/operator keyword +
 	OperatorAdd = "+"
 	// OperatorSubtract is the function/operator keyword -
@@ -145,15 +156,161 @@ func init() {
 
 	MustRegist(FuncModulo, Modulo)
 	MustRegist(OperatorModulo, Modulo)
+	MustRegist(FuncHash, Hash)
+	MustRegist(FuncBucket, Bucket)
+	MustRegist(FuncRollout, Rollout)
+	MustRegist(FuncVariant, Variant)
 	MustRegistFuncer(OperatorAdd, SuccessiveBinaryOperator{ModeAdd})
 	MustRegistFuncer(OperatorSubtract, BinaryOperator{ModeSubtract})
 	MustRegistFuncer(OperatorMultiply, SuccessiveBinaryOperator{ModeMultiply})
 	MustRegistFuncer(OperatorDivide, BinaryOperator{ModeDivide})
+
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{GenericType, ListOf(GenericType)}, Return: BoolType},
+		Doc:           "whether the first param is in the second param which must be a list or set, the constant list is looked up by hash",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in 1 (1 2))`, `(in (1) ((1)))`},
+		Prepare:       prepareSet(1),
+	}, FuncIn)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{OrderedType, OrderedType, OrderedType}, Return: BoolType},
+		Doc:           "whether the first param is in the range between the second and the third param, both inclusive",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(between age 18 20)`},
+	}, FuncBetween)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{ListOf(GenericType), ListOf(GenericType)}, Return: BoolType},
+		Doc:           "whether the two lists or sets have element(s) in common, the constant lists are looked up by hash",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(overlap region (3142 1860))`},
+		Prepare:       prepareSet(0),
+	}, FuncOverlap)
+	describe(FuncSpec{
+		Doc:           "logic and of all params",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(and (eq gender "female") (between age 18 20))`},
+	}, FuncAnd, OperatorAnd)
+	describe(FuncSpec{
+		Doc:           "logic or of all params",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(or (eq gender "female") (between age 18 20))`},
+	}, FuncOr, OperatorOr)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{BoolType}, Return: BoolType},
+		Doc:           "logic not",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(not (eq gender "female"))`},
+	}, FuncNot, OperatorNot)
+	describe(FuncSpec{
+		Doc:           "whether all params are equal, lists are compared element by element",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(eq gender "female")`},
+	}, FuncEqual, OperatorEqual)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{GenericType, GenericType}, Variadic: true, Return: BoolType},
+		Doc:           "whether the params are not equal to each other",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(ne os "ios")`},
+	}, FuncNotEqual, OperatorNotEqual)
+	for _, v := range []struct {
+		names []string
+		doc   string
+	}{
+		{[]string{FuncGreaterThan, OperatorGreaterThan}, "greater than"},
+		{[]string{FuncLessThan, OperatorLessThan}, "less than"},
+		{[]string{FuncGreaterThanOrEqualTo, OperatorGreaterThanOrEqualTo}, "greater than or equal to"},
+		{[]string{FuncLessThanOrEqualTo, OperatorLessThanOrEqualTo}, "less than or equal to"},
+	} {
+		describe(FuncSpec{
+			Doc:           "whether the first param is " + v.doc + " the second one",
+			Pure:          true,
+			Deterministic: true,
+			Examples:      []string{fmt.Sprintf("(%s age 18)", v.names[0])},
+		}, v.names...)
+	}
+	describe(FuncSpec{
+		Doc:           "converts version strings to comparable numbers",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(t_version "2.7.1")`, `(t_version ("2.7.1" "2.9.1"))`},
+	}, FuncTypeVersion)
+	describe(FuncSpec{
+		Doc:           "converts strings to time, the first param is the layout",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(t_time "2006-01-02 15:04" "2017-09-09 12:00")`},
+	}, FuncTypeTime)
+	describe(FuncSpec{
+		Doc:           "converts strings to time with the layout " + DefaultTimeFormat,
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(td_time "2017-09-09 12:00:00")`},
+	}, FuncTypeDefaultTime)
+	describe(FuncSpec{
+		Doc:           "converts strings to time with the layout " + DefaultDateFormat,
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(td_date "2017-09-09")`},
+	}, FuncTypeDefaultDate)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType}, Return: NumberType},
+		Doc:           "remainder of the first param divided by the second one, both are truncated to integer",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(mod age 5)`},
+	}, FuncModulo, OperatorModulo)
+	describe(FuncSpec{
+		MinArgs:       1,
+		MaxArgs:       2,
+		Signature:     Signature{Params: []Type{AnyType, StringType}, Return: NumberType},
+		Doc:           "murmur3 hash of the first param salted with the optional second param, which is stable across versions and architectures",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(hash user_id)`, `(hash user_id "feature-x")`},
+	}, FuncHash)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType, StringType, NumberType}, Return: NumberType},
+		Doc:           "the bucket of the first param salted with the second param, ranging from 0 to the third param exclusive",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(bucket user_id "feature-x" 100)`},
+	}, FuncBucket)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType, StringType, NumberType}, Return: BoolType},
+		Doc:           "whether the first param salted with the second param is within the percentage of the third param, raising the percentage keeps the ones within",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(rollout user_id "feature-x" 25)`},
+	}, FuncRollout)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType, StringType, ListOf(AnyType)}, Return: AnyType},
+		Doc:           "the variant of the first param salted with the second param, chosen from the pairs of variant and weight",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(variant user_id "experiment-y" (("A" 50) ("B" 50)))`},
+	}, FuncVariant)
+	describe(FuncSpec{Doc: "sum of all params", Pure: true, Deterministic: true, Examples: []string{`(+ 1 2 3)`}}, OperatorAdd)
+	describe(FuncSpec{Doc: "product of all params", Pure: true, Deterministic: true, Examples: []string{`(* 1 2 3)`}}, OperatorMultiply)
+	describe(FuncSpec{Doc: "the first param minus the second one", Pure: true, Deterministic: true, Examples: []string{`(- 3 1)`}}, OperatorSubtract)
+	describe(FuncSpec{Doc: "the first param divided by the second one", Pure: true, Deterministic: true, Examples: []string{`(/ 3 1)`}}, OperatorDivide)
 }
 
-// Equal returns whether the input params are equal to each other, array type is supported too
+// Equal returns whether the input params are equal to each other, array and map types are supported too
 type Equal struct{}
 
+// Signature implements the interface Signer
+func (f Equal) Signature() Signature {
+	return Signature{Params: []Type{GenericType, GenericType}, Variadic: true, Return: BoolType}
+}
+
 // Eval implements the interface Funcer
 func (f Equal) Eval(params ...interface{}) (res interface{}, err error) {
 	defer func() {
@@ -172,7 +329,7 @@ func (f Equal) eval(params ...interface{}) (res bool, err error) {
 	if l < 2 {
 		return false, fmt.Errorf("equal: need at least two params, but got %d", l)
 	}
-	if k := reflect.TypeOf(params[0]).Kind(); k == reflect.Slice || k == reflect.Array {
+	if k := reflect.TypeOf(params[0]).Kind(); (k == reflect.Slice || k == reflect.Array) && !hasSet(params) {
 		vs := make([]reflect.Value, l)
 		max := 0
 		for i := 0; i < l; i++ {
@@ -201,7 +358,7 @@ func (f Equal) eval(params ...interface{}) (res bool, err error) {
 		}
 	} else {
 		for i := 1; i < len(params); i++ {
-			if params[0] != params[i] {
+			if !equalValues(params[0], params[i]) {
 				return false, nil
 			}
 		}
@@ -209,6 +366,53 @@ func (f Equal) eval(params ...interface{}) (res bool, err error) {
 	return true, nil
 }
 
+// hasSet returns whether any of the params is Set
+func hasSet(params []interface{}) bool {
+	for _, p := range params {
+		if _, ok := p.(*Set); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues returns whether the uniformed values are equal, the lists and maps are compared by their elements.
+// Set equals to the Set or list of the same values regardless of the order
+func equalValues(a, b interface{}) bool {
+	if _, ok := b.(*Set); ok {
+		a, b = b, a
+	}
+	if s, ok := a.(*Set); ok {
+		other, err := ToSet(b)
+		return err == nil && s.Equal(other)
+	}
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !equalValues(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
 // NotEqual returns whether the input params are not equal with each other, array type is supported too
 func NotEqual(params ...interface{}) (res interface{}, err error) {
 	l := len(params)
@@ -228,11 +432,14 @@ func NotEqual(params ...interface{}) (res interface{}, err error) {
 	return true, nil
 }
 
-// In returns whether first param is in the second param(must be array type). The length of params must be 2
+// In returns whether first param is in the second param(must be array type or Set). The length of params must be 2
 func In(params ...interface{}) (interface{}, error) {
 	if l := len(params); l != 2 {
 		return false, fmt.Errorf("in: need two params, but got %d", l)
 	}
+	if s, ok := params[1].(*Set); ok {
+		return s.Contains(params[0]), nil
+	}
 	if k := reflect.TypeOf(params[1]).Kind(); k != reflect.Slice && k != reflect.Array {
 		return false, errors.New("in: the second param must be an array")
 	}
@@ -241,18 +448,25 @@ func In(params ...interface{}) (interface{}, error) {
 
 	array := reflect.ValueOf(params[1])
 	for i := 0; i < array.Len(); i++ {
-		if params[0] == array.Index(i).Interface() {
+		if equalValues(params[0], array.Index(i).Interface()) {
 			return true, nil
 		}
 	}
 	return false, nil
 }
 
-// Overlap returns whether two arrays have element(s) in common. The length of params must be 2 and type must be array.
+// Overlap returns whether two arrays have element(s) in common. The length of params must be 2 and type must be array or Set.
 func Overlap(params ...interface{}) (interface{}, error) {
 	if l := len(params); l != 2 {
 		return false, fmt.Errorf("overlap: need two params, but got %d", l)
 	}
+	if s, ok := params[0].(*Set); ok {
+		// the other param is iterated while the set is looked up
+		params = []interface{}{params[1], s}
+		if other, ok := params[0].(*Set); ok {
+			params[0] = other.values
+		}
+	}
 	first := reflect.TypeOf(params[0])
 	if k := first.Kind(); k != reflect.Slice && k != reflect.Array {
 		return false, fmt.Errorf("overlap: the params should be array type")
@@ -273,6 +487,11 @@ type AndOr struct {
 	Mode uint8
 }
 
+// Signature implements the interface Signer
+func (f AndOr) Signature() Signature {
+	return Signature{Params: []Type{BoolType, BoolType}, Variadic: true, Return: BoolType}
+}
+
 // Eval implements the interface Funcer
 func (f AndOr) Eval(params ...interface{}) (interface{}, error) {
 	if l := len(params); l < 2 {
@@ -316,6 +535,11 @@ type Compare struct {
 	Mode uint8
 }
 
+// Signature implements the interface Signer
+func (f Compare) Signature() Signature {
+	return Signature{Params: []Type{OrderedType, OrderedType}, Return: BoolType}
+}
+
 // Eval implements the interface Funcer
 func (f Compare) Eval(params ...interface{}) (interface{}, error) {
 	if l := len(params); l != 2 {
@@ -339,6 +563,10 @@ func (f Compare) Eval(params ...interface{}) (interface{}, error) {
 		}
 	case time.Time:
 		return f.evalTime(left, params[1].(time.Time))
+	case Semver:
+		return f.evalOrder(left.Compare(params[1].(Semver)))
+	case IP:
+		return f.evalOrder(left.Compare(params[1].(IP)))
 	default:
 		l, err := toFloat64(left)
 		if err != nil {
@@ -373,6 +601,21 @@ func (f Compare) evalTime(left, right time.Time) (bool, error) {
 	return false, fmt.Errorf("mode %v not supported", f.Mode)
 }
 
+// evalOrder applies the mode on the result of comparison, which is -1, 0 or 1
+func (f Compare) evalOrder(c int) (bool, error) {
+	switch f.Mode {
+	case ModeGreaterThan:
+		return c > 0, nil
+	case ModeLessThan:
+		return c < 0, nil
+	case ModeGreaterThanOrEqualTo:
+		return c >= 0, nil
+	case ModeLessThanOrEqualTo:
+		return c <= 0, nil
+	}
+	return false, fmt.Errorf("mode %v not supported", f.Mode)
+}
+
 // Between returns whether first param is in the range between second and third param. The input params must be comparable
 func Between(params ...interface{}) (interface{}, error) {
 	if l := len(params); l != 3 {
@@ -395,6 +638,14 @@ type TypeTime struct {
 	Format string
 }
 
+// Signature implements the interface Signer
+func (f TypeTime) Signature() Signature {
+	if f.Format == "" {
+		return Signature{Params: []Type{StringType, StringType}, Variadic: true, Broadcast: true, Return: TimeType}
+	}
+	return Signature{Params: []Type{StringType}, Variadic: true, Broadcast: true, Return: TimeType}
+}
+
 // Eval implements the interface Funcer
 func (f TypeTime) Eval(params ...interface{}) (res interface{}, err error) {
 	if l := len(params); f.Format == "" && l < 2 {
@@ -453,6 +704,11 @@ func (f TypeTime) eval(params ...interface{}) (interface{}, error) {
 // TypeVersion converts version string to a comparable number
 type TypeVersion struct{}
 
+// Signature implements the interface Signer
+func (f TypeVersion) Signature() Signature {
+	return Signature{Params: []Type{StringType}, Variadic: true, Broadcast: true, Return: VersionType}
+}
+
 // Eval implements the interface Funcer
 func (f TypeVersion) Eval(params ...interface{}) (interface{}, error) {
 	if l := len(params); l < 1 {
@@ -531,14 +787,197 @@ func Modulo(params ...interface{}) (interface{}, error) {
 	if err != nil {
 		return 0, fmt.Errorf("mod: %+v", err)
 	}
+	if right == 0 {
+		return 0, errors.New("mod: divisor should not be zero")
+	}
 	return left % right, nil
 }
 
+// rolloutBuckets is the count of buckets used by Rollout and Variant, so the percentage is accurate to 0.01
+const rolloutBuckets = 10000
+
+// Hash returns the murmur3 hash of the first param salted with the optional second param, as float64.
+// The param of number is hashed as its shortest decimal, e.g. 12 and 12.0 are the same
+func Hash(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 && l != 2 {
+		return nil, fmt.Errorf("hash: need one or two params, but got %d", l)
+	}
+	salt := ""
+	if len(params) == 2 {
+		s, ok := params[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("hash: salt should be string, but got %v", params[1])
+		}
+		salt = s
+	}
+	h, err := saltedHash(params[0], salt)
+	if err != nil {
+		return nil, fmt.Errorf("hash: %v", err)
+	}
+	return float64(h), nil
+}
+
+// Bucket returns the bucket of the first param salted with the second param, ranging in [0, n) where n is the third param
+func Bucket(params ...interface{}) (interface{}, error) {
+	h, n, err := hashParams(FuncBucket, params...)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("bucket: count of buckets should be positive, but got %d", n)
+	}
+	// n may exceed the range of uint32
+	return float64(uint64(h) % uint64(n)), nil
+}
+
+// Rollout returns whether the first param salted with the second param is within the percentage of the third param.
+// The ones within stay within when the percentage is raised
+func Rollout(params ...interface{}) (interface{}, error) {
+	h, _, err := hashParams(FuncRollout, params...)
+	if err != nil {
+		return nil, err
+	}
+	percentage, err := toFloat64(params[2])
+	if err != nil {
+		return nil, fmt.Errorf("rollout: %v", err)
+	}
+	return float64(h%rolloutBuckets) < percentage*rolloutBuckets/100, nil
+}
+
+// Variant returns the variant of the first param salted with the second param,
+// the third param is a list of pairs of variant and weight, e.g. (("A" 50) ("B" 50))
+func Variant(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 3 {
+		return nil, fmt.Errorf("variant: need three params, but got %d", l)
+	}
+	h, err := hashSalt(params...)
+	if err != nil {
+		return nil, fmt.Errorf("variant: %v", err)
+	}
+	var pairs []interface{}
+	if params[2] != nil {
+		pairs, _ = Uniform(params[2])[0].([]interface{})
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("variant: need a list of variants, but got %v", params[2])
+	}
+	variants := make([]interface{}, len(pairs))
+	weights := make([]float64, len(pairs))
+	var total float64
+	for i, p := range pairs {
+		pair, ok := p.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("variant: need pair of variant and weight, but got %v", p)
+		}
+		w, err := toFloat64(pair[1])
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("variant: illegal weight %v", pair[1])
+		}
+		variants[i], weights[i] = pair[0], w
+		total += w
+	}
+	if total <= 0 {
+		return nil, errors.New("variant: total weight should be positive")
+	}
+	point := float64(h % rolloutBuckets)
+	var cumulative float64
+	for i, w := range weights {
+		cumulative += w
+		if point < cumulative*rolloutBuckets/total {
+			return variants[i], nil
+		}
+	}
+	return variants[len(variants)-1], nil
+}
+
+// hashParams returns the hash of the first two params and the third param as integer
+func hashParams(name string, params ...interface{}) (uint32, int64, error) {
+	if l := len(params); l != 3 {
+		return 0, 0, fmt.Errorf("%s: need three params, but got %d", name, l)
+	}
+	h, err := hashSalt(params...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", name, err)
+	}
+	n, err := toInt64(params[2])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", name, err)
+	}
+	return h, n, nil
+}
+
+// hashSalt returns the hash of the first param salted with the second param
+func hashSalt(params ...interface{}) (uint32, error) {
+	salt, ok := params[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("salt should be string, but got %v", params[1])
+	}
+	return saltedHash(params[0], salt)
+}
+
+// saltedHash returns the murmur3 hash of salt:id, or id alone without salt
+func saltedHash(id interface{}, salt string) (uint32, error) {
+	key, err := toString(id)
+	if err != nil {
+		return 0, err
+	}
+	if salt != "" {
+		key = salt + ":" + key
+	}
+	return murmur3([]byte(key), 0), nil
+}
+
+// murmur3 is the 32-bit MurmurHash3 of x86, which reads the data as little endian regardless of the architecture
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	h := seed
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	switch len(data) - n {
+	case 3:
+		k ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[n])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
 // SuccessiveBinaryOperator implements successive plus or multiply
 type SuccessiveBinaryOperator struct {
 	Mode uint8
 }
 
+// Signature implements the interface Signer
+func (f SuccessiveBinaryOperator) Signature() Signature {
+	return Signature{Params: []Type{NumberType, NumberType}, Variadic: true, Return: NumberType}
+}
+
 // Eval implements the interface Funcer
 func (f SuccessiveBinaryOperator) Eval(params ...interface{}) (interface{}, error) {
 	if l := len(params); l < 2 {
@@ -570,6 +1009,11 @@ type BinaryOperator struct {
 	Mode uint8
 }
 
+// Signature implements the interface Signer
+func (f BinaryOperator) Signature() Signature {
+	return Signature{Params: []Type{NumberType, NumberType}, Return: NumberType}
+}
+
 // Eval implements the interface Funcer
 func (f BinaryOperator) Eval(params ...interface{}) (interface{}, error) {
 	if l := len(params); l != 2 {
@@ -604,6 +1048,9 @@ var (
 func toFloat64(uv interface{}) (float64, error) {
 	v := reflect.ValueOf(uv)
 	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return 0, fmt.Errorf("cannot convert %v to float64", uv)
+	}
 	if !v.Type().ConvertibleTo(tFloat64) {
 		return 0, fmt.Errorf("cannot convert %v to float64", v.Type())
 	}
@@ -614,6 +1061,9 @@ func toFloat64(uv interface{}) (float64, error) {
 func toInt64(uv interface{}) (int64, error) {
 	v := reflect.ValueOf(uv)
 	v = reflect.Indirect(v)
+	if !v.IsValid() {
+		return 0, fmt.Errorf("cannot convert %v to float64", uv)
+	}
 	if !v.Type().ConvertibleTo(tInt64) {
 		return 0, fmt.Errorf("cannot convert %v to float64", v.Type())
 	}
@@ -637,10 +1087,14 @@ func convertible(params ...interface{}) bool {
 	return true
 }
 
-// Uniform converts any number-like element to type of float64 as much as possible
+// Uniform converts any number-like element to type of float64 as much as possible, time.Duration is converted into nanoseconds.
+// The maps of string keys are converted into map[string]interface{} along with their values
 func Uniform(params ...interface{}) []interface{} {
 	res := make([]interface{}, len(params))
 	for i, p := range params {
+		if p == nil {
+			continue
+		}
 		if k := reflect.TypeOf(p).Kind(); k == reflect.Slice || k == reflect.Array {
 			v := reflect.ValueOf(p)
 			ps := make([]interface{}, v.Len())
@@ -648,6 +1102,13 @@ func Uniform(params ...interface{}) []interface{} {
 				ps[j] = v.Index(j).Interface()
 			}
 			res[i] = Uniform(ps...)
+		} else if k == reflect.Map && reflect.TypeOf(p).Key().Kind() == reflect.String {
+			v := reflect.ValueOf(p)
+			m := make(map[string]interface{}, v.Len())
+			for it := v.MapRange(); it.Next(); {
+				m[it.Key().String()] = Uniform(it.Value().Interface())[0]
+			}
+			res[i] = m
 		} else {
 			switch t := reflect.ValueOf(p); t.Kind() {
 			case reflect.String:
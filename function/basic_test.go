@@ -1,6 +1,8 @@
 package function
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -42,8 +44,16 @@ func TestEqual(t *testing.T) {
 		{[]interface{}{[]interface{}{100, 200, 300}, []interface{}{"hi there", 200.0, 300.0}}, false, false},
 		{[]interface{}{[]interface{}{100, 200, 300}, 300.0}, false, false},
 
+		{[]interface{}{map[string]string{"one": "one"}, map[string]interface{}{"one": "one"}}, true, false},
+		{[]interface{}{map[string]int{"one": 1}, map[string]interface{}{"one": 1.0}}, true, false},
+		{[]interface{}{map[string]interface{}{"one": []int{1}, "two": nil}, map[string]interface{}{"one": []interface{}{1.0}, "two": nil}}, true, false},
+		{[]interface{}{[]interface{}{map[string]int{"one": 1}}, []interface{}{map[string]int{"one": 1}}}, true, false},
+		{[]interface{}{map[string]int{"one": 1}, map[string]int{"one": 2}}, false, false},
+		{[]interface{}{map[string]int{"one": 1}, map[string]int{"one": 1, "two": 2}}, false, false},
+		{[]interface{}{map[string]int{"one": 1}, 1}, false, false},
+
 		{[]interface{}{"200"}, false, true},
-		{[]interface{}{map[string]string{"one": "one"}, map[string]string{"one": "one"}}, false, true},
+		{[]interface{}{map[int]string{1: "one"}, map[int]string{1: "one"}}, false, true},
 	}
 	for _, input := range inputs {
 		res, err := Equal{}.Eval(input.params...)
@@ -82,8 +92,11 @@ func TestNotEqual(t *testing.T) {
 		{[]interface{}{100, 100, "100"}, false, false},
 		{[]interface{}{[]interface{}{100.0}, 200.0, 200}, false, false},
 
+		{[]interface{}{map[string]string{"one": "one"}, map[string]string{"one": "one"}}, false, false},
+		{[]interface{}{map[string]string{"one": "one"}, map[string]string{"one": "two"}}, true, false},
+
 		{[]interface{}{"200"}, nil, true},
-		{[]interface{}{map[string]string{"one": "one"}, map[string]string{"one": "one"}}, nil, true},
+		{[]interface{}{map[int]string{1: "one"}, map[int]string{1: "one"}}, nil, true},
 	}
 	for _, input := range inputs {
 		res, err := NotEqual(input.params...)
@@ -117,6 +130,9 @@ func TestIn(t *testing.T) {
 		{[]interface{}{"100", []interface{}{100, 200, 300}}, false, false},
 		{[]interface{}{now, []interface{}{time.Now(), time.Now()}}, false, false},
 		{[]interface{}{200, []interface{}{100, "200", 300}}, false, false},
+		{[]interface{}{map[string]int{"a": 1}, []interface{}{map[string]float64{"a": 2}}}, false, false},
+		{[]interface{}{map[string]int{"a": 1}, []interface{}{1, map[string]float64{"a": 1}}}, true, false},
+		{[]interface{}{[]int{1, 2}, []interface{}{[]float64{1, 2}}}, true, false},
 
 		{[]interface{}{true, 100, []interface{}{false, true}}, nil, true},
 		{[]interface{}{[]interface{}{true}, false}, nil, true},
@@ -652,6 +668,7 @@ func TestModulo(t *testing.T) {
 		{[]interface{}{-5.0}, nil, true},
 		{[]interface{}{-5.0, "one"}, nil, true},
 		{[]interface{}{"one", 5}, nil, true},
+		{[]interface{}{5, 0}, nil, true},
 	}
 	for _, input := range inputs {
 		res, err := Modulo(input.params...)
@@ -695,3 +712,166 @@ func TestConvertible(t *testing.T) {
 		}
 	}
 }
+
+func TestMurmur3(t *testing.T) {
+	inputs := []struct {
+		data string
+		seed uint32
+		hash uint32
+	}{
+		{"", 0, 0},
+		{"", 1, 0x514e28b7},
+		{"a", 0, 0x3c2569b2},
+		{"abcd", 0, 0x43ed676a},
+		{"hello", 0, 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0, 0x2e4ff723},
+	}
+	for _, input := range inputs {
+		if h := murmur3([]byte(input.data), input.seed); h != input.hash {
+			t.Errorf("input: %q wanna: %#x, got: %#x", input.data, input.hash, h)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	inputs := []res{
+		{[]interface{}{"hello"}, float64(0x248bfa47), false},
+		{[]interface{}{12}, float64(murmur3([]byte("12"), 0)), false},
+		{[]interface{}{12.0, "x"}, float64(murmur3([]byte("x:12"), 0)), false},
+		{[]interface{}{"12", "x"}, float64(murmur3([]byte("x:12"), 0)), false},
+
+		{[]interface{}{}, nil, true},
+		{[]interface{}{nil}, nil, true},
+		{[]interface{}{true}, nil, true},
+		{[]interface{}{"a", 1}, nil, true},
+		{[]interface{}{[]interface{}{1, "a", nil}}, nil, true},
+	}
+	for _, input := range inputs {
+		res, err := Hash(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("input: %v, shoud have errors but got none", input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("input: %v, shoud not have error but got %s", input.params, err.Error())
+			continue
+		}
+		if input.result != res {
+			t.Errorf("input: %v wanna: %v, got: %v", input.params, input.result, res)
+		}
+	}
+}
+
+func TestRollout(t *testing.T) {
+	// the results are pinned, so any change of them breaks the rollouts running
+	pinned := []res{
+		{[]interface{}{"user-1", "feature-x", 100}, float64(80), false},
+		{[]interface{}{"user-2", "feature-x", 100}, float64(43), false},
+		{[]interface{}{10086, "feature-x", 100}, float64(9), false},
+	}
+	for _, input := range pinned {
+		res, err := Bucket(input.params...)
+		if err != nil || res != input.result {
+			t.Errorf("input: %v wanna: %v, got: %v, %v", input.params, input.result, res, err)
+		}
+	}
+
+	const users = 100000
+	counts := make(map[interface{}]int)
+	var within10, within25 int
+	for i := 0; i < users; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		in10, err := Rollout(id, "feature-x", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in25, err := Rollout(id, "feature-x", 25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if in10.(bool) && !in25.(bool) {
+			t.Fatalf("%s is within 10%% but not 25%%", id)
+		}
+		if in10.(bool) {
+			within10++
+		}
+		if in25.(bool) {
+			within25++
+		}
+		v, err := Variant(id, "experiment-y", []interface{}{[]interface{}{"A", 50}, []interface{}{"B", 30}, []interface{}{"C", 20}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[v]++
+	}
+	// the tolerance is far beyond 3 standard deviations
+	if within10 < 9500 || within10 > 10500 {
+		t.Errorf("wanna about 10%% within, got: %d", within10)
+	}
+	if within25 < 24500 || within25 > 25500 {
+		t.Errorf("wanna about 25%% within, got: %d", within25)
+	}
+	for v, share := range map[interface{}]int{"A": 50, "B": 30, "C": 20} {
+		if got := counts[v]; got < share*users/100-1000 || got > share*users/100+1000 {
+			t.Errorf("wanna about %d%% of %v, got: %d", share, v, got)
+		}
+	}
+
+	for _, input := range []res{
+		{[]interface{}{"u", "x", 0}, false, false},
+		{[]interface{}{"u", "x", 100}, true, false},
+		{[]interface{}{"u", "x"}, nil, true},
+		{[]interface{}{"u", "x", "all"}, nil, true},
+	} {
+		res, err := Rollout(input.params...)
+		if (err != nil) != input.err || res != input.result {
+			t.Errorf("input: %v wanna: %v, got: %v, %v", input.params, input.result, res, err)
+		}
+	}
+	h, _ := Hash("u1", "s")
+	for _, n := range []interface{}{4294967296, int64(8589934592), 1e10} {
+		if res, err := Bucket("u1", "s", n); err != nil || res != h {
+			t.Errorf("input: %v wanna: %v, got: %v, %v", n, h, res, err)
+		}
+	}
+	for _, params := range [][]interface{}{
+		{"u", "x", 0},
+		{"u", 1, 10},
+		{[]interface{}{1, "a", nil}, "a", 10},
+	} {
+		if _, err := Bucket(params...); err == nil {
+			t.Errorf("input: %v, shoud have errors but got none", params)
+		}
+	}
+	for _, params := range [][]interface{}{
+		{"u", "x", []interface{}{}},
+		{"u", "x", []interface{}{[]interface{}{"A", 0}}},
+		{"u", "x", []interface{}{[]interface{}{"A", -1}, []interface{}{"B", 2}}},
+		{"u", "x", []interface{}{"A", 50}},
+		{"u", "x", nil},
+	} {
+		if _, err := Variant(params...); err == nil {
+			t.Errorf("input: %v, shoud have errors but got none", params)
+		}
+	}
+}
+
+func TestUniform(t *testing.T) {
+	inputs := []struct {
+		param  interface{}
+		result interface{}
+	}{
+		{nil, nil},
+		{[]interface{}{1, "a", nil}, []interface{}{1.0, "a", nil}},
+		{[]interface{}{[]interface{}{nil}}, []interface{}{[]interface{}{nil}}},
+		{[]*int{nil}, []interface{}{(*int)(nil)}},
+		{map[string]interface{}{"a": 1, "b": nil}, map[string]interface{}{"a": 1.0, "b": nil}},
+	}
+	for _, input := range inputs {
+		if res := Uniform(input.param)[0]; !reflect.DeepEqual(res, input.result) {
+			t.Errorf("input: %v wanna: %#v, got: %#v", input.param, input.result, res)
+		}
+	}
+}
@@ -40,13 +40,19 @@ func RegistFuncer(name string, fn Funcer) error {
 	if _, exist := functions[name]; exist {
 		return ErrFunctionExists
 	}
-	functions[name] = fn.Eval
+	MustRegistFuncer(name, fn)
 	return nil
 }
 
-// MustRegistFuncer is same as RegistFuncer but may overide if function with name existed
+// MustRegistFuncer is same as RegistFuncer but may overide if function with name existed.
+// The FuncSpec or Signature is registered as well if fn implements Describer or Signer
 func MustRegistFuncer(name string, fn Funcer) {
-	functions[name] = fn.Eval
+	MustRegist(name, fn.Eval)
+	if d, ok := fn.(Describer); ok {
+		specs[name] = d.Spec().normalize(name)
+	} else if s, ok := fn.(Signer); ok {
+		specs[name] = FuncSpec{Signature: s.Signature()}.normalize(name)
+	}
 }
 
 // Regist regists fn with type Func with name of name
@@ -54,7 +60,7 @@ func Regist(name string, fn Func) error {
 	if _, exist := functions[name]; exist {
 		return ErrFunctionExists
 	}
-	functions[name] = fn
+	MustRegist(name, fn)
 	return nil
 }
 
@@ -70,4 +76,5 @@ func Registered() []string {
 // MustRegist is same as Regist but may overide if function with name existed
 func MustRegist(name string, fn Func) {
 	functions[name] = fn
+	delete(specs, name)
 }
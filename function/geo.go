@@ -0,0 +1,481 @@
+package function
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+const (
+	// FuncDistanceKm is the function/operator keyword distance_km
+	FuncDistanceKm = "distance_km"
+	// FuncWithinKm is the function/operator keyword within_km
+	FuncWithinKm = "within_km"
+	// FuncInBBox is the function/operator keyword in_bbox
+	FuncInBBox = "in_bbox"
+	// FuncInPolygon is the function/operator keyword in_polygon
+	FuncInPolygon = "in_polygon"
+	// FuncGeohash is the function/operator keyword geohash
+	FuncGeohash = "geohash"
+	// FuncGeohashPrefix is the function/operator keyword geohash_prefix
+	FuncGeohashPrefix = "geohash_prefix"
+)
+
+// EarthRadiusKm is the mean radius of the earth used by haversine distance
+const EarthRadiusKm = 6371.0088
+
+func init() {
+	MustRegist(FuncDistanceKm, DistanceKm)
+	MustRegist(FuncWithinKm, WithinKm)
+	MustRegist(FuncInBBox, InBBox)
+	MustRegist(FuncInPolygon, InPolygon)
+	MustRegist(FuncGeohash, Geohash)
+	MustRegist(FuncGeohashPrefix, GeohashPrefix)
+
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType, NumberType, NumberType}, Return: NumberType},
+		Doc:           "haversine distance in kilometers between the two points of latitude and longitude",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(distance_km lat lng 31.23 121.47)`},
+	}, FuncDistanceKm)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType, NumberType, NumberType, NumberType}, Return: BoolType},
+		Doc:           "whether the first point is within the radius in kilometers of the second one",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(within_km lat lng 31.23 121.47 50)`},
+	}, FuncWithinKm)
+	describe(FuncSpec{
+		Signature: Signature{Params: []Type{NumberType, NumberType, NumberType, NumberType, NumberType, NumberType}, Return: BoolType},
+		Doc: "whether the point is within the bounding box of the south west and north east corners, " +
+			"the box crosses the antimeridian if the west longitude is greater than the east one",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in_bbox lat lng 30.7 120.8 31.9 122.2)`},
+	}, FuncInBBox)
+	describe(FuncSpec{
+		Signature: Signature{Params: []Type{NumberType, NumberType, AnyType}, Return: BoolType},
+		Doc: "whether the point is within the GeoJSON Polygon, MultiPolygon, Feature or FeatureCollection, in which the holes are excluded. " +
+			"The GeoJSON of literal is parsed and indexed once when parsing, and the GeoJSON string is limited to MaxPatternSize bytes",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in_polygon lat lng "{\"type\":\"Polygon\",\"coordinates\":[[[121,31],[122,31],[122,32],[121,31]]]}")`},
+		Prepare:       preparePolygon,
+	}, FuncInPolygon)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType, NumberType}, Return: StringType},
+		Doc:           "geohash of the point with the precision of characters ranging from 1 to 12",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(geohash lat lng 6)`},
+	}, FuncGeohash)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType, AnyType}, Return: BoolType},
+		Doc:           "whether the geohash of the point starts with the prefix or any of the list of prefixes",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(geohash_prefix lat lng ("wtw3" "wtw6"))`},
+	}, FuncGeohashPrefix)
+}
+
+// coordinates converts the params into float64 in pairs of latitude and longitude, which are checked within range
+func coordinates(name string, params []interface{}, n int) ([]float64, error) {
+	if l := len(params); l != n {
+		return nil, fmt.Errorf("%s: need %d params, but got %d", name, n, l)
+	}
+	vs := make([]float64, n)
+	for i, p := range params {
+		v, err := toNumber(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		vs[i] = v
+	}
+	for i := 0; i+1 < n; i += 2 {
+		if err := checkPoint(vs[i], vs[i+1]); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return vs, nil
+}
+
+func checkPoint(lat, lng float64) error {
+	if !(lat >= -90 && lat <= 90) || !(lng >= -180 && lng <= 180) {
+		return fmt.Errorf("%w: latitude %v, longitude %v", ErrDomain, lat, lng)
+	}
+	return nil
+}
+
+// haversine returns the distance in kilometers
+func haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dlat, dlng := (lat2-lat1)*rad, (lng2-lng1)*rad
+	a := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dlng/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// DistanceKm returns the haversine distance in kilometers between the two points
+func DistanceKm(params ...interface{}) (interface{}, error) {
+	vs, err := coordinates(FuncDistanceKm, params, 4)
+	if err != nil {
+		return nil, err
+	}
+	return haversine(vs[0], vs[1], vs[2], vs[3]), nil
+}
+
+// WithinKm returns whether the first point is within the radius in kilometers of the second one
+func WithinKm(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 5 {
+		return false, fmt.Errorf("within_km: need 5 params, but got %d", l)
+	}
+	vs, err := coordinates(FuncWithinKm, params[:4], 4)
+	if err != nil {
+		return false, err
+	}
+	radius, err := toNumber(params[4])
+	if err != nil {
+		return false, fmt.Errorf("within_km: %v", err)
+	}
+	return haversine(vs[0], vs[1], vs[2], vs[3]) <= radius, nil
+}
+
+// InBBox returns whether the point is within the bounding box of the south west and north east corners
+func InBBox(params ...interface{}) (interface{}, error) {
+	vs, err := coordinates(FuncInBBox, params, 6)
+	if err != nil {
+		return false, err
+	}
+	lat, lng, south, west, north, east := vs[0], vs[1], vs[2], vs[3], vs[4], vs[5]
+	if lat < south || lat > north {
+		return false, nil
+	}
+	if west <= east {
+		return lng >= west && lng <= east, nil
+	}
+	// crossing the antimeridian
+	return lng >= west || lng <= east, nil
+}
+
+// edge is the segment of ring in the form of x as longitude and y as latitude
+type edge struct {
+	x1, y1, x2, y2 float64
+}
+
+// polygonIndex is the polygon with holes, of which the edges are bucketed into horizontal slabs by latitude,
+// so only the edges of the slab are tested by ray casting
+type polygonIndex struct {
+	minX, minY, maxX, maxY float64
+	height                 float64
+	slabs                  [][]edge
+}
+
+func newPolygonIndex(rings [][][]float64) (*polygonIndex, error) {
+	var edges []edge
+	p := &polygonIndex{minX: math.Inf(1), minY: math.Inf(1), maxX: math.Inf(-1), maxY: math.Inf(-1)}
+	for _, ring := range rings {
+		if n := len(ring); n > 1 && ring[0][0] == ring[n-1][0] && ring[0][1] == ring[n-1][1] {
+			ring = ring[:n-1]
+		}
+		if len(ring) < 3 {
+			return nil, fmt.Errorf("%w: ring of polygon needs at least 3 positions", ErrIllegalFormat)
+		}
+		for i, pos := range ring {
+			if err := checkPoint(pos[1], pos[0]); err != nil {
+				return nil, err
+			}
+			next := ring[(i+1)%len(ring)]
+			edges = append(edges, edge{pos[0], pos[1], next[0], next[1]})
+			p.minX, p.maxX = math.Min(p.minX, pos[0]), math.Max(p.maxX, pos[0])
+			p.minY, p.maxY = math.Min(p.minY, pos[1]), math.Max(p.maxY, pos[1])
+		}
+	}
+	n := len(edges) / 8
+	if n < 1 {
+		n = 1
+	} else if n > 1024 {
+		n = 1024
+	}
+	p.height = (p.maxY - p.minY) / float64(n)
+	if p.height == 0 {
+		n = 1
+	}
+	p.slabs = make([][]edge, n)
+	for _, e := range edges {
+		lo, hi := p.slab(math.Min(e.y1, e.y2)), p.slab(math.Max(e.y1, e.y2))
+		for i := lo; i <= hi; i++ {
+			p.slabs[i] = append(p.slabs[i], e)
+		}
+	}
+	return p, nil
+}
+
+func (p *polygonIndex) slab(y float64) int {
+	if p.height == 0 {
+		return 0
+	}
+	i := int((y - p.minY) / p.height)
+	if i >= len(p.slabs) {
+		i = len(p.slabs) - 1
+	}
+	return i
+}
+
+func (p *polygonIndex) contains(x, y float64) bool {
+	if x < p.minX || x > p.maxX || y < p.minY || y > p.maxY {
+		return false
+	}
+	in := false
+	for _, e := range p.slabs[p.slab(y)] {
+		if (e.y1 > y) != (e.y2 > y) && x < (e.x2-e.x1)*(y-e.y1)/(e.y2-e.y1)+e.x1 {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Polygon is the union of the GeoJSON polygons indexed, the longitude and latitude are treated as plane coordinates
+type Polygon struct {
+	parts []*polygonIndex
+}
+
+// Contains tells whether the point is within any of the polygons
+func (p *Polygon) Contains(lat, lng float64) bool {
+	for _, part := range p.parts {
+		if part.contains(lng, lat) {
+			return true
+		}
+	}
+	return false
+}
+
+// geoJSON is the GeoJSON object of the types supported
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Features    []*geoJSON      `json:"features"`
+}
+
+// ParsePolygon parses and indexes the GeoJSON of Polygon, MultiPolygon, Feature or FeatureCollection
+func ParsePolygon(data []byte) (*Polygon, error) {
+	var g geoJSON
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("%w: GeoJSON: %v", ErrIllegalFormat, err)
+	}
+	p := &Polygon{}
+	if err := p.add(&g); err != nil {
+		return nil, err
+	}
+	if len(p.parts) == 0 {
+		return nil, fmt.Errorf("%w: GeoJSON has no polygon", ErrIllegalFormat)
+	}
+	return p, nil
+}
+
+func (p *Polygon) add(g *geoJSON) error {
+	switch g.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := unmarshalPositions(g.Coordinates, &rings); err != nil {
+			return err
+		}
+		return p.addRings(rings)
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := unmarshalPositions(g.Coordinates, &polygons); err != nil {
+			return err
+		}
+		for _, rings := range polygons {
+			if err := p.addRings(rings); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Feature":
+		if g.Geometry == nil {
+			return fmt.Errorf("%w: GeoJSON Feature without geometry", ErrIllegalFormat)
+		}
+		return p.add(g.Geometry)
+	case "FeatureCollection":
+		for _, f := range g.Features {
+			if err := p.add(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: GeoJSON type %q is not supported", ErrIllegalFormat, g.Type)
+}
+
+func (p *Polygon) addRings(rings [][][]float64) error {
+	if len(rings) == 0 {
+		return fmt.Errorf("%w: polygon has no ring", ErrIllegalFormat)
+	}
+	for _, ring := range rings {
+		for _, pos := range ring {
+			if len(pos) < 2 {
+				return fmt.Errorf("%w: position needs longitude and latitude", ErrIllegalFormat)
+			}
+		}
+	}
+	index, err := newPolygonIndex(rings)
+	if err != nil {
+		return err
+	}
+	p.parts = append(p.parts, index)
+	return nil
+}
+
+func unmarshalPositions(data json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: GeoJSON coordinates: %v", ErrIllegalFormat, err)
+	}
+	return nil
+}
+
+// toPolygon converts the GeoJSON of string or the object decoded from JSON, the strings are cached
+func toPolygon(p interface{}) (*Polygon, error) {
+	switch v := p.(type) {
+	case *Polygon:
+		return v, nil
+	case string:
+		if len(v) > MaxPatternSize {
+			return nil, fmt.Errorf("%w: GeoJSON of %d bytes exceeds %d, pass the Polygon parsed instead", ErrPatternTooLarge, len(v), MaxPatternSize)
+		}
+		polygon, err := patterns.get("geojson:"+v, func() (interface{}, error) {
+			return ParsePolygon([]byte(v))
+		})
+		if err != nil {
+			return nil, err
+		}
+		return polygon.(*Polygon), nil
+	}
+	if k := reflect.ValueOf(p).Kind(); k != reflect.Map && k != reflect.Struct {
+		return nil, fmt.Errorf("polygon should be GeoJSON, but got %v", p)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: GeoJSON: %v", ErrIllegalFormat, err)
+	}
+	return ParsePolygon(data)
+}
+
+// preparePolygon parses and indexes the GeoJSON which is the third param
+func preparePolygon(i int, param interface{}) (interface{}, bool, error) {
+	if i != 2 {
+		return nil, false, nil
+	}
+	p, err := toPolygon(param)
+	if err != nil {
+		return nil, false, err
+	}
+	return p, true, nil
+}
+
+// InPolygon returns whether the point is within the GeoJSON polygons
+func InPolygon(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 3 {
+		return false, fmt.Errorf("in_polygon: need 3 params, but got %d", l)
+	}
+	vs, err := coordinates(FuncInPolygon, params[:2], 2)
+	if err != nil {
+		return false, err
+	}
+	p, err := toPolygon(params[2])
+	if err != nil {
+		return false, fmt.Errorf("in_polygon: %w", err)
+	}
+	return p.Contains(vs[0], vs[1]), nil
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the max characters of geohash, which is accurate to centimeters
+const MaxGeohashPrecision = 12
+
+// encodeGeohash returns the geohash of the point with the precision of characters
+func encodeGeohash(lat, lng float64, precision int) string {
+	latRange, lngRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	b := make([]byte, precision)
+	even := true
+	for i := range b {
+		var c int
+		for bit := 4; bit >= 0; bit-- {
+			r, v := &latRange, lat
+			if even {
+				r, v = &lngRange, lng
+			}
+			if mid := (r[0] + r[1]) / 2; v >= mid {
+				c |= 1 << uint(bit)
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+		b[i] = geohashAlphabet[c]
+	}
+	return string(b)
+}
+
+// Geohash returns the geohash of the point with the precision of characters
+func Geohash(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 3 {
+		return nil, fmt.Errorf("geohash: need 3 params, but got %d", l)
+	}
+	vs, err := coordinates(FuncGeohash, params[:2], 2)
+	if err != nil {
+		return nil, err
+	}
+	precision, err := toInt64(params[2])
+	if err != nil || precision < 1 || precision > MaxGeohashPrecision {
+		return nil, fmt.Errorf("geohash: precision should be 1 to %d, but got %v", MaxGeohashPrecision, params[2])
+	}
+	return encodeGeohash(vs[0], vs[1], int(precision)), nil
+}
+
+// GeohashPrefix returns whether the geohash of the point starts with the prefix or any of the list of prefixes
+func GeohashPrefix(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 3 {
+		return false, fmt.Errorf("geohash_prefix: need 3 params, but got %d", l)
+	}
+	vs, err := coordinates(FuncGeohashPrefix, params[:2], 2)
+	if err != nil {
+		return false, err
+	}
+	var prefixes []string
+	if s, ok := params[2].(string); ok {
+		prefixes = []string{s}
+	} else if v := reflect.ValueOf(params[2]); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			s, ok := v.Index(i).Interface().(string)
+			if !ok {
+				return false, errors.New("geohash_prefix: prefix should be string")
+			}
+			prefixes = append(prefixes, s)
+		}
+	} else {
+		return false, fmt.Errorf("geohash_prefix: prefix should be string or list of strings, but got %v", params[2])
+	}
+	longest := 0
+	for _, p := range prefixes {
+		p = strings.ToLower(p)
+		if len(p) > MaxGeohashPrecision || strings.Trim(p, geohashAlphabet) != "" {
+			return false, fmt.Errorf("geohash_prefix: %w: geohash %q", ErrIllegalFormat, p)
+		}
+		if len(p) > longest {
+			longest = len(p)
+		}
+	}
+	hash := encodeGeohash(vs[0], vs[1], longest)
+	for _, p := range prefixes {
+		if strings.HasPrefix(hash, strings.ToLower(p)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,189 @@
+package function
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	inputs := []struct {
+		lat1, lng1, lat2, lng2 float64
+		km                     float64
+	}{
+		{31.23, 121.47, 31.23, 121.47, 0},
+		// Shanghai to Beijing
+		{31.2304, 121.4737, 39.9042, 116.4074, 1067.3},
+		// across the antimeridian
+		{0, 179.5, 0, -179.5, 111.2},
+		{90, 0, -90, 0, math.Pi * EarthRadiusKm},
+	}
+	for _, input := range inputs {
+		d, err := DistanceKm(input.lat1, input.lng1, input.lat2, input.lng2)
+		if err != nil || math.Abs(d.(float64)-input.km) > 0.5 {
+			t.Errorf("%v wanna: %v km, got: %v, %v", input, input.km, d, err)
+		}
+	}
+	if r, err := WithinKm(31.3, 121.5, 31.23, 121.47, 50); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := WithinKm(39.9042, 116.4074, 31.23, 121.47, 50); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if _, err := WithinKm(91, 0, 0, 0, 50); !errors.Is(err, ErrDomain) {
+		t.Errorf("wanna: %v, got: %v", ErrDomain, err)
+	}
+	if _, err := DistanceKm(0, 0, 0, "a"); err == nil {
+		t.Errorf("wanna error of string")
+	}
+}
+
+func TestInBBox(t *testing.T) {
+	inputs := []struct {
+		params []interface{}
+		result bool
+	}{
+		{[]interface{}{31.23, 121.47, 30.7, 120.8, 31.9, 122.2}, true},
+		{[]interface{}{32, 121.47, 30.7, 120.8, 31.9, 122.2}, false},
+		{[]interface{}{31.23, 123, 30.7, 120.8, 31.9, 122.2}, false},
+		{[]interface{}{0, 179.9, -10, 170, 10, -170}, true},
+		{[]interface{}{0, -175, -10, 170, 10, -170}, true},
+		{[]interface{}{0, 0, -10, 170, 10, -170}, false},
+	}
+	for _, input := range inputs {
+		if r, err := InBBox(input.params...); err != nil || r != input.result {
+			t.Errorf("%v wanna: %v, got: %v, %v", input.params, input.result, r, err)
+		}
+	}
+}
+
+func TestInPolygon(t *testing.T) {
+	// a square with a square hole, and a triangle
+	geo := `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [
+			[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]],
+			[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]
+		]}},
+		{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [[[[20, 0], [30, 0], [25, 10]]]]}}
+	]}`
+	inputs := []struct {
+		lat, lng float64
+		result   bool
+	}{
+		{1, 1, true},
+		{5, 5, false},
+		{5, 3, true},
+		{11, 5, false},
+		{1, 25, true},
+		{9, 21, false},
+		{-1, 25, false},
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(geo), &obj); err != nil {
+		t.Fatal(err)
+	}
+	for _, polygon := range []interface{}{geo, obj} {
+		for _, input := range inputs {
+			if r, err := InPolygon(input.lat, input.lng, polygon); err != nil || r != input.result {
+				t.Errorf("(%v, %v) wanna: %v, got: %v, %v", input.lat, input.lng, input.result, r, err)
+			}
+		}
+	}
+
+	spec, _ := Describe(FuncInPolygon)
+	p, ok, err := spec.Prepare(2, geo)
+	if !ok || err != nil {
+		t.Fatalf("wanna the polygon indexed, got: %v, %v", ok, err)
+	}
+	if r, err := InPolygon(1, 1, p); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+
+	// the large GeoJSON string is not cached, but the Polygon parsed is accepted
+	large := `{"type": "Polygon", "coordinates": [[[0, 0], [10, 0], [10, 10], [0, 10], [0, 0]]]` + strings.Repeat(" ", MaxPatternSize) + `}`
+	if _, err := InPolygon(1, 1, large); !errors.Is(err, ErrPatternTooLarge) {
+		t.Errorf("wanna: %v, got: %v", ErrPatternTooLarge, err)
+	}
+	if _, _, err := spec.Prepare(2, large); !errors.Is(err, ErrPatternTooLarge) {
+		t.Errorf("wanna: %v, got: %v", ErrPatternTooLarge, err)
+	}
+	parsed, err := ParsePolygon([]byte(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := InPolygon(1, 1, parsed); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+
+	for _, s := range []string{
+		`{"type": "Point", "coordinates": [0, 0]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 1], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1], [1, 1], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 100], [1, 1], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": []}`,
+		`{"type": "Feature"}`,
+		`{"type": "Polygon"`,
+		`{"type": "FeatureCollection", "features": []}`,
+	} {
+		if _, err := ParsePolygon([]byte(s)); !errors.Is(err, ErrIllegalFormat) && !errors.Is(err, ErrDomain) {
+			t.Errorf("%s wanna error, got: %v", s, err)
+		}
+	}
+}
+
+func TestPolygonIndex(t *testing.T) {
+	// a circle of many edges, which are spread into slabs
+	var ring [][]float64
+	for i := 0; i < 1000; i++ {
+		a := 2 * math.Pi * float64(i) / 1000
+		ring = append(ring, []float64{10 * math.Cos(a), 10 * math.Sin(a)})
+	}
+	p, err := newPolygonIndex([][][]float64{ring})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.slabs) != 125 {
+		t.Errorf("wanna 125 slabs, got: %d", len(p.slabs))
+	}
+	for y := -9.5; y <= 9.5; y += 0.5 {
+		x := math.Sqrt(100 - y*y)
+		if !p.contains(x-0.1, y) || !p.contains(-x+0.1, y) || p.contains(x+0.1, y) || p.contains(-x-0.1, y) {
+			t.Errorf("wrong result around the edges at y of %v", y)
+		}
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	inputs := []struct {
+		lat, lng  float64
+		precision int
+		hash      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{31.2304, 121.4737, 6, "wtw3sj"},
+		{-33.8688, 151.2093, 5, "r3gx2"},
+		{0, 0, 1, "s"},
+	}
+	for _, input := range inputs {
+		if h, err := Geohash(input.lat, input.lng, input.precision); err != nil || h != input.hash {
+			t.Errorf("%v wanna: %s, got: %v, %v", input, input.hash, h, err)
+		}
+	}
+	if _, err := Geohash(0, 0, 13); err == nil {
+		t.Errorf("wanna error of precision")
+	}
+	if r, err := GeohashPrefix(31.2304, 121.4737, "WTW3"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := GeohashPrefix(31.2304, 121.4737, []interface{}{"wtw6", "wtw3s"}); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := GeohashPrefix(31.2304, 121.4737, []string{"wtw6"}); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if _, err := GeohashPrefix(31.2304, 121.4737, "wtwa"); !errors.Is(err, ErrIllegalFormat) {
+		t.Errorf("wanna: %v, got: %v", ErrIllegalFormat, err)
+	}
+}
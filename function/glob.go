@@ -0,0 +1,293 @@
+package function
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// FuncGlob is the function/operator keyword glob
+	FuncGlob = "glob"
+	// FuncLike is the function/operator keyword like
+	FuncLike = "like"
+	// FuncILike is the function/operator keyword ilike
+	FuncILike = "ilike"
+)
+
+func init() {
+	for _, f := range []StringFunc{
+		{
+			Name: FuncGlob, Params: []Type{StringType}, Return: BoolType,
+			Doc:     "whether the string matches the shell pattern, where * and ? match any characters except /, [...] matches the class and ** matches across /",
+			Example: `(glob hostname "web-*.prod.*")`,
+		},
+		{
+			Name: FuncLike, Params: []Type{StringType}, Return: BoolType,
+			Doc:     "whether the string matches the pattern of SQL like, where % matches any characters and _ matches one character",
+			Example: `(like sku "ABC-%")`,
+		},
+		{
+			Name: FuncILike, Params: []Type{StringType}, Return: BoolType,
+			Doc:     "same as like but case-insensitive",
+			Example: `(ilike name "%smith")`,
+		},
+	} {
+		name := f.Name
+		f.Prepare = func(i int, param interface{}) (interface{}, bool, error) {
+			if i != 1 {
+				return nil, false, nil
+			}
+			switch p := param.(type) {
+			case *wildcard:
+				return p, true, nil
+			case string:
+				w, err := compileWildcard(name, p)
+				if err != nil {
+					return nil, false, err
+				}
+				return w, true, nil
+			}
+			return nil, false, nil
+		}
+		f.Fn = func(s string, args []interface{}) (interface{}, error) {
+			return args[0].(*wildcard).match(s), nil
+		}
+		MustRegistFuncer(f.Name, f)
+	}
+}
+
+const (
+	wildcardExact uint8 = iota + 1
+	wildcardPrefix
+	wildcardSuffix
+	wildcardContains
+	wildcardRegexp
+)
+
+// wildcard is the pattern of glob or like compiled. The patterns made of a literal and the leading or trailing wildcards
+// are matched by comparing strings, and the others are translated into regular expressions
+type wildcard struct {
+	mode    uint8
+	literal string
+	// slash tells whether the wildcards of the simple patterns cannot match /
+	slash bool
+	// fold tells whether it's case-insensitive, the pattern is in lower case then
+	fold bool
+	re   *regexp.Regexp
+}
+
+func (w *wildcard) match(s string) bool {
+	if w.fold {
+		s = strings.ToLower(s)
+	}
+	switch w.mode {
+	case wildcardExact:
+		return s == w.literal
+	case wildcardPrefix:
+		return strings.HasPrefix(s, w.literal) && !(w.slash && strings.Contains(s[len(w.literal):], "/"))
+	case wildcardSuffix:
+		return strings.HasSuffix(s, w.literal) && !(w.slash && strings.Contains(s[:len(s)-len(w.literal)], "/"))
+	case wildcardContains:
+		return strings.Contains(s, w.literal) && !(w.slash && strings.Contains(s, "/"))
+	}
+	return w.re.MatchString(s)
+}
+
+// wildcardToken is either a literal or a wildcard in the form of regular expression
+type wildcardToken struct {
+	literal string
+	re      string
+	// star tells whether the wildcard matches any characters, and anySlash tells whether / is included
+	star, anySlash bool
+}
+
+// compileWildcard compiles the pattern of glob, like or ilike
+func compileWildcard(name, pattern string) (*wildcard, error) {
+	if len(pattern) > MaxPatternSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrPatternTooLarge, len(pattern), MaxPatternSize)
+	}
+	w, err := patterns.get(name+":"+pattern, func() (interface{}, error) {
+		fold := name == FuncILike
+		if fold {
+			pattern = strings.ToLower(pattern)
+		}
+		var tokens []wildcardToken
+		var err error
+		if name == FuncGlob {
+			tokens, err = globTokens(pattern)
+		} else {
+			tokens, err = likeTokens(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		w := simpleWildcard(tokens)
+		if w == nil {
+			var b strings.Builder
+			b.WriteString("(?s)^")
+			for _, t := range tokens {
+				if t.re != "" {
+					b.WriteString(t.re)
+				} else {
+					b.WriteString(regexp.QuoteMeta(t.literal))
+				}
+			}
+			b.WriteString("$")
+			re, err := regexp.Compile(b.String())
+			if err != nil {
+				return nil, err
+			}
+			w = &wildcard{mode: wildcardRegexp, re: re}
+		}
+		w.fold = fold
+		return w, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return w.(*wildcard), nil
+}
+
+// simpleWildcard returns the wildcard matched by comparing strings, nil if the pattern is not simple
+func simpleWildcard(tokens []wildcardToken) *wildcard {
+	isLiteral := func(t wildcardToken) bool { return t.re == "" }
+	switch len(tokens) {
+	case 0:
+		return &wildcard{mode: wildcardExact}
+	case 1:
+		if t := tokens[0]; isLiteral(t) {
+			return &wildcard{mode: wildcardExact, literal: t.literal}
+		} else if t.star {
+			return &wildcard{mode: wildcardPrefix, slash: !t.anySlash}
+		}
+	case 2:
+		if first, last := tokens[0], tokens[1]; isLiteral(first) && last.star {
+			return &wildcard{mode: wildcardPrefix, literal: first.literal, slash: !last.anySlash}
+		} else if first.star && isLiteral(last) {
+			return &wildcard{mode: wildcardSuffix, literal: last.literal, slash: !first.anySlash}
+		}
+	case 3:
+		first, middle, last := tokens[0], tokens[1], tokens[2]
+		if first.star && isLiteral(middle) && last.star && first.anySlash == last.anySlash &&
+			(first.anySlash || !strings.Contains(middle.literal, "/")) {
+			return &wildcard{mode: wildcardContains, literal: middle.literal, slash: !first.anySlash}
+		}
+	}
+	return nil
+}
+
+// appendLiteral appends the literal to tokens, merging with the last literal
+func appendLiteral(tokens []wildcardToken, s string) []wildcardToken {
+	if n := len(tokens); n > 0 && tokens[n-1].re == "" {
+		tokens[n-1].literal += s
+		return tokens
+	}
+	return append(tokens, wildcardToken{literal: s})
+}
+
+// globTokens splits the shell pattern, backslash escapes the next character
+func globTokens(pattern string) ([]wildcardToken, error) {
+	var tokens []wildcardToken
+	for i := 0; i < len(pattern); {
+		switch c := pattern[i]; c {
+		case '*':
+			j := i
+			for j < len(pattern) && pattern[j] == '*' {
+				j++
+			}
+			if j-i == 1 {
+				tokens = append(tokens, wildcardToken{re: "[^/]*", star: true})
+			} else if j < len(pattern) && pattern[j] == '/' && (i == 0 || pattern[i-1] == '/') {
+				// **/ matches zero or more directories
+				tokens = append(tokens, wildcardToken{re: "(?:.*/)?"})
+				j++
+			} else {
+				tokens = append(tokens, wildcardToken{re: ".*", star: true, anySlash: true})
+			}
+			i = j
+		case '?':
+			tokens = append(tokens, wildcardToken{re: "[^/]"})
+			i++
+		case '[':
+			re, n, err := globClass(pattern[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, wildcardToken{re: re})
+			i += n
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrIllegalFormat)
+			}
+			_, n := utf8.DecodeRuneInString(pattern[i+1:])
+			tokens = appendLiteral(tokens, pattern[i+1:i+1+n])
+			i += 1 + n
+		default:
+			_, n := utf8.DecodeRuneInString(pattern[i:])
+			tokens = appendLiteral(tokens, pattern[i:i+n])
+			i += n
+		}
+	}
+	return tokens, nil
+}
+
+// globClass translates the class like [a-z] or [!0-9] at the beginning of pattern, and returns the bytes consumed.
+// The negated class never matches / as ? and *
+func globClass(pattern string) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		b.WriteString("^/")
+		i++
+	}
+	for start := i; i < len(pattern); {
+		c := pattern[i]
+		if c == ']' && i > start {
+			b.WriteString("]")
+			return b.String(), i + 1, nil
+		}
+		if c == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		r, n := utf8.DecodeRuneInString(pattern[i:])
+		if r == '-' && c != '\\' {
+			b.WriteString("-")
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		i += n
+	}
+	return "", 0, fmt.Errorf("%w: unterminated [", ErrIllegalFormat)
+}
+
+// likeTokens splits the pattern of SQL like, backslash escapes the next character
+func likeTokens(pattern string) ([]wildcardToken, error) {
+	var tokens []wildcardToken
+	for i := 0; i < len(pattern); {
+		switch c := pattern[i]; c {
+		case '%':
+			if n := len(tokens); n == 0 || !tokens[n-1].star {
+				tokens = append(tokens, wildcardToken{re: ".*", star: true, anySlash: true})
+			}
+			i++
+		case '_':
+			tokens = append(tokens, wildcardToken{re: "."})
+			i++
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrIllegalFormat)
+			}
+			_, n := utf8.DecodeRuneInString(pattern[i+1:])
+			tokens = appendLiteral(tokens, pattern[i+1:i+1+n])
+			i += 1 + n
+		default:
+			_, n := utf8.DecodeRuneInString(pattern[i:])
+			tokens = appendLiteral(tokens, pattern[i:i+n])
+			i += n
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,97 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWildcard(t *testing.T) {
+	inputs := []struct {
+		name, pattern string
+		mode          uint8
+		matched       []string
+		unmatched     []string
+	}{
+		{FuncGlob, "web-*.prod.*", wildcardRegexp, []string{"web-1.prod.sh", "web-.prod."}, []string{"web-1.prod", "db-1.prod.sh", "web-1/a.prod.sh"}},
+		{FuncGlob, "web-*", wildcardPrefix, []string{"web-", "web-1"}, []string{"web", "web-1/2", "api-web-1"}},
+		{FuncGlob, "*.log", wildcardSuffix, []string{".log", "a.log"}, []string{"a/b.log", "a.log.1"}},
+		{FuncGlob, "*prod*", wildcardContains, []string{"prod", "web-prod-1"}, []string{"web/prod", "pro"}},
+		{FuncGlob, "*a/b*", wildcardRegexp, []string{"xa/by"}, []string{"x/a/b"}},
+		{FuncGlob, "src/**", wildcardPrefix, []string{"src/", "src/a/b.go"}, []string{"src", "lib/a.go"}},
+		{FuncGlob, "**/*.go", wildcardRegexp, []string{"a.go", "src/a.go", "src/a/b.go"}, []string{"a.go/b", "a.c"}},
+		{FuncGlob, "src/**/test/*.go", wildcardRegexp, []string{"src/test/a.go", "src/a/b/test/c.go"}, []string{"src/test/a/b.go"}},
+		{FuncGlob, "a**b", wildcardRegexp, []string{"ab", "a/x/b"}, []string{"a/x/c"}},
+		{FuncGlob, "file-?.txt", wildcardRegexp, []string{"file-1.txt", "file-你.txt"}, []string{"file-.txt", "file-12.txt", "file-/.txt"}},
+		{FuncGlob, "[a-c]x[!0-9]", wildcardRegexp, []string{"axb", "cx-"}, []string{"dxb", "ax1"}},
+		{FuncGlob, "a[!b]c", wildcardRegexp, []string{"acc", "a-c"}, []string{"abc", "a/c"}},
+		{FuncGlob, "a[^b-]c", wildcardRegexp, []string{"acc"}, []string{"abc", "a-c", "a/c"}},
+		{FuncGlob, "[]x]", wildcardRegexp, []string{"]", "x"}, []string{"y"}},
+		{FuncGlob, `\*.\[x\]`, wildcardExact, []string{"*.[x]"}, []string{"a.[x]"}},
+		{FuncGlob, "a.b", wildcardExact, []string{"a.b"}, []string{"axb"}},
+		{FuncLike, "ABC-%", wildcardPrefix, []string{"ABC-", "ABC-1/2"}, []string{"abc-1", "AB"}},
+		{FuncLike, "%-XL", wildcardSuffix, []string{"shirt-XL"}, []string{"shirt-xl"}},
+		{FuncLike, "%%red%", wildcardContains, []string{"dark red\ncar"}, []string{"blue"}},
+		{FuncLike, "A_C%", wildcardRegexp, []string{"ABC", "A\nCD", "A你C"}, []string{"AC", "ABBC"}},
+		{FuncLike, `100\%`, wildcardExact, []string{"100%"}, []string{"1000"}},
+		{FuncLike, "a.*", wildcardExact, []string{"a.*"}, []string{"ab"}},
+		{FuncLike, "", wildcardExact, []string{""}, []string{"a"}},
+		{FuncILike, "%SMITH", wildcardSuffix, []string{"John Smith", "JOHN SMITH"}, []string{"Smithson"}},
+		{FuncILike, "straße_", wildcardRegexp, []string{"STRAßEN"}, []string{"STRASSE"}},
+	}
+	for _, input := range inputs {
+		w, err := compileWildcard(input.name, input.pattern)
+		if err != nil {
+			t.Errorf("%s %q: %v", input.name, input.pattern, err)
+			continue
+		}
+		if w.mode != input.mode {
+			t.Errorf("%s %q wanna mode: %d, got: %d", input.name, input.pattern, input.mode, w.mode)
+		}
+		for _, s := range input.matched {
+			if !w.match(s) {
+				t.Errorf("%s %q wanna %q matched", input.name, input.pattern, s)
+			}
+		}
+		for _, s := range input.unmatched {
+			if w.match(s) {
+				t.Errorf("%s %q wanna %q unmatched", input.name, input.pattern, s)
+			}
+		}
+	}
+
+	for _, input := range []struct{ name, pattern string }{
+		{FuncGlob, "[a-"},
+		{FuncGlob, `a\`},
+		{FuncLike, `a\`},
+	} {
+		if _, err := compileWildcard(input.name, input.pattern); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%s %q wanna: %v, got: %v", input.name, input.pattern, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestGlobFuncs(t *testing.T) {
+	fn, err := Get(FuncGlob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := fn("web-1.prod.sh", "web-*.prod.*"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := fn([]interface{}{"web-1", "db-1"}, "web-*"); err != nil || fmt.Sprint(r) != "[true false]" {
+		t.Errorf("wanna: [true false], got: %v, %v", r, err)
+	}
+	spec, _ := Describe(FuncLike)
+	w, ok, err := spec.Prepare(1, "ABC-%")
+	if !ok || err != nil {
+		t.Fatalf("wanna the pattern compiled, got: %v, %v", ok, err)
+	}
+	fn, _ = Get(FuncLike)
+	if r, err := fn("ABC-1", w); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if _, err := fn("ABC-1", 1); err == nil {
+		t.Errorf("wanna error of the pattern of number")
+	}
+}
@@ -0,0 +1,326 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// FuncTypeIP is the function/operator keyword t_ip
+	FuncTypeIP = "t_ip"
+	// FuncInCIDR is the function/operator keyword in_cidr
+	FuncInCIDR = "in_cidr"
+	// FuncPrefixSet is the function/operator keyword prefix_set
+	FuncPrefixSet = "prefix_set"
+	// FuncIPVersion is the function/operator keyword ip_version
+	FuncIPVersion = "ip_version"
+	// FuncIsPrivate is the function/operator keyword is_private
+	FuncIsPrivate = "is_private"
+)
+
+func init() {
+	MustRegistFuncer(FuncTypeIP, StringFunc{
+		Name: FuncTypeIP, Return: IPType,
+		Doc:     "converts strings to IP addresses of IPv4 or IPv6, which can be compared and IPv4 is lower than IPv6. The IPv4-mapped IPv6 addresses are kept as IPv6",
+		Example: `(t_ip "192.168.1.1")`,
+		Fn: func(s string, args []interface{}) (interface{}, error) {
+			return ParseIP(s)
+		},
+	})
+	MustRegist(FuncInCIDR, InCIDR)
+	MustRegist(FuncPrefixSet, BuildPrefixSet)
+	MustRegist(FuncIPVersion, IPVersion)
+	MustRegist(FuncIsPrivate, IsPrivate)
+
+	describe(FuncSpec{
+		Signature: Signature{Params: []Type{AnyType, AnyType}, Return: BoolType},
+		Doc: "whether the IP address, which can be a string, is within the CIDR or the list of CIDRs like 10.0.0.0/8, " +
+			"the list of literals is compiled into the prefix set once when parsing",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in_cidr client_ip ("10.0.0.0/8" "192.168.0.0/16"))`},
+		Prepare:       preparePrefixSet,
+	}, FuncInCIDR)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType}, Variadic: true, Return: AnyType},
+		Doc:           "compiles the CIDRs or the lists of them into the prefix set, which is looked up by binary search in in_cidr",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in_cidr client_ip (prefix_set "10.0.0.0/8" "fc00::/7"))`},
+	}, FuncPrefixSet)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType}, Return: NumberType},
+		Doc:           "4 or 6 by the version of the IP address, which can be a string",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(eq (ip_version client_ip) 6)`},
+	}, FuncIPVersion)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType}, Return: BoolType},
+		Doc:           "whether the IP address, which can be a string, is private by RFC 1918 or RFC 4193",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(is_private client_ip)`},
+	}, FuncIsPrivate)
+}
+
+// IP is the IP address of IPv4 or IPv6, which is comparable with ==.
+// The IPv4-mapped IPv6 address like ::ffff:10.0.0.1 is kept as IPv6, which is not equal to 10.0.0.1
+type IP struct {
+	addr netip.Addr
+}
+
+// ParseIP parses the IP address of IPv4 or IPv6 without zone
+func ParseIP(s string) (IP, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return IP{}, fmt.Errorf("%w: IP address %q", ErrIllegalFormat, s)
+	}
+	return IP{addr: addr}, nil
+}
+
+// Addr returns the netip.Addr of ip
+func (ip IP) Addr() netip.Addr {
+	return ip.addr
+}
+
+// Is4 tells whether it's IPv4
+func (ip IP) Is4() bool {
+	return ip.addr.Is4()
+}
+
+// BitLen returns 32 for IPv4 and 128 for IPv6
+func (ip IP) BitLen() int {
+	return ip.addr.BitLen()
+}
+
+func (ip IP) String() string {
+	return ip.addr.String()
+}
+
+// Compare returns -1, 0 or 1 if ip is lower than, equal to or higher than other, IPv4 is lower than IPv6
+func (ip IP) Compare(other IP) int {
+	return ip.addr.Compare(other.addr)
+}
+
+// IsPrivate tells whether it's private by RFC 1918 or RFC 4193, the IPv4-mapped IPv6 address is checked by its IPv4 address
+func (ip IP) IsPrivate() bool {
+	return ip.addr.IsPrivate()
+}
+
+// ipRange is the range of addresses including both ends, which are of the same version
+type ipRange struct {
+	first, last netip.Addr
+}
+
+// PrefixSet is the set of CIDR prefixes compiled, which is the sorted ranges without overlapping, looked up by binary search
+type PrefixSet struct {
+	ranges []ipRange
+}
+
+// NewPrefixSet compiles the CIDRs like 10.0.0.0/8 or fc00::/7, the address without prefix length is the prefix of itself.
+// The IPv4-mapped IPv6 prefix like ::ffff:10.0.0.0/104 only contains the IPv4-mapped IPv6 addresses
+func NewPrefixSet(cidrs ...string) (*PrefixSet, error) {
+	s := &PrefixSet{}
+	for _, c := range cidrs {
+		r, err := parseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		s.ranges = append(s.ranges, r)
+	}
+	s.ranges = mergeRanges(s.ranges)
+	return s, nil
+}
+
+// MustPrefixSet is the same as NewPrefixSet but panics on error
+func MustPrefixSet(cidrs ...string) *PrefixSet {
+	s, err := NewPrefixSet(cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func parseCIDR(s string) (ipRange, error) {
+	if !strings.Contains(s, "/") {
+		ip, err := ParseIP(s)
+		if err != nil {
+			return ipRange{}, err
+		}
+		return ipRange{ip.addr, ip.addr}, nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("%w: CIDR %q", ErrIllegalFormat, s)
+	}
+	prefix = prefix.Masked()
+	return ipRange{prefix.Addr(), lastAddr(prefix)}, nil
+}
+
+// lastAddr returns the highest address of the masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().As16()
+	// the IPv4 address takes the last 4 bytes
+	bits := prefix.Bits() + 128 - prefix.Addr().BitLen()
+	for i := range b {
+		if n := bits - i*8; n <= 0 {
+			b[i] = 0xff
+		} else if n < 8 {
+			b[i] |= 0xff >> uint(n)
+		}
+	}
+	addr := netip.AddrFrom16(b)
+	if prefix.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+// mergeRanges sorts the ranges and merges the overlapping or adjacent ones, IPv4 ranges are lower than IPv6 ones
+func mergeRanges(rs []ipRange) []ipRange {
+	sort.Slice(rs, func(i, j int) bool { return rs[i].first.Less(rs[j].first) })
+	merged := rs[:0]
+	for _, r := range rs {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			// next is invalid if last is the highest address of its version, which is not adjacent to the next version
+			next := last.last.Next()
+			if r.first.Compare(last.last) <= 0 || next.IsValid() && r.first.Compare(next) <= 0 {
+				if r.last.Compare(last.last) > 0 {
+					last.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Contains tells whether the IP address is within any of the prefixes
+func (s *PrefixSet) Contains(ip IP) bool {
+	rs := s.ranges
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].last.Compare(ip.addr) >= 0 })
+	return i < len(rs) && rs[i].first.Compare(ip.addr) <= 0
+}
+
+// Len returns the count of the ranges merged
+func (s *PrefixSet) Len() int {
+	return len(s.ranges)
+}
+
+// toIP converts the IP address or the string
+func toIP(p interface{}) (IP, error) {
+	switch v := p.(type) {
+	case IP:
+		return v, nil
+	case string:
+		return ParseIP(v)
+	}
+	return IP{}, fmt.Errorf("%v is not IP address", p)
+}
+
+// toPrefixSet compiles the CIDR or the list of CIDRs, the lists are flattened
+func toPrefixSet(params ...interface{}) (*PrefixSet, error) {
+	if len(params) == 1 {
+		if s, ok := params[0].(*PrefixSet); ok {
+			return s, nil
+		}
+	}
+	var cidrs []string
+	var flatten func(p interface{}) error
+	flatten = func(p interface{}) error {
+		if s, ok := p.(string); ok {
+			cidrs = append(cidrs, s)
+			return nil
+		}
+		v := reflect.ValueOf(p)
+		if k := v.Kind(); k != reflect.Slice && k != reflect.Array {
+			return fmt.Errorf("CIDR should be string, but got %v", p)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := flatten(v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, p := range params {
+		if err := flatten(p); err != nil {
+			return nil, err
+		}
+	}
+	return NewPrefixSet(cidrs...)
+}
+
+// preparePrefixSet compiles the CIDRs which are the second param
+func preparePrefixSet(i int, param interface{}) (interface{}, bool, error) {
+	if i != 1 {
+		return nil, false, nil
+	}
+	s, err := toPrefixSet(param)
+	if err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// InCIDR returns whether the IP address is within the CIDR, the list of CIDRs or the PrefixSet
+func InCIDR(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return false, fmt.Errorf("in_cidr: need two params, but got %d", l)
+	}
+	ip, err := toIP(params[0])
+	if err != nil {
+		return false, fmt.Errorf("in_cidr: %w", err)
+	}
+	s, err := toPrefixSet(params[1])
+	if err != nil {
+		return false, fmt.Errorf("in_cidr: %w", err)
+	}
+	return s.Contains(ip), nil
+}
+
+// BuildPrefixSet compiles the CIDRs or the lists of them into PrefixSet
+func BuildPrefixSet(params ...interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, errors.New("prefix_set: need at least one param")
+	}
+	s, err := toPrefixSet(params...)
+	if err != nil {
+		return nil, fmt.Errorf("prefix_set: %w", err)
+	}
+	return s, nil
+}
+
+// IPVersion returns 4 or 6 by the version of the IP address
+func IPVersion(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("ip_version: need one param, but got %d", l)
+	}
+	ip, err := toIP(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("ip_version: %w", err)
+	}
+	if ip.Is4() {
+		return 4.0, nil
+	}
+	return 6.0, nil
+}
+
+// IsPrivate returns whether the IP address is private
+func IsPrivate(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return false, fmt.Errorf("is_private: need one param, but got %d", l)
+	}
+	ip, err := toIP(params[0])
+	if err != nil {
+		return false, fmt.Errorf("is_private: %w", err)
+	}
+	return ip.IsPrivate(), nil
+}
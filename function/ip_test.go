@@ -0,0 +1,159 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseIP(t *testing.T) {
+	inputs := []struct {
+		s, str string
+		v4     bool
+	}{
+		{"192.168.1.1", "192.168.1.1", true},
+		{"::ffff:192.168.1.1", "::ffff:192.168.1.1", false},
+		{"2001:DB8::1", "2001:db8::1", false},
+		{"::", "::", false},
+	}
+	for _, input := range inputs {
+		ip, err := ParseIP(input.s)
+		if err != nil || ip.String() != input.str || ip.Is4() != input.v4 {
+			t.Errorf("%q wanna: %s v4: %v, got: %v v4: %v, %v", input.s, input.str, input.v4, ip, ip.Is4(), err)
+		}
+	}
+	for _, s := range []string{"", "1.2.3", "256.1.1.1", "fe80::1%eth0", "10.0.0.0/8"} {
+		if _, err := ParseIP(s); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", s, ErrIllegalFormat, err)
+		}
+	}
+
+	ordered := []string{"0.0.0.0", "9.255.255.255", "10.0.0.0", "255.255.255.255", "::", "::1", "2001:db8::", "ffff::"}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := ParseIP(ordered[i])
+			b, _ := ParseIP(ordered[j])
+			wanna := 0
+			if i < j {
+				wanna = -1
+			} else if i > j {
+				wanna = 1
+			}
+			if c := a.Compare(b); c != wanna {
+				t.Errorf("compare %s with %s wanna: %d, got: %d", a, b, wanna, c)
+			}
+		}
+	}
+}
+
+func TestPrefixSet(t *testing.T) {
+	s, err := NewPrefixSet("10.0.0.0/8", "10.1.0.0/16", "11.0.0.0/8", "192.168.0.1", "0.0.0.0/0", "2001:db8::/32", "::ffff:172.16.0.0/108", "::/0", "ffff::/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// all of IPv4, while the IPv6 ranges are merged into ::/0
+	if s.Len() != 2 {
+		t.Errorf("wanna 2 ranges merged, got: %d", s.Len())
+	}
+
+	s = MustPrefixSet("10.0.0.0/8", "11.0.0.0/8", "192.168.0.1", "2001:db8::/32", "::ffff:172.16.0.0/108", "ffff::/16", "127.0.0.1/32")
+	if s.Len() != 6 {
+		t.Errorf("wanna 6 ranges merged, got: %d, %+v", s.Len(), s)
+	}
+	for ip, wanna := range map[string]bool{
+		"9.255.255.255":                          false,
+		"10.0.0.0":                               true,
+		"11.255.255.255":                         true,
+		"12.0.0.0":                               false,
+		"192.168.0.1":                            true,
+		"192.168.0.2":                            false,
+		"172.16.0.1":                             false,
+		"::ffff:172.16.0.1":                      true,
+		"::ffff:172.32.0.0":                      false,
+		"127.0.0.1":                              true,
+		"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff": true,
+		"2001:db9::":                             false,
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff": true,
+		"::a00:1": false,
+	} {
+		addr, _ := ParseIP(ip)
+		if s.Contains(addr) != wanna {
+			t.Errorf("%s wanna: %v, got: %v", ip, wanna, !wanna)
+		}
+	}
+	for _, c := range []string{"10.0.0.0/33", "10.0.0/8", "fe80::/10%eth0", "abc"} {
+		if _, err := NewPrefixSet(c); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", c, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestIPFuncs(t *testing.T) {
+	ip := func(s string) IP {
+		v, err := ParseIP(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncTypeIP, []interface{}{[]interface{}{"10.0.0.1", "::1"}}, []interface{}{ip("10.0.0.1"), ip("::1")}, false},
+		{FuncTypeIP, []interface{}{"10.0.0"}, nil, true},
+		{FuncInCIDR, []interface{}{"10.1.2.3", []interface{}{"10.0.0.0/8", "192.168.0.0/16"}}, true, false},
+		{FuncInCIDR, []interface{}{ip("192.169.0.1"), []string{"10.0.0.0/8", "192.168.0.0/16"}}, false, false},
+		{FuncInCIDR, []interface{}{"2001:db8::1", "2001:db8::/32"}, true, false},
+		{FuncInCIDR, []interface{}{"10.1.2.3", MustPrefixSet("10.0.0.0/8")}, true, false},
+		{FuncInCIDR, []interface{}{"10.1.2.3", []interface{}{"10.0.0.0/8", 1}}, nil, true},
+		{FuncInCIDR, []interface{}{1, "10.0.0.0/8"}, nil, true},
+		{FuncIPVersion, []interface{}{"10.0.0.1"}, 4.0, false},
+		{FuncIPVersion, []interface{}{ip("fe80::1")}, 6.0, false},
+		{FuncIPVersion, []interface{}{"::ffff:1.2.3.4"}, 6.0, false},
+		{FuncInCIDR, []interface{}{"::ffff:10.1.2.3", "10.0.0.0/8"}, false, false},
+		{FuncIsPrivate, []interface{}{"172.31.255.255"}, true, false},
+		{FuncIsPrivate, []interface{}{"172.32.0.0"}, false, false},
+		{FuncIsPrivate, []interface{}{"fd12:3456::1"}, true, false},
+		{FuncIsPrivate, []interface{}{"::ffff:192.168.0.1"}, true, false},
+		{FuncIsPrivate, []interface{}{"8.8.8.8"}, false, false},
+		{FuncIsPrivate, []interface{}{"localhost"}, nil, true},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+
+	if r, err := (Equal{}).Eval(ip("::ffff:10.0.0.1"), ip("10.0.0.1")); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if r, err := In(ip("::1"), []interface{}{ip("10.0.0.1"), ip("::1")}); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := Between(ip("10.0.0.5"), ip("10.0.0.1"), ip("10.0.0.9")); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	spec, _ := Describe(FuncInCIDR)
+	if s, ok, err := spec.Prepare(1, []interface{}{"10.0.0.0/8"}); !ok || err != nil || fmt.Sprintf("%T", s) != "*function.PrefixSet" {
+		t.Errorf("wanna the CIDRs compiled, got: %v, %v, %v", s, ok, err)
+	}
+}
@@ -0,0 +1,223 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const (
+	// FuncMap is the function/operator keyword map, which the literal {k v} is parsed into
+	FuncMap = "map"
+	// FuncGet is the function/operator keyword get
+	FuncGet = "get"
+	// FuncHasKey is the function/operator keyword has_key
+	FuncHasKey = "has_key"
+	// FuncKeys is the function/operator keyword keys
+	FuncKeys = "keys"
+	// FuncValues is the function/operator keyword values
+	FuncValues = "values"
+	// FuncMerge is the function/operator keyword merge
+	FuncMerge = "merge"
+)
+
+// ErrKeyNotFound means the key is not within the map and no default is given
+var ErrKeyNotFound = errors.New("key not found")
+
+func init() {
+	MustRegist(FuncMap, Map)
+	MustRegist(FuncGet, MapGet)
+	MustRegist(FuncHasKey, HasKey)
+	MustRegist(FuncKeys, Keys)
+	MustRegist(FuncValues, Values)
+	MustRegist(FuncMerge, Merge)
+
+	describe(FuncSpec{
+		MaxArgs:       -1,
+		Signature:     Signature{Params: []Type{AnyType}, Variadic: true, Return: MapType},
+		Doc:           "the map of the keys and values in pairs, the keys must be strings. It's written as {k v} in short",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`{"CN" 1.2 "US" 1.0}`, `(map "CN" 1.2 "US" 1.0)`},
+		Prepare: func(i int, param interface{}) (interface{}, bool, error) {
+			if _, ok := param.(string); i%2 == 0 && !ok {
+				return nil, false, fmt.Errorf("%w: key must be string, but got %v", ErrParamsInvalid, param)
+			}
+			return nil, false, nil
+		},
+	}, FuncMap)
+	describe(FuncSpec{
+		MinArgs:       2,
+		MaxArgs:       3,
+		Signature:     Signature{Params: []Type{MapType, StringType, AnyType}, Return: AnyType},
+		Doc:           "the value of the key within the map, or the default if the key is not found",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(get {"CN" 1.2 "US" 1.0} country 1.0)`},
+	}, FuncGet)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{MapType, StringType}, Return: BoolType},
+		Doc:           "whether the map has the key",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(has_key labels "env")`},
+	}, FuncHasKey)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{MapType}, Return: ListOf(StringType)},
+		Doc:           "the keys of the map in ascending order",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(overlap (keys labels) ("env" "team"))`},
+	}, FuncKeys)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{MapType}, Return: ListOf(AnyType)},
+		Doc:           "the values of the map in the ascending order of the keys",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(max (values scores))`},
+	}, FuncValues)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{MapType}, Variadic: true, Return: MapType},
+		Doc:           "the maps merged into a new one, the latter value wins if the keys are the same",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(get (merge {"CN" 1.2} rates) country 1.0)`},
+	}, FuncMerge)
+}
+
+// Map returns the map of the params in pairs of key and value
+func Map(params ...interface{}) (interface{}, error) {
+	if l := len(params); l%2 != 0 {
+		return nil, fmt.Errorf("map: need params in pairs, but got %d", l)
+	}
+	m := make(map[string]interface{}, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		k, ok := params[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("map: key must be string, but got %v", params[i])
+		}
+		m[k] = params[i+1]
+	}
+	return m, nil
+}
+
+// MapGet returns the value of the key within the map, the third param is the default if the key is not found
+func MapGet(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 && l != 3 {
+		return nil, fmt.Errorf("get: need two or three params, but got %d", l)
+	}
+	m, key, err := mapKey(FuncGet, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := m[key]; ok {
+		return v, nil
+	}
+	if len(params) == 3 {
+		return params[2], nil
+	}
+	return nil, fmt.Errorf("get: %w: %q", ErrKeyNotFound, key)
+}
+
+// HasKey returns whether the map has the key
+func HasKey(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("has_key: need two params, but got %d", l)
+	}
+	m, key, err := mapKey(FuncHasKey, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	_, ok := m[key]
+	return ok, nil
+}
+
+// Keys returns the keys of the map in ascending order
+func Keys(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("keys: need one param, but got %d", l)
+	}
+	m, err := toMap(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("keys: %v", err)
+	}
+	keys := sortedKeys(m)
+	res := make([]interface{}, len(keys))
+	for i, k := range keys {
+		res[i] = k
+	}
+	return res, nil
+}
+
+// Values returns the values of the map in the ascending order of the keys
+func Values(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("values: need one param, but got %d", l)
+	}
+	m, err := toMap(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("values: %v", err)
+	}
+	keys := sortedKeys(m)
+	res := make([]interface{}, len(keys))
+	for i, k := range keys {
+		res[i] = m[k]
+	}
+	return res, nil
+}
+
+// Merge returns a new map of all the params, the latter value wins if the keys are the same
+func Merge(params ...interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, errors.New("merge: need at least one param, but got 0")
+	}
+	res := make(map[string]interface{})
+	for _, p := range params {
+		m, err := toMap(p)
+		if err != nil {
+			return nil, fmt.Errorf("merge: %v", err)
+		}
+		for k, v := range m {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+func mapKey(name string, m, key interface{}) (map[string]interface{}, string, error) {
+	res, err := toMap(m)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", name, err)
+	}
+	k, ok := key.(string)
+	if !ok {
+		return nil, "", fmt.Errorf("%s: key must be string, but got %v", name, key)
+	}
+	return res, k, nil
+}
+
+// toMap converts the map of string keys into map[string]interface{}, the values are left as they are
+func toMap(v interface{}) (map[string]interface{}, error) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, nil
+	}
+	t := reflect.ValueOf(v)
+	if t.Kind() != reflect.Map || t.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("need map of string keys, but got %T", v)
+	}
+	m := make(map[string]interface{}, t.Len())
+	for it := t.MapRange(); it.Next(); {
+		m[it.Key().String()] = it.Value().Interface()
+	}
+	return m, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package function
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMapFuncs(t *testing.T) {
+	rates := map[string]interface{}{"CN": 1.2, "US": 1.0}
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncMap, []interface{}{"CN", 1.2, "US", 1.0}, rates, false},
+		{FuncMap, nil, map[string]interface{}{}, false},
+		{FuncMap, []interface{}{"CN", 1.2, "US"}, nil, true},
+		{FuncMap, []interface{}{1.0, 1.2}, nil, true},
+		{FuncGet, []interface{}{rates, "CN"}, 1.2, false},
+		{FuncGet, []interface{}{rates, "JP", 0.8}, 0.8, false},
+		{FuncGet, []interface{}{map[string]int{"CN": 1}, "CN"}, 1, false},
+		{FuncGet, []interface{}{rates, "JP"}, nil, true},
+		{FuncGet, []interface{}{rates, 1, 0.8}, nil, true},
+		{FuncGet, []interface{}{map[int]int{1: 1}, "1"}, nil, true},
+		{FuncGet, []interface{}{[]interface{}{"CN"}, "CN"}, nil, true},
+		{FuncHasKey, []interface{}{rates, "US"}, true, false},
+		{FuncHasKey, []interface{}{map[string]string{"env": ""}, "env"}, true, false},
+		{FuncHasKey, []interface{}{rates, "JP"}, false, false},
+		{FuncHasKey, []interface{}{nil, "JP"}, nil, true},
+		{FuncKeys, []interface{}{map[string]int{"b": 1, "c": 2, "a": 3}}, []interface{}{"a", "b", "c"}, false},
+		{FuncKeys, []interface{}{map[string]int{}}, []interface{}{}, false},
+		{FuncValues, []interface{}{map[string]int{"b": 1, "c": 2, "a": 3}}, []interface{}{3, 1, 2}, false},
+		{FuncValues, []interface{}{"abc"}, nil, true},
+		{FuncMerge, []interface{}{rates, map[string]float64{"US": 1.1, "JP": 0.8}}, map[string]interface{}{"CN": 1.2, "US": 1.1, "JP": 0.8}, false},
+		{FuncMerge, []interface{}{rates, 1}, nil, true},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+
+	if _, err := MapGet(rates, "JP"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("wanna: %v, got: %v", ErrKeyNotFound, err)
+	}
+	// merge returns a new map
+	if _, err := Merge(rates, map[string]interface{}{"JP": 0.8}); err != nil || len(rates) != 2 {
+		t.Errorf("wanna the map untouched, got: %v, %v", rates, err)
+	}
+	spec, _ := Describe(FuncMap)
+	if _, _, err := spec.Prepare(2, 1.0); err == nil {
+		t.Errorf("wanna error of the number key")
+	}
+	if _, _, err := spec.Prepare(1, 1.0); err != nil {
+		t.Errorf("wanna no error of the value, got: %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+const (
+	// FuncAbs is the function/operator keyword abs
+	FuncAbs = "abs"
+	// FuncMin is the function/operator keyword min
+	FuncMin = "min"
+	// FuncMax is the function/operator keyword max
+	FuncMax = "max"
+	// FuncRound is the function/operator keyword round
+	FuncRound = "round"
+	// FuncFloor is the function/operator keyword floor
+	FuncFloor = "floor"
+	// FuncCeil is the function/operator keyword ceil
+	FuncCeil = "ceil"
+	// FuncPow is the function/operator keyword pow
+	FuncPow = "pow"
+	// FuncSqrt is the function/operator keyword sqrt
+	FuncSqrt = "sqrt"
+	// FuncLog is the function/operator keyword log
+	FuncLog = "log"
+	// FuncExp is the function/operator keyword exp
+	FuncExp = "exp"
+	// FuncClamp is the function/operator keyword clamp
+	FuncClamp = "clamp"
+	// FuncSign is the function/operator keyword sign
+	FuncSign = "sign"
+	// FuncSum is the function/operator keyword sum
+	FuncSum = "sum"
+	// FuncAvg is the function/operator keyword avg
+	FuncAvg = "avg"
+	// FuncMedian is the function/operator keyword median
+	FuncMedian = "median"
+)
+
+// ErrDomain means the param is out of the domain of the math function, e.g. sqrt of negative number
+var ErrDomain = errors.New("domain error")
+
+func init() {
+	MustRegist(FuncAbs, Abs)
+	MustRegist(FuncMin, Min)
+	MustRegist(FuncMax, Max)
+	MustRegist(FuncRound, Round)
+	MustRegist(FuncFloor, Floor)
+	MustRegist(FuncCeil, Ceil)
+	MustRegist(FuncPow, Pow)
+	MustRegist(FuncSqrt, Sqrt)
+	MustRegist(FuncLog, Log)
+	MustRegist(FuncExp, Exp)
+	MustRegist(FuncClamp, Clamp)
+	MustRegist(FuncSign, Sign)
+	MustRegist(FuncSum, Sum)
+	MustRegist(FuncAvg, Avg)
+	MustRegist(FuncMedian, Median)
+
+	unary := Signature{Params: []Type{NumberType}, Return: NumberType}
+	for _, v := range []struct {
+		name, doc, example string
+	}{
+		{FuncAbs, "absolute value", `(abs (- score base))`},
+		{FuncFloor, "the greatest integer less than or equal to the param", `(floor score)`},
+		{FuncCeil, "the least integer greater than or equal to the param", `(ceil score)`},
+		{FuncSqrt, "square root, the param must not be negative", `(sqrt area)`},
+		{FuncExp, "e raised to the param", `(exp rate)`},
+		{FuncSign, "-1, 0 or 1 by the sign of the param", `(sign balance)`},
+	} {
+		describe(FuncSpec{Signature: unary, Doc: v.doc, Pure: true, Deterministic: true, Examples: []string{v.example}}, v.name)
+	}
+	describe(FuncSpec{
+		MinArgs:       1,
+		MaxArgs:       2,
+		Signature:     Signature{Params: []Type{NumberType, NumberType}, Return: NumberType},
+		Doc:           "the param rounded half away from zero to the digits after the decimal point, 0 by default, negative digits round to tens and so on",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(round price 2)`},
+	}, FuncRound)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType}, Return: NumberType},
+		Doc:           "the first param raised to the second one",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(pow 1.05 years)`},
+	}, FuncPow)
+	describe(FuncSpec{
+		MinArgs:       1,
+		MaxArgs:       2,
+		Signature:     Signature{Params: []Type{NumberType, NumberType}, Return: NumberType},
+		Doc:           "logarithm of the first param to the base of the second one, e by default",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(log amount)`, `(log amount 10)`},
+	}, FuncLog)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType, NumberType, NumberType}, Return: NumberType},
+		Doc:           "the first param limited within the range of the second and the third param",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(clamp (* score 1.2) 0 100)`},
+	}, FuncClamp)
+	for _, v := range []struct {
+		name, doc, example string
+	}{
+		{FuncMin, "minimum", `(min (* score 1.2) 100)`},
+		{FuncMax, "maximum", `(max scores)`},
+		{FuncSum, "sum, 0 if there is none", `(sum scores)`},
+		{FuncAvg, "arithmetic mean", `(avg scores)`},
+		{FuncMedian, "median, the mean of the middle two if the count is even", `(median scores)`},
+	} {
+		describe(FuncSpec{
+			Signature:     Signature{Params: []Type{AnyType}, Variadic: true, Return: NumberType},
+			Doc:           v.doc + " of the params, in which the lists are flattened",
+			Pure:          true,
+			Deterministic: true,
+			Examples:      []string{v.example},
+		}, v.name)
+	}
+}
+
+// unary converts the only param into float64, and checks the result of fn
+func unary(name string, params []interface{}, fn func(float64) (float64, error)) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("%s: need one param, but got %d", name, l)
+	}
+	x, err := toNumber(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	res, err := fn(x)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return res, nil
+}
+
+// finite returns ErrDomain if the result is not finite while the params are
+func finite(res float64, params ...float64) (float64, error) {
+	if !math.IsNaN(res) && !math.IsInf(res, 0) {
+		return res, nil
+	}
+	for _, p := range params {
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			return res, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %v", ErrDomain, params)
+}
+
+// Abs returns the absolute value of the param
+func Abs(params ...interface{}) (interface{}, error) {
+	return unary(FuncAbs, params, func(x float64) (float64, error) {
+		return math.Abs(x), nil
+	})
+}
+
+// Floor returns the greatest integer less than or equal to the param
+func Floor(params ...interface{}) (interface{}, error) {
+	return unary(FuncFloor, params, func(x float64) (float64, error) {
+		return math.Floor(x), nil
+	})
+}
+
+// Ceil returns the least integer greater than or equal to the param
+func Ceil(params ...interface{}) (interface{}, error) {
+	return unary(FuncCeil, params, func(x float64) (float64, error) {
+		return math.Ceil(x), nil
+	})
+}
+
+// Sqrt returns the square root of the param, ErrDomain is returned if it's negative
+func Sqrt(params ...interface{}) (interface{}, error) {
+	return unary(FuncSqrt, params, func(x float64) (float64, error) {
+		return finite(math.Sqrt(x), x)
+	})
+}
+
+// Exp returns e raised to the param, ErrDomain is returned if it overflows
+func Exp(params ...interface{}) (interface{}, error) {
+	return unary(FuncExp, params, func(x float64) (float64, error) {
+		return finite(math.Exp(x), x)
+	})
+}
+
+// Sign returns -1, 0 or 1 by the sign of the param
+func Sign(params ...interface{}) (interface{}, error) {
+	return unary(FuncSign, params, func(x float64) (float64, error) {
+		switch {
+		case x > 0:
+			return 1, nil
+		case x < 0:
+			return -1, nil
+		}
+		return x, nil
+	})
+}
+
+// numberPair converts the first param and the optional second param into float64, def is used if the second one is omitted
+func numberPair(name string, params []interface{}, optional bool, def float64) (x, y float64, err error) {
+	if l := len(params); optional && l != 1 && l != 2 {
+		return 0, 0, fmt.Errorf("%s: need one or two params, but got %d", name, l)
+	} else if !optional && l != 2 {
+		return 0, 0, fmt.Errorf("%s: need two params, but got %d", name, l)
+	}
+	if x, err = toNumber(params[0]); err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", name, err)
+	}
+	y = def
+	if len(params) == 2 {
+		if y, err = toNumber(params[1]); err != nil {
+			return 0, 0, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return x, y, nil
+}
+
+// Round returns the first param rounded half away from zero to the digits of the optional second param after the decimal point
+func Round(params ...interface{}) (interface{}, error) {
+	x, digits, err := numberPair(FuncRound, params, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	if digits != math.Trunc(digits) {
+		return nil, fmt.Errorf("round: digits should be integer, but got %v", digits)
+	}
+	if digits == 0 {
+		return math.Round(x), nil
+	}
+	p := math.Pow10(int(digits))
+	if math.IsInf(x*p, 0) {
+		return x, nil
+	}
+	return math.Round(x*p) / p, nil
+}
+
+// Pow returns the first param raised to the second one, ErrDomain is returned if the result is not a finite number
+func Pow(params ...interface{}) (interface{}, error) {
+	x, y, err := numberPair(FuncPow, params, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	res, err := finite(math.Pow(x, y), x, y)
+	if err != nil {
+		return nil, fmt.Errorf("pow: %w", err)
+	}
+	return res, nil
+}
+
+// Log returns the logarithm of the first param to the base of the optional second param, which is e by default.
+// ErrDomain is returned if the param is not positive, or the base is not positive or is 1
+func Log(params ...interface{}) (interface{}, error) {
+	x, base, err := numberPair(FuncLog, params, true, math.E)
+	if err != nil {
+		return nil, err
+	}
+	if x <= 0 || base <= 0 || base == 1 {
+		return nil, fmt.Errorf("log: %w: %v", ErrDomain, params)
+	}
+	if len(params) == 1 {
+		return math.Log(x), nil
+	}
+	return math.Log(x) / math.Log(base), nil
+}
+
+// Clamp returns the first param limited within the range of the second and the third param
+func Clamp(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 3 {
+		return nil, fmt.Errorf("clamp: need three params, but got %d", l)
+	}
+	var vs [3]float64
+	for i, p := range params {
+		v, err := toNumber(p)
+		if err != nil {
+			return nil, fmt.Errorf("clamp: %v", err)
+		}
+		vs[i] = v
+	}
+	x, lo, hi := vs[0], vs[1], vs[2]
+	if lo > hi {
+		return nil, fmt.Errorf("clamp: lower bound %v is greater than upper bound %v", lo, hi)
+	}
+	return math.Max(lo, math.Min(x, hi)), nil
+}
+
+// numbers flattens the params and the lists within them into float64
+func numbers(name string, params []interface{}) ([]float64, error) {
+	var res []float64
+	for _, p := range params {
+		if v := reflect.ValueOf(p); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			ps := make([]interface{}, v.Len())
+			for j := range ps {
+				ps[j] = v.Index(j).Interface()
+			}
+			vs, err := numbers(name, ps)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, vs...)
+			continue
+		}
+		v, err := toNumber(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// aggregate applies fn on the numbers flattened from params, which must not be empty
+func aggregate(name string, params []interface{}, fn func([]float64) float64) (interface{}, error) {
+	vs, err := numbers(name, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("%s: need at least one number", name)
+	}
+	return fn(vs), nil
+}
+
+// Min returns the minimum of the params, in which the lists are flattened
+func Min(params ...interface{}) (interface{}, error) {
+	return aggregate(FuncMin, params, func(vs []float64) float64 {
+		res := vs[0]
+		for _, v := range vs[1:] {
+			res = math.Min(res, v)
+		}
+		return res
+	})
+}
+
+// Max returns the maximum of the params, in which the lists are flattened
+func Max(params ...interface{}) (interface{}, error) {
+	return aggregate(FuncMax, params, func(vs []float64) float64 {
+		res := vs[0]
+		for _, v := range vs[1:] {
+			res = math.Max(res, v)
+		}
+		return res
+	})
+}
+
+// Sum returns the sum of the params, in which the lists are flattened. It's 0 if there is none
+func Sum(params ...interface{}) (interface{}, error) {
+	vs, err := numbers(FuncSum, params)
+	if err != nil {
+		return nil, err
+	}
+	var res float64
+	for _, v := range vs {
+		res += v
+	}
+	return res, nil
+}
+
+// Avg returns the arithmetic mean of the params, in which the lists are flattened
+func Avg(params ...interface{}) (interface{}, error) {
+	return aggregate(FuncAvg, params, func(vs []float64) float64 {
+		var sum float64
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / float64(len(vs))
+	})
+}
+
+// Median returns the median of the params, in which the lists are flattened. It's the mean of the middle two if the count is even
+func Median(params ...interface{}) (interface{}, error) {
+	return aggregate(FuncMedian, params, func(vs []float64) float64 {
+		sort.Float64s(vs)
+		mid := len(vs) / 2
+		if len(vs)%2 == 1 {
+			return vs[mid]
+		}
+		return (vs[mid-1] + vs[mid]) / 2
+	})
+}
+
+// toNumber is the same as toFloat64 but returns error rather than panics on nil
+func toNumber(p interface{}) (float64, error) {
+	if p == nil {
+		return 0, errors.New("cannot convert nil to float64")
+	}
+	return toFloat64(p)
+}
@@ -0,0 +1,98 @@
+package function
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    error
+	}{
+		{FuncAbs, []interface{}{-1.5}, 1.5, nil},
+		{FuncAbs, []interface{}{int64(-3)}, 3.0, nil},
+		{FuncAbs, []interface{}{"1"}, nil, errors.New("")},
+		{FuncAbs, []interface{}{nil}, nil, errors.New("")},
+		{FuncAbs, []interface{}{1, 2}, nil, errors.New("")},
+		{FuncFloor, []interface{}{-1.5}, -2.0, nil},
+		{FuncCeil, []interface{}{1.2}, 2.0, nil},
+		{FuncSign, []interface{}{-0.1}, -1.0, nil},
+		{FuncSign, []interface{}{0}, 0.0, nil},
+		{FuncSign, []interface{}{uint8(7)}, 1.0, nil},
+		{FuncSqrt, []interface{}{16}, 4.0, nil},
+		{FuncSqrt, []interface{}{-1}, nil, ErrDomain},
+		{FuncExp, []interface{}{0}, 1.0, nil},
+		{FuncExp, []interface{}{1000}, nil, ErrDomain},
+		{FuncRound, []interface{}{2.5}, 3.0, nil},
+		{FuncRound, []interface{}{-2.5}, -3.0, nil},
+		{FuncRound, []interface{}{3.14159, 2}, 3.14, nil},
+		{FuncRound, []interface{}{1234.5, -2}, 1200.0, nil},
+		{FuncRound, []interface{}{1.5, 0.5}, nil, errors.New("")},
+		{FuncPow, []interface{}{2, 10}, 1024.0, nil},
+		{FuncPow, []interface{}{4, 0.5}, 2.0, nil},
+		{FuncPow, []interface{}{-8, 1.0 / 3}, nil, ErrDomain},
+		{FuncPow, []interface{}{0, -1}, nil, ErrDomain},
+		{FuncPow, []interface{}{2}, nil, errors.New("")},
+		{FuncLog, []interface{}{math.E}, 1.0, nil},
+		{FuncLog, []interface{}{1000, 10}, 3.0, nil},
+		{FuncLog, []interface{}{0}, nil, ErrDomain},
+		{FuncLog, []interface{}{8, 1}, nil, ErrDomain},
+		{FuncLog, []interface{}{8, -2}, nil, ErrDomain},
+		{FuncClamp, []interface{}{120, 0, 100}, 100.0, nil},
+		{FuncClamp, []interface{}{-5, 0, 100}, 0.0, nil},
+		{FuncClamp, []interface{}{42, 0, 100}, 42.0, nil},
+		{FuncClamp, []interface{}{42, 100, 0}, nil, errors.New("")},
+		{FuncMin, []interface{}{3, 1.5, 2}, 1.5, nil},
+		{FuncMax, []interface{}{[]interface{}{3, 9, 2}}, 9.0, nil},
+		{FuncMax, []interface{}{[]int{3, 9}, 10, []interface{}{[]float64{11}}}, 11.0, nil},
+		{FuncMax, []interface{}{[]interface{}{}}, nil, errors.New("")},
+		{FuncMax, []interface{}{[]interface{}{1, "a"}}, nil, errors.New("")},
+		{FuncSum, []interface{}{[]interface{}{1, 2, 3}, 4}, 10.0, nil},
+		{FuncSum, []interface{}{[]interface{}{}}, 0.0, nil},
+		{FuncAvg, []interface{}{[]interface{}{1, 2, 3, 6}}, 3.0, nil},
+		{FuncAvg, []interface{}{}, nil, errors.New("")},
+		{FuncMedian, []interface{}{[]interface{}{5, 1, 3}}, 3.0, nil},
+		{FuncMedian, []interface{}{[]interface{}{4, 1, 3, 2}}, 2.5, nil},
+		{FuncMedian, []interface{}{[]interface{}{nil}}, nil, errors.New("")},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err != nil {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			} else if errors.Is(input.err, ErrDomain) && !errors.Is(err, ErrDomain) {
+				t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, ErrDomain, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if f, ok := res.(float64); ok && math.Abs(f-input.result.(float64)) < 1e-9 {
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+}
+
+func TestMedianKeepsOrder(t *testing.T) {
+	scores := []interface{}{3, 1, 2}
+	if _, err := Median(scores); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scores, []interface{}{3, 1, 2}) {
+		t.Errorf("wanna the params untouched, got: %v", scores)
+	}
+}
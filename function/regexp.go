@@ -0,0 +1,124 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+const (
+	// FuncMatches is the function/operator keyword matches
+	FuncMatches = "matches"
+	// FuncRegexExtract is the function/operator keyword regex_extract
+	FuncRegexExtract = "regex_extract"
+)
+
+// ErrPatternTooLarge means the pattern is larger than MaxPatternSize
+var ErrPatternTooLarge = errors.New("pattern too large")
+
+var (
+	// MaxPatternSize is the max bytes of pattern, which protects against the pathological patterns from users
+	MaxPatternSize = 1 << 10
+	// PatternCacheSize is the max count of patterns compiled which are cached, for the patterns not known until evaluation
+	PatternCacheSize = 1 << 10
+)
+
+func init() {
+	for _, f := range []StringFunc{
+		{
+			Name: FuncMatches, Params: []Type{StringType}, Return: BoolType,
+			Doc: "whether the string matches the regular expression of RE2 syntax", Example: `(matches email ".*@corp\.com$")`,
+			Prepare: prepareRegexp,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return args[0].(*regexp.Regexp).MatchString(s), nil
+			},
+		},
+		{
+			Name: FuncRegexExtract, Params: []Type{StringType, NumberType}, Optional: 1, Return: StringType,
+			Doc: "the submatch of the group, which is the whole match by default, within the string, empty if not matched", Example: `(regex_extract path "^/v(\d+)/" 1)`,
+			Prepare: prepareRegexp,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				re := args[0].(*regexp.Regexp)
+				var group int64
+				if len(args) == 2 {
+					var err error
+					if group, err = toInt64(args[1]); err != nil {
+						return nil, err
+					}
+				}
+				if group < 0 || group > int64(re.NumSubexp()) {
+					return nil, fmt.Errorf("group %d is out of range", group)
+				}
+				m := re.FindStringSubmatch(s)
+				if m == nil {
+					return "", nil
+				}
+				return m[group], nil
+			},
+		},
+	} {
+		MustRegistFuncer(f.Name, f)
+	}
+}
+
+// prepareRegexp compiles the pattern which is the second param
+func prepareRegexp(i int, param interface{}) (interface{}, bool, error) {
+	if i != 1 {
+		return nil, false, nil
+	}
+	switch p := param.(type) {
+	case *regexp.Regexp:
+		return p, true, nil
+	case string:
+		re, err := compileRegexp(p)
+		if err != nil {
+			return nil, false, err
+		}
+		return re, true, nil
+	}
+	return nil, false, nil
+}
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > MaxPatternSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrPatternTooLarge, len(pattern), MaxPatternSize)
+	}
+	re, err := patterns.get("regexp:"+pattern, func() (interface{}, error) {
+		return regexp.Compile(pattern)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return re.(*regexp.Regexp), nil
+}
+
+// patterns caches the patterns compiled
+var patterns = &patternCache{m: make(map[string]interface{})}
+
+// patternCache is the cache of patterns compiled, which is cleared once the count exceeds PatternCacheSize
+type patternCache struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+// get returns the pattern compiled by key, compile is called if it's missing. The errors are not cached
+func (c *patternCache) get(key string, compile func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	v, ok := c.m[key]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.m) >= PatternCacheSize {
+		c.m = make(map[string]interface{})
+	}
+	c.m[key] = v
+	c.mu.Unlock()
+	return v, nil
+}
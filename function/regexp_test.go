@@ -0,0 +1,99 @@
+package function
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncMatches, []interface{}{"a@corp.com", `.*@corp\.com$`}, true, false},
+		{FuncMatches, []interface{}{"a@corp.com.cn", `.*@corp\.com$`}, false, false},
+		{FuncMatches, []interface{}{"a@corp.com", regexp.MustCompile(`@corp`)}, true, false},
+		{FuncMatches, []interface{}{[]interface{}{"a@corp.com", "b@mail.com"}, `@corp`}, []interface{}{true, false}, false},
+		{FuncMatches, []interface{}{"你好", `^\p{Han}+$`}, true, false},
+		{FuncMatches, []interface{}{"a", `(`}, nil, true},
+		{FuncMatches, []interface{}{"a", 1}, nil, true},
+		{FuncMatches, []interface{}{1, "a"}, nil, true},
+		{FuncRegexExtract, []interface{}{"/v2/users", `^/v(\d+)/`, 1}, "2", false},
+		{FuncRegexExtract, []interface{}{"/v2/users", `^/v(\d+)/`}, "/v2/", false},
+		{FuncRegexExtract, []interface{}{"/users", `^/v(\d+)/`, 1}, "", false},
+		{FuncRegexExtract, []interface{}{[]string{"/v1/a", "/v3/b"}, `^/v(\d+)/`, 1}, []interface{}{"1", "3"}, false},
+		{FuncRegexExtract, []interface{}{"/v2/users", `^/v(\d+)/`, 2}, nil, true},
+		{FuncRegexExtract, []interface{}{"/v2/users", `^/v(\d+)/`, "1"}, nil, true},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+}
+
+func TestPrepareRegexp(t *testing.T) {
+	spec, err := Describe(FuncMatches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := spec.Prepare(0, "a"); ok {
+		t.Errorf("wanna the first param not prepared")
+	}
+	re, ok, err := spec.Prepare(1, "^a")
+	if !ok || err != nil || re.(*regexp.Regexp).String() != "^a" {
+		t.Errorf("wanna the pattern compiled, got: %v, %v, %v", re, ok, err)
+	}
+	if again, ok, _ := spec.Prepare(1, re); !ok || again != re {
+		t.Errorf("wanna the pattern compiled kept, got: %v, %v", again, ok)
+	}
+	if _, _, err := spec.Prepare(1, strings.Repeat("a", MaxPatternSize+1)); !errors.Is(err, ErrPatternTooLarge) {
+		t.Errorf("wanna: %v, got: %v", ErrPatternTooLarge, err)
+	}
+}
+
+func TestPatternCache(t *testing.T) {
+	c := &patternCache{m: make(map[string]interface{})}
+	compiled := 0
+	compile := func() (interface{}, error) {
+		compiled++
+		return compiled, nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := c.get("a", compile); err != nil || v != 1 {
+			t.Errorf("wanna the cached 1, got: %v, %v", v, err)
+		}
+	}
+	if _, err := c.get("b", func() (interface{}, error) { return nil, errors.New("illegal") }); err == nil {
+		t.Errorf("wanna error")
+	}
+	if _, ok := c.m["b"]; ok {
+		t.Errorf("wanna the error not cached")
+	}
+	for i := 0; i < PatternCacheSize*2; i++ {
+		c.get(strings.Repeat("x", i), compile)
+		if len(c.m) > PatternCacheSize {
+			t.Fatalf("wanna at most %d patterns cached, got: %d", PatternCacheSize, len(c.m))
+		}
+	}
+}
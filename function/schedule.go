@@ -0,0 +1,493 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FuncInWindow is the function/operator keyword in_window
+	FuncInWindow = "in_window"
+	// FuncCronMatch is the function/operator keyword cron_match
+	FuncCronMatch = "cron_match"
+	// FuncIsHoliday is the function/operator keyword is_holiday
+	FuncIsHoliday = "is_holiday"
+)
+
+func init() {
+	MustRegist(FuncInWindow, InWindow)
+	MustRegist(FuncCronMatch, CronMatch)
+	MustRegist(FuncIsHoliday, IsHoliday)
+
+	describe(FuncSpec{
+		MinArgs:   2,
+		MaxArgs:   4,
+		Signature: Signature{Params: []Type{TimeType, StringType, StringType, StringType}, Return: BoolType},
+		Doc: "whether the time is within the weekly window like Mon-Fri 09:00-18:00, in the zone of the optional third param. " +
+			"The days can be listed like Sat,Sun and are every day if omitted, the time ranges can be listed like 09:00-12:00,13:00-18:00, " +
+			"more windows are separated by ; and the range like 22:00-06:00 crosses midnight. " +
+			"The days of holiday within the calendar of the optional fourth param are excluded",
+		Pure: true,
+		// the calendar may be registered again at any time
+		Deterministic: false,
+		Examples:      []string{`(in_window (now) "Mon-Fri 09:00-18:00" "Asia/Tokyo")`, `(in_window (now) "Mon-Fri 09:00-12:00,13:00-18:00; Sat 10:00-14:00" "Asia/Tokyo" "jp")`},
+		Prepare: prepareSchedule(func(s string) (interface{}, error) {
+			return compileWindows(s)
+		}),
+	}, FuncInWindow)
+	describe(FuncSpec{
+		MinArgs:   2,
+		MaxArgs:   3,
+		Signature: Signature{Params: []Type{TimeType, StringType, StringType}, Return: BoolType},
+		Doc: "whether the time matches the cron expression of minute, hour, day of month, month and day of week, in the zone of the optional third param. " +
+			"Each field supports *, lists, ranges, steps like */15 and the names like Jan or Mon",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(cron_match (now) "*/15 9-17 * * 1-5" "Asia/Tokyo")`},
+		Prepare: prepareSchedule(func(s string) (interface{}, error) {
+			return compileCron(s)
+		}),
+	}, FuncCronMatch)
+	describe(FuncSpec{
+		MinArgs:   2,
+		MaxArgs:   3,
+		Signature: Signature{Params: []Type{TimeType, StringType, StringType}, Return: BoolType},
+		Doc:       "whether the date of the time, in the zone of the optional third param, is holiday within the calendar registered by RegistCalendar",
+		Pure:      true,
+		// the calendar may be registered again at any time
+		Deterministic: false,
+		Examples:      []string{`(is_holiday (now) "jp" "Asia/Tokyo")`},
+		Prepare:       prepareSchedule(nil),
+	}, FuncIsHoliday)
+}
+
+// Calendar tells whether the date is holiday, the time passed is midnight of the date in UTC
+type Calendar interface {
+	IsHoliday(date time.Time) bool
+}
+
+// Holidays is the Calendar of the listed dates
+type Holidays map[time.Time]bool
+
+// NewHolidays returns the Calendar of the dates with the layout 2006-01-02
+func NewHolidays(dates ...string) (Holidays, error) {
+	h := make(Holidays, len(dates))
+	for _, d := range dates {
+		t, err := time.Parse(DefaultDateFormat, d)
+		if err != nil {
+			return nil, fmt.Errorf("%w: holiday %q", ErrIllegalFormat, d)
+		}
+		h[t] = true
+	}
+	return h, nil
+}
+
+// IsHoliday implements the interface Calendar
+func (h Holidays) IsHoliday(date time.Time) bool {
+	return h[date]
+}
+
+var calendars = struct {
+	sync.RWMutex
+	m map[string]Calendar
+}{m: make(map[string]Calendar)}
+
+// RegistCalendar regists the Calendar with name used by in_window and is_holiday, the existing one is replaced.
+// It's safe to call while evaluating, so the calendars can be refreshed at any time
+func RegistCalendar(name string, c Calendar) {
+	calendars.Lock()
+	calendars.m[name] = c
+	calendars.Unlock()
+}
+
+// GetCalendar gets the Calendar registered with name
+func GetCalendar(name string) (Calendar, error) {
+	calendars.RLock()
+	c, ok := calendars.m[name]
+	calendars.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("calendar %q: %w", name, ErrNotFound)
+	}
+	return c, nil
+}
+
+// isHoliday tells whether the date is holiday within the calendar
+func isHoliday(c Calendar, y int, m time.Month, d int) bool {
+	return c.IsHoliday(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+}
+
+// prepareSchedule returns the Prepare which compiles the second param by compile if it's not nil, and loads the zone which is the third param
+func prepareSchedule(compile func(string) (interface{}, error)) func(int, interface{}) (interface{}, bool, error) {
+	return func(i int, param interface{}) (interface{}, bool, error) {
+		if i == 2 {
+			return prepareZone(1, param)
+		}
+		s, ok := param.(string)
+		if i != 1 || !ok || compile == nil {
+			return nil, false, nil
+		}
+		v, err := compile(s)
+		if err != nil {
+			return nil, false, err
+		}
+		return v, true, nil
+	}
+}
+
+// scheduleParams converts the time, and the optional zone which is the third param
+func scheduleParams(name string, params []interface{}, max int) (time.Time, error) {
+	if l := len(params); l < 2 || l > max {
+		return time.Time{}, fmt.Errorf("%s: need %d to %d params, but got %d", name, 2, max, l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(params) < 3 {
+		return t, nil
+	}
+	loc, ok := params[2].(*time.Location)
+	if !ok {
+		s, ok := params[2].(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%s: the zone should be string, but got %v", name, params[2])
+		}
+		if loc, err = loadLocation(s); err != nil {
+			return time.Time{}, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return t.In(loc), nil
+}
+
+// IsHoliday returns whether the date of the time is holiday within the calendar
+func IsHoliday(params ...interface{}) (interface{}, error) {
+	t, err := scheduleParams(FuncIsHoliday, params, 3)
+	if err != nil {
+		return false, err
+	}
+	name, ok := params[1].(string)
+	if !ok {
+		return false, fmt.Errorf("is_holiday: the calendar should be string, but got %v", params[1])
+	}
+	c, err := GetCalendar(name)
+	if err != nil {
+		return false, fmt.Errorf("is_holiday: %w", err)
+	}
+	y, m, d := t.Date()
+	return isHoliday(c, y, m, d), nil
+}
+
+// minutesOfDay is 24:00, the end of day
+const minutesOfDay = 24 * 60
+
+// window is the weekly window like Mon-Fri 09:00-18:00
+type window struct {
+	days [7]bool
+	// ranges are the minutes since midnight, the end is excluded and the range crosses midnight if the end is not greater than the start
+	ranges [][2]int
+}
+
+// windows is the union of the windows, which is compiled from the spec separated by ;
+type windows []window
+
+// match returns whether the time is within the windows, and the count of days from the day when the window starts, which is 0 or 1
+func (ws windows) match(t time.Time) (bool, int) {
+	wd, m := int(t.Weekday()), t.Hour()*60+t.Minute()
+	prev := (wd + 6) % 7
+	for _, w := range ws {
+		for _, r := range w.ranges {
+			if r[0] < r[1] {
+				if w.days[wd] && m >= r[0] && m < r[1] {
+					return true, 0
+				}
+				continue
+			}
+			if w.days[wd] && m >= r[0] {
+				return true, 0
+			}
+			if w.days[prev] && m < r[1] {
+				return true, 1
+			}
+		}
+	}
+	return false, 0
+}
+
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// compileWindows compiles the spec like "Mon-Fri 09:00-12:00,13:00-18:00; Sat 10:00-14:00"
+func compileWindows(spec string) (windows, error) {
+	if len(spec) > MaxPatternSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrPatternTooLarge, len(spec), MaxPatternSize)
+	}
+	ws, err := patterns.get("window:"+spec, func() (interface{}, error) {
+		var ws windows
+		for _, s := range strings.Split(spec, ";") {
+			w, err := parseWindow(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("%w: window %q: %v", ErrIllegalFormat, spec, err)
+			}
+			ws = append(ws, w)
+		}
+		return ws, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ws.(windows), nil
+}
+
+func parseWindow(s string) (window, error) {
+	var w window
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+	case 2:
+		for _, r := range strings.Split(fields[0], ",") {
+			bounds := strings.Split(r, "-")
+			if len(bounds) > 2 {
+				return w, fmt.Errorf("illegal days %q", r)
+			}
+			var ds [2]int
+			for i, b := range bounds {
+				d, ok := weekdays[strings.ToLower(b)]
+				if !ok {
+					return w, fmt.Errorf("illegal day %q", b)
+				}
+				ds[i] = d
+			}
+			if len(bounds) == 1 {
+				ds[1] = ds[0]
+			}
+			// the range like Fri-Mon wraps around Sunday
+			for d := ds[0]; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == ds[1] {
+					break
+				}
+			}
+		}
+		fields = fields[1:]
+	default:
+		return w, errors.New("should be days followed by time ranges")
+	}
+	for _, r := range strings.Split(fields[0], ",") {
+		bounds := strings.Split(r, "-")
+		if len(bounds) != 2 {
+			return w, fmt.Errorf("illegal time range %q", r)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return w, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return w, err
+		}
+		if start == minutesOfDay {
+			return w, fmt.Errorf("time range %q starts at 24:00", r)
+		}
+		if end == minutesOfDay {
+			end = 0
+		}
+		w.ranges = append(w.ranges, [2]int{start, end})
+	}
+	return w, nil
+}
+
+// parseClock parses the time like 09:00 into minutes since midnight, 24:00 is allowed
+func parseClock(s string) (int, error) {
+	hm := strings.Split(s, ":")
+	if len(hm) != 2 || len(hm[1]) != 2 || len(hm[0]) < 1 || len(hm[0]) > 2 || !isNumeric(hm[0]) || !isNumeric(hm[1]) {
+		return 0, fmt.Errorf("illegal time %q", s)
+	}
+	h, _ := strconv.Atoi(hm[0])
+	m, _ := strconv.Atoi(hm[1])
+	if m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("illegal time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// InWindow returns whether the time is within the weekly windows, except the holidays within the optional calendar
+func InWindow(params ...interface{}) (interface{}, error) {
+	t, err := scheduleParams(FuncInWindow, params, 4)
+	if err != nil {
+		return false, err
+	}
+	ws, ok := params[1].(windows)
+	if !ok {
+		s, ok := params[1].(string)
+		if !ok {
+			return false, fmt.Errorf("in_window: the window should be string, but got %v", params[1])
+		}
+		if ws, err = compileWindows(s); err != nil {
+			return false, fmt.Errorf("in_window: %w", err)
+		}
+	}
+	matched, days := ws.match(t)
+	if !matched || len(params) < 4 {
+		return matched, nil
+	}
+	name, ok := params[3].(string)
+	if !ok {
+		return false, fmt.Errorf("in_window: the calendar should be string, but got %v", params[3])
+	}
+	c, err := GetCalendar(name)
+	if err != nil {
+		return false, fmt.Errorf("in_window: %w", err)
+	}
+	// the holiday is the day when the window starts
+	y, m, d := t.Date()
+	return !isHoliday(c, y, m, d-days), nil
+}
+
+// cronField is the bit set of the values matched
+type cronField uint64
+
+// cron is the cron expression compiled
+type cron struct {
+	minute, hour, dom, month, dow cronField
+	// domStar and dowStar tell whether the day of month and the day of week are *, if neither is, the day matching either of them is matched
+	domStar, dowStar bool
+}
+
+func (c *cron) match(t time.Time) bool {
+	has := func(f cronField, v int) bool { return f&(1<<uint(v)) != 0 }
+	if !has(c.minute, t.Minute()) || !has(c.hour, t.Hour()) || !has(c.month, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if !c.domStar && !c.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+var (
+	cronMonths   = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// compileCron compiles the cron expression of five fields
+func compileCron(expr string) (*cron, error) {
+	if len(expr) > MaxPatternSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrPatternTooLarge, len(expr), MaxPatternSize)
+	}
+	c, err := patterns.get("cron:"+expr, func() (interface{}, error) {
+		fields := strings.Fields(expr)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%w: cron %q should have 5 fields, but got %d", ErrIllegalFormat, expr, len(fields))
+		}
+		c := &cron{domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+		for i, f := range []struct {
+			p        *cronField
+			min, max int
+			names    []string
+		}{
+			{&c.minute, 0, 59, nil},
+			{&c.hour, 0, 23, nil},
+			{&c.dom, 1, 31, nil},
+			{&c.month, 1, 12, cronMonths},
+			{&c.dow, 0, 7, cronWeekdays},
+		} {
+			v, err := parseCronField(fields[i], f.min, f.max, f.names)
+			if err != nil {
+				return nil, fmt.Errorf("%w: cron %q: %v", ErrIllegalFormat, expr, err)
+			}
+			*f.p = v
+		}
+		// 7 is Sunday as well
+		if c.dow&(1<<7) != 0 {
+			c.dow |= 1
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.(*cron), nil
+}
+
+// parseCronField parses the field like 1-5, */15 or 1,3,5
+func parseCronField(s string, min, max int, names []string) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(s, ",") {
+		r, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("illegal step %q", part)
+			}
+			r, step = part[:i], n
+		}
+		lo, hi := min, max
+		if r != "*" {
+			bounds := strings.Split(r, "-")
+			if len(bounds) > 2 {
+				return 0, fmt.Errorf("illegal range %q", part)
+			}
+			var vs [2]int
+			for i, b := range bounds {
+				v, err := cronValue(b, min, max, names)
+				if err != nil {
+					return 0, err
+				}
+				vs[i] = v
+			}
+			lo, hi = vs[0], vs[0]
+			if len(bounds) == 2 {
+				hi = vs[1]
+			} else if step > 1 {
+				// 5/15 is the same as 5-max/15
+				hi = max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("illegal range %q", part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			f |= 1 << uint(v)
+		}
+	}
+	return f, nil
+}
+
+func cronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("illegal value %q, should be within %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+// CronMatch returns whether the time matches the cron expression
+func CronMatch(params ...interface{}) (interface{}, error) {
+	t, err := scheduleParams(FuncCronMatch, params, 3)
+	if err != nil {
+		return false, err
+	}
+	c, ok := params[1].(*cron)
+	if !ok {
+		s, ok := params[1].(string)
+		if !ok {
+			return false, fmt.Errorf("cron_match: the cron expression should be string, but got %v", params[1])
+		}
+		if c, err = compileCron(s); err != nil {
+			return false, fmt.Errorf("cron_match: %w", err)
+		}
+	}
+	return c.match(t), nil
+}
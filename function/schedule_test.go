@@ -0,0 +1,134 @@
+package function
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInWindow(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holidays, err := NewHolidays("2017-09-18", "2017-09-23")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegistCalendar("test-jp", holidays)
+
+	// 2017-09-18 is Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2017, 9, day, hour, minute, 0, 0, tokyo)
+	}
+	inputs := []struct {
+		spec     string
+		calendar string
+		in       []time.Time
+		out      []time.Time
+	}{
+		{"Mon-Fri 09:00-18:00", "", []time.Time{at(18, 9, 0), at(22, 17, 59)}, []time.Time{at(18, 8, 59), at(18, 18, 0), at(23, 12, 0)}},
+		{"Mon-Fri 09:00-18:00", "test-jp", []time.Time{at(19, 9, 0)}, []time.Time{at(18, 12, 0)}},
+		{"mon,WED-fri 09:00-12:00,13:00-18:00", "", []time.Time{at(20, 11, 0), at(18, 13, 0)}, []time.Time{at(19, 11, 0), at(20, 12, 30)}},
+		{"Fri-Mon 10:00-11:00", "", []time.Time{at(22, 10, 0), at(24, 10, 0), at(18, 10, 0)}, []time.Time{at(19, 10, 0)}},
+		{"Fri 22:00-02:00", "", []time.Time{at(22, 23, 0), at(23, 1, 59)}, []time.Time{at(22, 1, 0), at(23, 2, 0), at(23, 23, 0)}},
+		{"Fri 22:00-02:00", "test-jp", []time.Time{at(22, 23, 0), at(23, 1, 0)}, nil},
+		{"Sat 22:00-02:00", "test-jp", nil, []time.Time{at(23, 23, 0), at(24, 1, 0)}},
+		{"Sat 00:00-24:00; Sun 10:00-12:00", "", []time.Time{at(23, 0, 0), at(23, 23, 59), at(24, 11, 0)}, []time.Time{at(24, 0, 0), at(22, 23, 59)}},
+		{"18:00-24:00", "", []time.Time{at(19, 18, 0), at(20, 23, 59)}, []time.Time{at(19, 0, 0)}},
+	}
+	for _, input := range inputs {
+		for _, tm := range input.in {
+			params := []interface{}{tm, input.spec, "Asia/Tokyo"}
+			if input.calendar != "" {
+				params = append(params, input.calendar)
+			}
+			if r, err := InWindow(params...); err != nil || r != true {
+				t.Errorf("%q %v wanna: true, got: %v, %v", input.spec, tm, r, err)
+			}
+		}
+		for _, tm := range input.out {
+			params := []interface{}{tm, input.spec, "Asia/Tokyo"}
+			if input.calendar != "" {
+				params = append(params, input.calendar)
+			}
+			if r, err := InWindow(params...); err != nil || r != false {
+				t.Errorf("%q %v wanna: false, got: %v, %v", input.spec, tm, r, err)
+			}
+		}
+	}
+
+	// 09:30 in Tokyo is 00:30 in UTC, the zone of the time is used if omitted
+	if r, err := InWindow(at(18, 9, 30).UTC(), "Mon 00:00-01:00"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if _, err := InWindow(at(18, 9, 30), "Mon-Fri 09:00-18:00", "Asia/Tokyo", "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("wanna: %v, got: %v", ErrNotFound, err)
+	}
+	for _, spec := range []string{"", "Mon-Fri", "Mon-Fri 9-18", "Funday 09:00-18:00", "Mon 24:00-02:00", "Mon 09:60-10:00", "Mon 09:00-10:00 extra", "Mon-Tue-Wed 09:00-10:00"} {
+		if _, err := compileWindows(spec); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", spec, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	// 2017-09-18 is Monday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2017, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	inputs := []struct {
+		expr string
+		in   []time.Time
+		out  []time.Time
+	}{
+		{"*/15 9-17 * * 1-5", []time.Time{at(9, 18, 9, 0), at(9, 22, 17, 45)}, []time.Time{at(9, 18, 9, 10), at(9, 18, 18, 0), at(9, 23, 9, 0)}},
+		{"0 0 1 jan,JUL *", []time.Time{at(1, 1, 0, 0), at(7, 1, 0, 0)}, []time.Time{at(2, 1, 0, 0)}},
+		{"30 12 * * sun,7", []time.Time{at(9, 17, 12, 30)}, []time.Time{at(9, 18, 12, 30)}},
+		{"5/20 * * * *", []time.Time{at(9, 18, 3, 5), at(9, 18, 3, 45)}, []time.Time{at(9, 18, 3, 0)}},
+		{"0 12-20/4 * * *", []time.Time{at(9, 18, 16, 0), at(9, 18, 20, 0)}, []time.Time{at(9, 18, 14, 0)}},
+		// either the day of month or the day of week
+		{"0 0 13 * fri", []time.Time{at(10, 13, 0, 0), at(9, 22, 0, 0)}, []time.Time{at(9, 21, 0, 0)}},
+	}
+	for _, input := range inputs {
+		for _, tm := range input.in {
+			if r, err := CronMatch(tm, input.expr); err != nil || r != true {
+				t.Errorf("%q %v wanna: true, got: %v, %v", input.expr, tm, r, err)
+			}
+		}
+		for _, tm := range input.out {
+			if r, err := CronMatch(tm, input.expr); err != nil || r != false {
+				t.Errorf("%q %v wanna: false, got: %v, %v", input.expr, tm, r, err)
+			}
+		}
+	}
+
+	// 09:00 in Tokyo is 00:00 in UTC
+	if r, err := CronMatch(at(9, 18, 0, 0), "0 9 * * mon", "Asia/Tokyo"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := compileCron(expr); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", expr, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestIsHoliday(t *testing.T) {
+	holidays, err := NewHolidays("2017-09-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegistCalendar("test-holiday", holidays)
+	// Monday in Tokyo, but still Sunday in UTC
+	tm := time.Date(2017, 9, 17, 20, 0, 0, 0, time.UTC)
+	if r, err := IsHoliday(tm, "test-holiday"); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if r, err := IsHoliday(tm, "test-holiday", "Asia/Tokyo"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if _, err := NewHolidays("2017/09/18"); !errors.Is(err, ErrIllegalFormat) {
+		t.Errorf("wanna: %v, got: %v", ErrIllegalFormat, err)
+	}
+}
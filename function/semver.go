@@ -0,0 +1,499 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FuncTypeSemver is the function/operator keyword t_semver
+	FuncTypeSemver = "t_semver"
+	// FuncSemverSatisfies is the function/operator keyword semver_satisfies
+	FuncSemverSatisfies = "semver_satisfies"
+)
+
+func init() {
+	MustRegistFuncer(FuncTypeSemver, TypeSemver{})
+	MustRegist(FuncSemverSatisfies, SemverSatisfies)
+
+	describe(FuncSpec{
+		Doc:           "converts strings to semantic versions, which are compared by the precedence of semver 2.0, the leading v is allowed and build metadata is ignored",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(t_semver "2.7.1-beta.2")`, `(between (t_semver app_version) (t_semver "2.7.0") (t_semver "3.0.0"))`},
+	}, FuncTypeSemver)
+	describe(FuncSpec{
+		Signature: Signature{Params: []Type{AnyType, StringType}, Return: BoolType},
+		Doc: "whether the semantic version, which can be a string, satisfies the range. The range is made of comparators like >=1.2.3, " +
+			"which are separated by spaces meaning and, or by || meaning or. ^, ~, x-ranges like 1.2.x and hyphen ranges like 1.2 - 2.3 are supported as well. " +
+			"The pre-release versions only satisfy the comparators of the same major.minor.patch with pre-release",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(semver_satisfies app_version "^2.7 || >=3.1.0 <4")`},
+		Prepare:       prepareSemverRange,
+	}, FuncSemverSatisfies)
+}
+
+// Semver is the semantic version, which is comparable with == as the build metadata is dropped
+type Semver struct {
+	Major, Minor, Patch uint64
+	// Pre is the pre-release, e.g. beta.2
+	Pre string
+}
+
+// ParseSemver parses the version of semver 2.0, the leading v is allowed
+func ParseSemver(s string) (Semver, error) {
+	p, err := parsePartialSemver(s)
+	if err != nil {
+		return Semver{}, err
+	}
+	if p.n != 3 {
+		return Semver{}, fmt.Errorf("%w: semver %q should have major, minor and patch", ErrIllegalFormat, s)
+	}
+	return p.Semver, nil
+}
+
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than w by the precedence of semver 2.0
+func (v Semver) Compare(w Semver) int {
+	for _, p := range [][2]uint64{{v.Major, w.Major}, {v.Minor, w.Minor}, {v.Patch, w.Patch}} {
+		if p[0] != p[1] {
+			if p[0] < p[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == w.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case w.Pre == "":
+		return -1
+	}
+	a, b := strings.Split(v.Pre, "."), strings.Split(w.Pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := comparePreIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// comparePreIdentifier compares the identifiers of pre-release, the numeric ones are lower than the others
+func comparePreIdentifier(a, b string) int {
+	an, bn := isNumeric(a), isNumeric(b)
+	switch {
+	case an && bn:
+		// no leading zeros, so the longer one is greater
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isNumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// checkIdentifiers checks the dot-separated identifiers of pre-release or build metadata
+func checkIdentifiers(s string, pre bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return errors.New("empty identifier")
+		}
+		for i := 0; i < len(id); i++ {
+			if c := id[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return fmt.Errorf("illegal character %q in identifier %q", c, id)
+			}
+		}
+		if pre && len(id) > 1 && id[0] == '0' && isNumeric(id) {
+			return fmt.Errorf("leading zero in numeric identifier %q", id)
+		}
+	}
+	return nil
+}
+
+// partialSemver is the version within range, in which the first n parts are given and the rest are wildcards
+type partialSemver struct {
+	Semver
+	n int
+}
+
+// parsePartialSemver parses the version like 1.2.3-beta, 1.2, 1.x or *
+func parsePartialSemver(s string) (partialSemver, error) {
+	var p partialSemver
+	v := strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		if err := checkIdentifiers(v[i+1:], false); err != nil {
+			return p, fmt.Errorf("%w: build metadata of semver %q: %v", ErrIllegalFormat, s, err)
+		}
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		if err := checkIdentifiers(v[i+1:], true); err != nil {
+			return p, fmt.Errorf("%w: pre-release of semver %q: %v", ErrIllegalFormat, s, err)
+		}
+		p.Pre = v[i+1:]
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("%w: semver %q has more than three parts", ErrIllegalFormat, s)
+	}
+	nums := []*uint64{&p.Major, &p.Minor, &p.Patch}
+	wildcard := false
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			wildcard = true
+			continue
+		}
+		if wildcard {
+			return p, fmt.Errorf("%w: semver %q has number after wildcard", ErrIllegalFormat, s)
+		}
+		if !isNumeric(part) || len(part) > 1 && part[0] == '0' {
+			return p, fmt.Errorf("%w: semver %q has illegal part %q", ErrIllegalFormat, s, part)
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("%w: semver %q: %v", ErrIllegalFormat, s, err)
+		}
+		*nums[i] = n
+		p.n = i + 1
+	}
+	if p.Pre != "" && p.n != 3 {
+		return p, fmt.Errorf("%w: semver %q has pre-release but not all parts", ErrIllegalFormat, s)
+	}
+	return p, nil
+}
+
+// bump returns the lowest version greater than all the versions matched by the first n parts, n must be within [1, 3]
+func (p partialSemver) bump(n int) Semver {
+	switch n {
+	case 1:
+		return Semver{Major: p.Major + 1}
+	case 2:
+		return Semver{Major: p.Major, Minor: p.Minor + 1}
+	}
+	return Semver{Major: p.Major, Minor: p.Minor, Patch: p.Patch + 1}
+}
+
+// TypeSemver converts version string to Semver
+type TypeSemver struct{}
+
+// Signature implements the interface Signer
+func (f TypeSemver) Signature() Signature {
+	return Signature{Params: []Type{StringType}, Variadic: true, Broadcast: true, Return: SemverType}
+}
+
+// Eval implements the interface Funcer
+func (f TypeSemver) Eval(params ...interface{}) (interface{}, error) {
+	if l := len(params); l < 1 {
+		return nil, fmt.Errorf("t_semver: need at leat one param, but got %d", l)
+	}
+	res, err := f.eval(params...)
+	if err != nil {
+		return nil, err
+	}
+	if l := res.([]interface{}); len(l) == 1 {
+		return l[0], nil
+	}
+	return res, nil
+}
+
+func (f TypeSemver) eval(params ...interface{}) (interface{}, error) {
+	res := make([]interface{}, len(params))
+	for i, p := range params {
+		if v := reflect.ValueOf(p); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			ps := make([]interface{}, v.Len())
+			for j := 0; j < v.Len(); j++ {
+				ps[j] = v.Index(j).Interface()
+			}
+			m, err := f.eval(ps...)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = m
+			continue
+		}
+		switch t := p.(type) {
+		case Semver:
+			res[i] = t
+		case string:
+			v, err := ParseSemver(t)
+			if err != nil {
+				return nil, fmt.Errorf("t_semver: %w", err)
+			}
+			res[i] = v
+		default:
+			return nil, errors.New("t_semver: param base type is not string")
+		}
+	}
+	return res, nil
+}
+
+// semverComparator is a single comparison like >=1.2.3, the operator is one of = > >= < <=
+type semverComparator struct {
+	op string
+	v  Semver
+}
+
+func (c semverComparator) match(v Semver) bool {
+	r := v.Compare(c.v)
+	switch c.op {
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return r == 0
+}
+
+// SemverRange is the range of semantic versions compiled, which is the union of the sets of comparators
+type SemverRange struct {
+	src  string
+	sets [][]semverComparator
+}
+
+func (r *SemverRange) String() string {
+	return r.src
+}
+
+// Contains returns whether v satisfies the range
+func (r *SemverRange) Contains(v Semver) bool {
+	for _, set := range r.sets {
+		if matchComparators(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchComparators(set []semverComparator, v Semver) bool {
+	for _, c := range set {
+		if !c.match(v) {
+			return false
+		}
+	}
+	if v.Pre == "" {
+		return true
+	}
+	// the pre-release is only allowed if it's explicitly asked for on the same major.minor.patch
+	for _, c := range set {
+		if c.v.Pre != "" && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// none matches nothing, as 0.0.0-0 is the lowest version
+var none = semverComparator{op: "<", v: Semver{Pre: "0"}}
+
+// ParseSemverRange parses the range like "^2.7 || >=3.1.0 <4"
+func ParseSemverRange(s string) (*SemverRange, error) {
+	if len(s) > MaxPatternSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrPatternTooLarge, len(s), MaxPatternSize)
+	}
+	r, err := patterns.get("semver:"+s, func() (interface{}, error) {
+		r := &SemverRange{src: s}
+		for _, alt := range strings.Split(s, "||") {
+			set, err := parseComparators(strings.Fields(alt))
+			if err != nil {
+				return nil, fmt.Errorf("range %q: %w", s, err)
+			}
+			r.sets = append(r.sets, set)
+		}
+		return r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.(*SemverRange), nil
+}
+
+func parseComparators(fields []string) ([]semverComparator, error) {
+	set := make([]semverComparator, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if i+2 < len(fields) && fields[i+1] == "-" {
+			cs, err := hyphenRange(f, fields[i+2])
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, cs...)
+			i += 2
+			continue
+		}
+		op := f[:len(f)-len(strings.TrimLeft(f, "<>=~^"))]
+		if op == f {
+			// the operator is separated from the version by spaces
+			if i+1 == len(fields) {
+				return nil, fmt.Errorf("%w: operator %q without version", ErrIllegalFormat, op)
+			}
+			i++
+			f = op + fields[i]
+		}
+		cs, err := comparators(op, f[len(op):])
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+// comparators expands the operator and the partial version into the plain comparators
+func comparators(op, version string) ([]semverComparator, error) {
+	p, err := parsePartialSemver(version)
+	if err != nil {
+		return nil, err
+	}
+	lower := p.Semver
+	switch op {
+	case "", "=":
+		switch p.n {
+		case 0:
+			return nil, nil
+		case 3:
+			return []semverComparator{{"=", lower}}, nil
+		}
+		return []semverComparator{{">=", lower}, {"<", p.bump(p.n)}}, nil
+	case ">=":
+		if p.n == 0 {
+			return nil, nil
+		}
+		return []semverComparator{{">=", lower}}, nil
+	case ">":
+		switch p.n {
+		case 0:
+			return []semverComparator{none}, nil
+		case 3:
+			return []semverComparator{{">", lower}}, nil
+		}
+		return []semverComparator{{">=", p.bump(p.n)}}, nil
+	case "<":
+		if p.n == 0 {
+			return []semverComparator{none}, nil
+		}
+		return []semverComparator{{"<", lower}}, nil
+	case "<=":
+		switch p.n {
+		case 0:
+			return nil, nil
+		case 3:
+			return []semverComparator{{"<=", lower}}, nil
+		}
+		return []semverComparator{{"<", p.bump(p.n)}}, nil
+	case "~":
+		switch p.n {
+		case 0:
+			return nil, nil
+		case 1:
+			return []semverComparator{{">=", lower}, {"<", p.bump(1)}}, nil
+		}
+		return []semverComparator{{">=", lower}, {"<", p.bump(2)}}, nil
+	case "^":
+		switch {
+		case p.n == 0:
+			return nil, nil
+		case p.Major > 0 || p.n == 1:
+			return []semverComparator{{">=", lower}, {"<", p.bump(1)}}, nil
+		case p.Minor > 0 || p.n == 2:
+			return []semverComparator{{">=", lower}, {"<", p.bump(2)}}, nil
+		}
+		return []semverComparator{{">=", lower}, {"<", p.bump(3)}}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %q", ErrIllegalFormat, op)
+}
+
+// hyphenRange expands the range like 1.2 - 2.3, which includes both ends
+func hyphenRange(from, to string) ([]semverComparator, error) {
+	cs, err := comparators(">=", from)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := comparators("<=", to)
+	if err != nil {
+		return nil, err
+	}
+	return append(cs, upper...), nil
+}
+
+// prepareSemverRange compiles the range which is the second param
+func prepareSemverRange(i int, param interface{}) (interface{}, bool, error) {
+	if i != 1 {
+		return nil, false, nil
+	}
+	switch p := param.(type) {
+	case *SemverRange:
+		return p, true, nil
+	case string:
+		r, err := ParseSemverRange(p)
+		if err != nil {
+			return nil, false, err
+		}
+		return r, true, nil
+	}
+	return nil, false, nil
+}
+
+// SemverSatisfies returns whether the semantic version satisfies the range, the version can be either string or Semver
+func SemverSatisfies(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return false, fmt.Errorf("semver_satisfies: need two params, but got %d", l)
+	}
+	r, ok, err := prepareSemverRange(1, params[1])
+	if err != nil {
+		return false, fmt.Errorf("semver_satisfies: %w", err)
+	}
+	if !ok {
+		return false, errors.New("semver_satisfies: the range should be string")
+	}
+	var v Semver
+	switch t := params[0].(type) {
+	case Semver:
+		v = t
+	case string:
+		if v, err = ParseSemver(t); err != nil {
+			return false, fmt.Errorf("semver_satisfies: %w", err)
+		}
+	default:
+		return false, fmt.Errorf("semver_satisfies: the version should be string or semver, but got %v", params[0])
+	}
+	return r.(*SemverRange).Contains(v), nil
+}
@@ -0,0 +1,173 @@
+package function
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSemverPrecedence(t *testing.T) {
+	// ordered from the lowest, as the example of semver 2.0
+	ordered := []string{
+		"0.0.0-0",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+		"18446744073709551615.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := ParseSemver(ordered[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := ParseSemver(ordered[j])
+			wanna := 0
+			if i < j {
+				wanna = -1
+			} else if i > j {
+				wanna = 1
+			}
+			if c := a.Compare(b); c != wanna {
+				t.Errorf("compare %s with %s wanna: %d, got: %d", a, b, wanna, c)
+			}
+		}
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	for _, s := range []string{"1.0.0+build.1", "v1.0.0", "1.0.0+20130313144700"} {
+		if v, err := ParseSemver(s); err != nil || v != (Semver{Major: 1}) {
+			t.Errorf("%q wanna: 1.0.0, got: %v, %v", s, v, err)
+		}
+	}
+	if v, _ := ParseSemver("v2.7.1-beta.2+exp.sha.5114f85"); v.String() != "2.7.1-beta.2" {
+		t.Errorf("wanna: 2.7.1-beta.2, got: %s", v)
+	}
+	for _, s := range []string{"", "1", "1.2", "1.2.3.4", "01.2.3", "1.2.x", "1.2.3-", "1.2.3-01", "1.2.3-a..b", "1.2.3+", "1.2.3-a_b", "a.b.c"} {
+		if _, err := ParseSemver(s); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", s, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestSemverFuncs(t *testing.T) {
+	semver := func(s string) Semver {
+		v, err := ParseSemver(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	v, err := TypeSemver{}.Eval([]interface{}{"2.7.1-beta.2", "v3.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := v.([]interface{})
+	if vs[0] != semver("2.7.1-beta.2") || vs[1] != semver("3.0.0") {
+		t.Errorf("wanna: [2.7.1-beta.2 3.0.0], got: %v", vs)
+	}
+	if _, err := (TypeSemver{}).Eval("2.7"); err == nil {
+		t.Errorf("wanna error of partial version")
+	}
+
+	if r, err := (Compare{ModeLessThan}).Eval(semver("2.7.1-beta.2"), semver("2.7.1")); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := Between(semver("2.7.1-rc.1"), semver("2.7.1-beta.2"), semver("2.7.1")); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := (Equal{}).Eval(semver("1.0.0+a"), semver("v1.0.0")); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := In(semver("1.0.0"), vs); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if r, err := In(semver("3.0.0+b"), vs); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+}
+
+func TestSemverRange(t *testing.T) {
+	inputs := []struct {
+		rng       string
+		satisfied []string
+		others    []string
+	}{
+		{"^2.7 || >=3.1.0 <4", []string{"2.7.0", "2.9.1", "3.1.0", "3.99.0"}, []string{"2.6.9", "3.0.5", "4.0.0", "2.8.0-beta"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.0", []string{"0.0.9"}, []string{"0.1.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.0.0", "1.9.9"}, []string{"2.0.0"}},
+		{"1.2.x", []string{"1.2.0", "1.2.99"}, []string{"1.3.0", "1.1.9"}},
+		{"1.x || 3", []string{"1.5.0", "3.1.2"}, []string{"2.0.0"}},
+		{"*", []string{"0.0.1", "99.0.0"}, []string{"1.0.0-beta"}},
+		{"", []string{"1.0.0"}, nil},
+		{">2.7", []string{"2.8.0"}, []string{"2.7.5"}},
+		{"<=2.7", []string{"2.7.5"}, []string{"2.8.0"}},
+		{"< 2", []string{"1.9.9"}, []string{"2.0.0"}},
+		{">= 1.2.3 < 1.3", []string{"1.2.3"}, []string{"1.3.0", "1.2.2"}},
+		{"=v1.2.3", []string{"1.2.3+build"}, []string{"1.2.4"}},
+		{"1.2 - 2.3", []string{"1.2.0", "2.3.9"}, []string{"1.1.9", "2.4.0"}},
+		{"1.2.3 - 2.3.4", []string{"2.3.4"}, []string{"2.3.5"}},
+		{">=1.2.3-beta.2 <2", []string{"1.2.3-beta.3", "1.2.3", "1.5.0"}, []string{"1.2.3-beta.1", "1.2.4-beta.1"}},
+		{"<0.x", nil, []string{"0.0.0-0", "0.0.0"}},
+	}
+	for _, input := range inputs {
+		r, err := ParseSemverRange(input.rng)
+		if err != nil {
+			t.Errorf("%q: %v", input.rng, err)
+			continue
+		}
+		for _, s := range input.satisfied {
+			v, _ := ParseSemver(s)
+			if !r.Contains(v) {
+				t.Errorf("%q wanna %s satisfied", input.rng, s)
+			}
+		}
+		for _, s := range input.others {
+			v, _ := ParseSemver(s)
+			if r.Contains(v) {
+				t.Errorf("%q wanna %s unsatisfied", input.rng, s)
+			}
+		}
+	}
+
+	for _, rng := range []string{">=", "!1.2.3", "1.x.3", "1.2.3-beta.01", "^1.2-beta"} {
+		if _, err := ParseSemverRange(rng); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", rng, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestSemverSatisfies(t *testing.T) {
+	fn, err := Get(FuncSemverSatisfies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := fn("v2.8.0", "^2.7"); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	spec, _ := Describe(FuncSemverSatisfies)
+	r, ok, err := spec.Prepare(1, ">=3.1.0 <4")
+	if !ok || err != nil {
+		t.Fatalf("wanna the range compiled, got: %v, %v", ok, err)
+	}
+	if res, err := fn(Semver{Major: 3, Minor: 2}, r); err != nil || res != true {
+		t.Errorf("wanna: true, got: %v, %v", res, err)
+	}
+	for _, params := range [][]interface{}{{"2.7", "^2"}, {2.7, "^2"}, {"2.7.0", 2}, {"2.7.0"}} {
+		if _, err := fn(params...); err == nil {
+			t.Errorf("%v wanna error", params)
+		}
+	}
+}
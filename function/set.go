@@ -0,0 +1,285 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// FuncSet is the function/operator keyword set
+	FuncSet = "set"
+	// FuncUnion is the function/operator keyword union
+	FuncUnion = "union"
+	// FuncIntersect is the function/operator keyword intersect
+	FuncIntersect = "intersect"
+	// FuncDifference is the function/operator keyword difference
+	FuncDifference = "difference"
+	// FuncSubset is the function/operator keyword subset
+	FuncSubset = "subset"
+)
+
+func init() {
+	MustRegist(FuncSet, BuildSet)
+	MustRegist(FuncUnion, Union)
+	MustRegist(FuncIntersect, Intersect)
+	MustRegist(FuncDifference, Difference)
+	MustRegist(FuncSubset, Subset)
+
+	// the sets are checked as lists, as they are accepted wherever the lists are by the functions below
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{ListOf(GenericType)}, Return: ListOf(GenericType)},
+		Doc:           "the set of the elements within the list, which is looked up by hash",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(in user_id (set blocked_ids))`},
+	}, FuncSet)
+	for _, v := range []struct {
+		name, doc, example string
+	}{
+		{FuncUnion, "the set of the elements within any of the params", `(union tags (set ("new")))`},
+		{FuncIntersect, "the set of the elements within all of the params", `(intersect tags ("vip" "svip"))`},
+		{FuncDifference, "the set of the elements within the first param but none of the others", `(difference tags ("test"))`},
+	} {
+		describe(FuncSpec{
+			Signature:     Signature{Params: []Type{ListOf(GenericType)}, Variadic: true, Return: ListOf(GenericType)},
+			Doc:           v.doc + ", the params are either lists or sets",
+			Pure:          true,
+			Deterministic: true,
+			Examples:      []string{v.example},
+			Prepare:       prepareSet(0),
+		}, v.name)
+	}
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{ListOf(GenericType), ListOf(GenericType)}, Return: BoolType},
+		Doc:           "whether all the elements of the first param are within the second one, the params are either lists or sets",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(subset permissions ("read" "write" "admin"))`},
+		Prepare:       prepareSet(0),
+	}, FuncSubset)
+}
+
+// Set is the collection of distinct values, which are normalized by Uniform and looked up by hash.
+// The values not comparable, e.g. lists and maps, are looked up one by one
+type Set struct {
+	// values in the order of insertion
+	values []interface{}
+	index  map[interface{}]struct{}
+	// rest are the values not comparable
+	rest []interface{}
+}
+
+// NewSet returns the set of the values
+func NewSet(values ...interface{}) *Set {
+	s := &Set{index: make(map[interface{}]struct{}, len(values))}
+	for _, v := range values {
+		s.add(normalize(v))
+	}
+	return s
+}
+
+// ToSet converts the list into Set, the Set is returned as it is
+func ToSet(v interface{}) (*Set, error) {
+	if s, ok := v.(*Set); ok {
+		return s, nil
+	}
+	if v == nil {
+		return nil, fmt.Errorf("need list or set, but got nil")
+	}
+	if k := reflect.TypeOf(v).Kind(); k != reflect.Slice && k != reflect.Array {
+		return nil, fmt.Errorf("need list or set, but got %T", v)
+	}
+	t := reflect.ValueOf(v)
+	s := &Set{index: make(map[interface{}]struct{}, t.Len())}
+	for i := 0; i < t.Len(); i++ {
+		s.add(normalize(t.Index(i).Interface()))
+	}
+	return s, nil
+}
+
+// normalize converts v by Uniform, nil is kept
+func normalize(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return Uniform(v)[0]
+}
+
+// hashable returns whether the normalized v can be the key of map
+func hashable(v interface{}) bool {
+	return v == nil || reflect.TypeOf(v).Comparable()
+}
+
+func (s *Set) add(v interface{}) {
+	if s.has(v) {
+		return
+	}
+	if hashable(v) {
+		s.index[v] = struct{}{}
+	} else {
+		s.rest = append(s.rest, v)
+	}
+	s.values = append(s.values, v)
+}
+
+// has looks up the normalized v
+func (s *Set) has(v interface{}) bool {
+	if hashable(v) {
+		_, ok := s.index[v]
+		return ok
+	}
+	for _, r := range s.rest {
+		if equalValues(v, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether v is within the set, v is normalized as the values are
+func (s *Set) Contains(v interface{}) bool {
+	return s.has(normalize(v))
+}
+
+// Len returns the count of values
+func (s *Set) Len() int {
+	return len(s.values)
+}
+
+// Values returns the values in the order of insertion
+func (s *Set) Values() []interface{} {
+	return append([]interface{}(nil), s.values...)
+}
+
+// Equal returns whether the two sets have the same values regardless of the order
+func (s *Set) Equal(other *Set) bool {
+	return s.Len() == other.Len() && s.subset(other)
+}
+
+func (s *Set) subset(other *Set) bool {
+	for _, v := range s.values {
+		if !other.has(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Set) String() string {
+	ss := make([]string, len(s.values))
+	for i, v := range s.values {
+		ss[i] = fmt.Sprint(v)
+	}
+	return "set(" + strings.Join(ss, " ") + ")"
+}
+
+// prepareSet converts the constant lists from the param at index start into Set
+func prepareSet(start int) func(int, interface{}) (interface{}, bool, error) {
+	return func(i int, param interface{}) (interface{}, bool, error) {
+		if i < start || param == nil {
+			return nil, false, nil
+		}
+		if k := reflect.TypeOf(param).Kind(); k != reflect.Slice && k != reflect.Array {
+			return nil, false, nil
+		}
+		s, err := ToSet(param)
+		if err != nil {
+			return nil, false, err
+		}
+		return s, true, nil
+	}
+}
+
+// BuildSet returns the Set of the list
+func BuildSet(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("set: need one param, but got %d", l)
+	}
+	s, err := ToSet(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("set: %v", err)
+	}
+	return s, nil
+}
+
+// toSets converts all the params into Set
+func toSets(name string, params []interface{}) ([]*Set, error) {
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%s: need at least one param, but got 0", name)
+	}
+	sets := make([]*Set, len(params))
+	for i, p := range params {
+		s, err := ToSet(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+// Union returns the Set of the values within any of the params
+func Union(params ...interface{}) (interface{}, error) {
+	sets, err := toSets(FuncUnion, params)
+	if err != nil {
+		return nil, err
+	}
+	res := &Set{index: make(map[interface{}]struct{})}
+	for _, s := range sets {
+		for _, v := range s.values {
+			res.add(v)
+		}
+	}
+	return res, nil
+}
+
+// Intersect returns the Set of the values within all of the params
+func Intersect(params ...interface{}) (interface{}, error) {
+	sets, err := toSets(FuncIntersect, params)
+	if err != nil {
+		return nil, err
+	}
+	res := &Set{index: make(map[interface{}]struct{})}
+outer:
+	for _, v := range sets[0].values {
+		for _, s := range sets[1:] {
+			if !s.has(v) {
+				continue outer
+			}
+		}
+		res.add(v)
+	}
+	return res, nil
+}
+
+// Difference returns the Set of the values within the first param but none of the others
+func Difference(params ...interface{}) (interface{}, error) {
+	sets, err := toSets(FuncDifference, params)
+	if err != nil {
+		return nil, err
+	}
+	res := &Set{index: make(map[interface{}]struct{})}
+outer:
+	for _, v := range sets[0].values {
+		for _, s := range sets[1:] {
+			if s.has(v) {
+				continue outer
+			}
+		}
+		res.add(v)
+	}
+	return res, nil
+}
+
+// Subset returns whether all the values of the first param are within the second one
+func Subset(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("subset: need two params, but got %d", l)
+	}
+	sets, err := toSets(FuncSubset, params)
+	if err != nil {
+		return nil, err
+	}
+	return sets[0].subset(sets[1]), nil
+}
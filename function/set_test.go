@@ -0,0 +1,128 @@
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := NewSet(1, 2.0, "2", []int{1, 2}, map[string]int{"a": 1}, nil, int64(1), []interface{}{1.0, 2.0})
+	if s.Len() != 6 {
+		t.Errorf("wanna 6 values, got: %d, %v", s.Len(), s)
+	}
+	wanna := []interface{}{1.0, 2.0, "2", []interface{}{1.0, 2.0}, map[string]interface{}{"a": 1.0}, nil}
+	if !reflect.DeepEqual(s.Values(), wanna) {
+		t.Errorf("wanna: %v, got: %v", wanna, s.Values())
+	}
+	for v, wanna := range map[interface{}]bool{
+		1:        true,
+		uint8(2): true,
+		"1":      false,
+		3.0:      false,
+		nil:      true,
+		true:     false,
+	} {
+		if s.Contains(v) != wanna {
+			t.Errorf("%v wanna: %v, got: %v", v, wanna, !wanna)
+		}
+	}
+	if !s.Contains([]float64{1, 2}) || s.Contains([]int{2, 1}) || !s.Contains(map[string]float64{"a": 1}) {
+		t.Errorf("wrong result of the values not comparable")
+	}
+	if !NewSet(1, 2).Equal(NewSet(2.0, 1.0, 2)) || NewSet(1, 2).Equal(NewSet(1, 3)) {
+		t.Errorf("wrong result of equal")
+	}
+	if _, err := ToSet("abc"); err == nil {
+		t.Errorf("wanna error of string")
+	}
+}
+
+func TestSetFuncs(t *testing.T) {
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncSet, []interface{}{[]interface{}{1, 2, 2}}, NewSet(1, 2), false},
+		{FuncSet, []interface{}{1}, nil, true},
+		{FuncUnion, []interface{}{[]int{3, 1}, NewSet(2, 3)}, NewSet(3, 1, 2), false},
+		{FuncUnion, []interface{}{[]int{3, 1}, 1}, nil, true},
+		{FuncIntersect, []interface{}{[]int{3, 1, 2}, NewSet(2, 3), []float64{3, 4}}, NewSet(3), false},
+		{FuncIntersect, []interface{}{[]string{"a"}, []int{1}}, NewSet(), false},
+		{FuncDifference, []interface{}{[]int{3, 1, 2}, NewSet(2), []float64{3, 4}}, NewSet(1), false},
+		{FuncDifference, nil, nil, true},
+		{FuncSubset, []interface{}{[]int{1, 2}, NewSet(2, 3, 1)}, true, false},
+		{FuncSubset, []interface{}{NewSet(1, 4), []int{1, 2, 3}}, false, false},
+		{FuncSubset, []interface{}{[]int{}, []int{}}, true, false},
+		{FuncSubset, []interface{}{[]int{1}}, nil, true},
+		{FuncIn, []interface{}{2, NewSet(1, 2)}, true, false},
+		{FuncIn, []interface{}{"2", NewSet(1, 2)}, false, false},
+		{FuncOverlap, []interface{}{NewSet(1, 2), []int{2, 3}}, true, false},
+		{FuncOverlap, []interface{}{[]int{3, 4}, NewSet(1, 2)}, false, false},
+		{FuncOverlap, []interface{}{NewSet(1, 2), NewSet(3, 2)}, true, false},
+		{FuncLen, []interface{}{NewSet(1, 1, 2)}, 2.0, false},
+		{FuncEqual, []interface{}{NewSet(1, 2), []int{2, 1}}, true, false},
+		{FuncEqual, []interface{}{[]int{2, 1}, NewSet(1, 2)}, true, false},
+		{FuncEqual, []interface{}{NewSet(1, 2), NewSet(1)}, false, false},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if s, ok := input.result.(*Set); ok {
+			if r, ok := res.(*Set); !ok || !reflect.DeepEqual(s.Values(), r.Values()) {
+				t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+			}
+		} else if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+
+	for _, name := range []string{FuncIn, FuncOverlap, FuncUnion, FuncSubset} {
+		spec, _ := Describe(name)
+		if s, ok, err := spec.Prepare(1, []interface{}{"a", "b"}); !ok || err != nil || fmt.Sprintf("%T", s) != "*function.Set" {
+			t.Errorf("%s wanna the list converted, got: %v, %v, %v", name, s, ok, err)
+		}
+		if _, ok, _ := spec.Prepare(1, "a"); ok {
+			t.Errorf("%s wanna the string left as it is", name)
+		}
+	}
+	spec, _ := Describe(FuncIn)
+	if _, ok, _ := spec.Prepare(0, []interface{}{"a"}); ok {
+		t.Errorf("wanna the first param of in left as it is")
+	}
+}
+
+func BenchmarkInSet(b *testing.B) {
+	ids := make([]interface{}, 50000)
+	for i := range ids {
+		ids[i] = float64(i)
+	}
+	s := NewSet(ids...)
+	for _, v := range []struct {
+		name string
+		list interface{}
+	}{{"list", ids}, {"set", s}} {
+		b.Run(v.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				if _, err := In(49999, v.list); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+}
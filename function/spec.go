@@ -0,0 +1,142 @@
+package function
+
+import "fmt"
+
+// Signature describes the types of params and result of a function for static type checking
+type Signature struct {
+	Params []Type
+	// Variadic means the last type of Params can be repeated
+	Variadic bool
+	// Broadcast means each param can be a list of the type expected, and the result turns into a list as well
+	Broadcast bool
+	Return    Type
+}
+
+// Signer is implemented by Funcer which declares its Signature
+type Signer interface {
+	Signature() Signature
+}
+
+// FuncSpec describes a function for validation, autocomplete and documents
+type FuncSpec struct {
+	Name string
+	// MinArgs and MaxArgs limit the count of params, MaxArgs of -1 means unlimited.
+	// They are derived from the Signature if both are zero
+	MinArgs int
+	MaxArgs int
+	Signature
+	Doc string
+	// Pure means the function has no side effects
+	Pure bool
+	// Deterministic means the function always returns the same result with the same params, so it can be evaluated in advance
+	Deterministic bool
+	Examples      []string
+	// Prepare optionally converts the constant param at index i in advance, e.g. compiles the pattern,
+	// so that it's done once rather than on every evaluation. ok tells whether the param is converted,
+	// and the error is reported when parsing the expression
+	Prepare func(i int, param interface{}) (prepared interface{}, ok bool, err error)
+}
+
+// Describer is implemented by Funcer which declares its FuncSpec
+type Describer interface {
+	Spec() FuncSpec
+}
+
+var (
+	specs = make(map[string]FuncSpec)
+)
+
+// HasSignature returns whether the types of params and result are declared
+func (s FuncSpec) HasSignature() bool {
+	return s.Return != ""
+}
+
+// CheckArity returns error if the count of params is out of range
+func (s FuncSpec) CheckArity(n int) error {
+	if s.MaxArgs < 0 {
+		if n < s.MinArgs {
+			return fmt.Errorf("%s: need at least %d params, but got %d", s.Name, s.MinArgs, n)
+		}
+	} else if s.MinArgs == s.MaxArgs {
+		if n != s.MinArgs {
+			return fmt.Errorf("%s: need %d params, but got %d", s.Name, s.MinArgs, n)
+		}
+	} else if n < s.MinArgs || n > s.MaxArgs {
+		return fmt.Errorf("%s: need %d to %d params, but got %d", s.Name, s.MinArgs, s.MaxArgs, n)
+	}
+	return nil
+}
+
+func (s FuncSpec) normalize(name string) FuncSpec {
+	s.Name = name
+	if s.MinArgs == 0 && s.MaxArgs == 0 {
+		if !s.HasSignature() {
+			s.MaxArgs = -1
+		} else if s.MinArgs = len(s.Params); s.Variadic {
+			s.MaxArgs = -1
+		} else {
+			s.MaxArgs = len(s.Params)
+		}
+	}
+	return s
+}
+
+// Describe returns the FuncSpec of a registered function by name.
+// Only the name is known if the function is registered without FuncSpec or Signature
+func Describe(name string) (FuncSpec, error) {
+	if _, exists := functions[name]; !exists {
+		return FuncSpec{}, ErrNotFound
+	}
+	spec, exists := specs[name]
+	if !exists {
+		return FuncSpec{Name: name, MaxArgs: -1}, nil
+	}
+	return spec, nil
+}
+
+// GetSignature gets the Signature of a registered function by name
+func GetSignature(name string) (Signature, bool) {
+	spec, exists := specs[name]
+	if !exists || !spec.HasSignature() {
+		return Signature{}, false
+	}
+	return spec.Signature, true
+}
+
+// RegistSignature regists the Signature of a registered function with name of name
+func RegistSignature(name string, sig Signature) error {
+	if _, exist := functions[name]; !exist {
+		return ErrNotFound
+	}
+	spec := specs[name]
+	spec.Signature = sig
+	spec.MinArgs, spec.MaxArgs = 0, 0
+	specs[name] = spec.normalize(name)
+	return nil
+}
+
+// RegistSpec regists fn with type Func along with its FuncSpec with name of name
+func RegistSpec(name string, fn Func, spec FuncSpec) error {
+	if _, exist := functions[name]; exist {
+		return ErrFunctionExists
+	}
+	MustRegistSpec(name, fn, spec)
+	return nil
+}
+
+// MustRegistSpec is same as RegistSpec but may overide if function with name existed
+func MustRegistSpec(name string, fn Func, spec FuncSpec) {
+	MustRegist(name, fn)
+	specs[name] = spec.normalize(name)
+}
+
+// describe annotates the registered built-in functions with spec, the Signature declared by Signer is kept if spec has none
+func describe(spec FuncSpec, names ...string) {
+	for _, name := range names {
+		s := spec
+		if !s.HasSignature() {
+			s.Signature = specs[name].Signature
+		}
+		specs[name] = s.normalize(name)
+	}
+}
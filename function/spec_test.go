@@ -0,0 +1,135 @@
+package function
+
+import (
+	"testing"
+)
+
+type typedFoo struct{}
+
+func (f typedFoo) Eval(params ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (f typedFoo) Signature() Signature {
+	return Signature{Params: []Type{StringType}, Return: NumberType}
+}
+
+func TestSignature(t *testing.T) {
+	if err := RegistFuncer("typed_foo", typedFoo{}); err != nil {
+		t.Fatal(err)
+	}
+	if sig, ok := GetSignature("typed_foo"); !ok || sig.Return != NumberType {
+		t.Errorf("signature of typed_foo wanna: %v, got: %v", typedFoo{}.Signature(), sig)
+	}
+	MustRegist("typed_foo", foo)
+	if _, ok := GetSignature("typed_foo"); ok {
+		t.Error("signature should be removed when overridden")
+	}
+	if err := RegistSignature("typed_foo", Signature{Return: BoolType}); err != nil {
+		t.Error(err)
+	}
+	if err := RegistSignature("typed_fooooo", Signature{Return: BoolType}); err != ErrNotFound {
+		t.Error("should not found")
+	}
+	if sig, ok := GetSignature(FuncBetween); !ok || len(sig.Params) != 3 {
+		t.Errorf("signature of between is incorrect: %v", sig)
+	}
+	if sig, ok := GetSignature(FuncTypeDefaultTime); !ok || len(sig.Params) != 1 || !sig.Broadcast {
+		t.Errorf("signature of td_time is incorrect: %v", sig)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	spec, err := Describe(FuncBetween)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != FuncBetween || spec.MinArgs != 3 || spec.MaxArgs != 3 || !spec.Pure || !spec.Deterministic ||
+		spec.Doc == "" || len(spec.Examples) == 0 || spec.Return != BoolType {
+		t.Errorf("spec of between is incorrect: %+v", spec)
+	}
+	spec, err = Describe(OperatorAnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.MinArgs != 2 || spec.MaxArgs != -1 || spec.Return != BoolType {
+		t.Errorf("spec of & is incorrect: %+v", spec)
+	}
+	if _, err := Describe("fooooo"); err != ErrNotFound {
+		t.Error("should not found")
+	}
+
+	for _, name := range []string{FuncIn, FuncEqual, FuncTypeVersion, FuncTypeTime, OperatorDivide, FuncModulo} {
+		if spec, _ := Describe(name); spec.Doc == "" || !spec.HasSignature() || !spec.Pure {
+			t.Errorf("function %s is not described: %+v", name, spec)
+		}
+	}
+}
+
+func TestRegistSpec(t *testing.T) {
+	spec := FuncSpec{
+		Signature: Signature{Params: []Type{StringType, NumberType}, Return: StringType},
+		Doc:       "foo",
+	}
+	if err := RegistSpec("spec_foo", foo, spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegistSpec("spec_foo", foo, spec); err != ErrFunctionExists {
+		t.Error("should exist")
+	}
+	r, err := Describe("spec_foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "spec_foo" || r.MinArgs != 2 || r.MaxArgs != 2 || r.Pure {
+		t.Errorf("spec of spec_foo is incorrect: %+v", r)
+	}
+
+	if err := Regist("spec_bar", foo); err != nil {
+		t.Fatal(err)
+	}
+	r, err = Describe("spec_bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "spec_bar" || r.MinArgs != 0 || r.MaxArgs != -1 || r.HasSignature() {
+		t.Errorf("spec of spec_bar is incorrect: %+v", r)
+	}
+
+	MustRegistFuncer("spec_foor", describedFoo{})
+	if r, _ := Describe("spec_foor"); r.MinArgs != 1 || r.MaxArgs != 2 || !r.Pure {
+		t.Errorf("spec of spec_foor is incorrect: %+v", r)
+	}
+}
+
+type describedFoo struct{}
+
+func (f describedFoo) Eval(params ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (f describedFoo) Spec() FuncSpec {
+	return FuncSpec{MinArgs: 1, MaxArgs: 2, Pure: true}
+}
+
+func TestCheckArity(t *testing.T) {
+	inputs := []struct {
+		spec FuncSpec
+		n    int
+		err  bool
+	}{
+		{FuncSpec{MinArgs: 2, MaxArgs: -1}, 2, false},
+		{FuncSpec{MinArgs: 2, MaxArgs: -1}, 5, false},
+		{FuncSpec{MinArgs: 2, MaxArgs: -1}, 1, true},
+		{FuncSpec{MinArgs: 1, MaxArgs: 1}, 1, false},
+		{FuncSpec{MinArgs: 1, MaxArgs: 1}, 2, true},
+		{FuncSpec{MinArgs: 1, MaxArgs: 3}, 3, false},
+		{FuncSpec{MinArgs: 1, MaxArgs: 3}, 0, true},
+		{FuncSpec{MinArgs: 1, MaxArgs: 3}, 4, true},
+	}
+	for _, input := range inputs {
+		if err := input.spec.CheckArity(input.n); (err != nil) != input.err {
+			t.Errorf("spec: %+v, params: %d, unexpected error: %v", input.spec, input.n, err)
+		}
+	}
+}
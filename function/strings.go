@@ -0,0 +1,361 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// FuncContains is the function/operator keyword contains
+	FuncContains = "contains"
+	// FuncStartsWith is the function/operator keyword starts_with
+	FuncStartsWith = "starts_with"
+	// FuncEndsWith is the function/operator keyword ends_with
+	FuncEndsWith = "ends_with"
+	// FuncLower is the function/operator keyword lower
+	FuncLower = "lower"
+	// FuncUpper is the function/operator keyword upper
+	FuncUpper = "upper"
+	// FuncTrim is the function/operator keyword trim
+	FuncTrim = "trim"
+	// FuncLen is the function/operator keyword len
+	FuncLen = "len"
+	// FuncConcat is the function/operator keyword concat
+	FuncConcat = "concat"
+	// FuncSubstr is the function/operator keyword substr
+	FuncSubstr = "substr"
+	// FuncSplit is the function/operator keyword split
+	FuncSplit = "split"
+	// FuncJoin is the function/operator keyword join
+	FuncJoin = "join"
+	// FuncReplace is the function/operator keyword replace
+	FuncReplace = "replace"
+	// FuncIndexOf is the function/operator keyword index_of
+	FuncIndexOf = "index_of"
+)
+
+func init() {
+	for _, f := range []StringFunc{
+		{
+			Name: FuncContains, Params: []Type{StringType}, Return: BoolType,
+			Doc: "whether the string contains the substring", Example: `(contains user_agent "iPhone")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.Contains(s, args[0].(string)), nil
+			},
+		},
+		{
+			Name: FuncStartsWith, Params: []Type{StringType}, Return: BoolType,
+			Doc: "whether the string starts with the prefix", Example: `(starts_with path "/api/")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.HasPrefix(s, args[0].(string)), nil
+			},
+		},
+		{
+			Name: FuncEndsWith, Params: []Type{StringType}, Return: BoolType,
+			Doc: "whether the string ends with the suffix", Example: `(ends_with email "@example.com")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.HasSuffix(s, args[0].(string)), nil
+			},
+		},
+		{
+			Name: FuncLower, Return: StringType,
+			Doc: "the string in lower case", Example: `(lower country)`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.ToLower(s), nil
+			},
+		},
+		{
+			Name: FuncUpper, Return: StringType,
+			Doc: "the string in upper case", Example: `(upper country)`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.ToUpper(s), nil
+			},
+		},
+		{
+			Name: FuncTrim, Params: []Type{StringType}, Optional: 1, Return: StringType,
+			Doc: "the string without the leading and trailing spaces, or the characters of the optional cutset", Example: `(trim name)`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				if len(args) == 0 {
+					return strings.TrimSpace(s), nil
+				}
+				return strings.Trim(s, args[0].(string)), nil
+			},
+		},
+		{
+			Name: FuncSubstr, Params: []Type{NumberType, NumberType}, Optional: 1, Return: StringType,
+			Doc: "the substring from the start of character index with the optional count of characters, out of range is truncated", Example: `(substr phone 0 3)`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				runes := []rune(s)
+				start, err := runeIndex(args[0], len(runes))
+				if err != nil {
+					return nil, err
+				}
+				end := len(runes)
+				if len(args) == 2 {
+					n, err := toInt64(args[1])
+					if err != nil {
+						return nil, err
+					}
+					if n < 0 {
+						return nil, fmt.Errorf("count should not be negative, but got %d", n)
+					}
+					if int64(start)+n < int64(end) {
+						end = start + int(n)
+					}
+				}
+				return string(runes[start:end]), nil
+			},
+		},
+		{
+			Name: FuncReplace, Params: []Type{StringType, StringType}, Return: StringType,
+			Doc: "the string with all the old substrings replaced by the new one", Example: `(replace phone "-" "")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return strings.ReplaceAll(s, args[0].(string), args[1].(string)), nil
+			},
+		},
+		{
+			Name: FuncIndexOf, Params: []Type{StringType}, Return: NumberType,
+			Doc: "the character index of the first substring, -1 if not found", Example: `(index_of email "@")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				i := strings.Index(s, args[0].(string))
+				if i < 0 {
+					return -1.0, nil
+				}
+				return float64(utf8.RuneCountInString(s[:i])), nil
+			},
+		},
+	} {
+		MustRegistFuncer(f.Name, f)
+	}
+
+	MustRegist(FuncLen, Len)
+	MustRegist(FuncConcat, Concat)
+	MustRegist(FuncSplit, Split)
+	MustRegist(FuncJoin, Join)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType}, Return: NumberType},
+		Doc:           "the count of characters of string, or the count of elements of list",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(len name)`, `(len tags)`},
+	}, FuncLen)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{AnyType}, Variadic: true, Return: StringType},
+		Doc:           "the strings and numbers concatenated",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(concat country "-" city)`},
+	}, FuncConcat)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{StringType, StringType}, Return: ListOf(StringType)},
+		Doc:           "the list of substrings separated by the separator",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(split tags ",")`},
+	}, FuncSplit)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{ListOf(AnyType), StringType}, Return: StringType},
+		Doc:           "the strings and numbers of list joined by the separator",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(join ("a" "b") ",")`},
+	}, FuncJoin)
+}
+
+// StringFunc applies Fn on the first param of string. The first param can be a list of strings,
+// and the result turns into a list as well, in the same way as TypeVersion
+type StringFunc struct {
+	Name string
+	// Params are the types of params after the first one, which are checked before calling Fn
+	Params []Type
+	// Optional is the count of the trailing Params which can be omitted
+	Optional int
+	Return   Type
+	Doc      string
+	Example  string
+	// Prepare is the same as FuncSpec.Prepare, which is also applied to the params not prepared before calling Fn
+	Prepare func(i int, param interface{}) (interface{}, bool, error)
+	Fn      func(s string, args []interface{}) (interface{}, error)
+}
+
+// Spec implements the interface Describer
+func (f StringFunc) Spec() FuncSpec {
+	return FuncSpec{
+		MinArgs:       1 + len(f.Params) - f.Optional,
+		MaxArgs:       1 + len(f.Params),
+		Signature:     Signature{Params: append([]Type{StringType}, f.Params...), Broadcast: true, Return: f.Return},
+		Doc:           f.Doc + ", the first param can be a list of strings",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{f.Example},
+		Prepare:       f.Prepare,
+	}
+}
+
+// Eval implements the interface Funcer
+func (f StringFunc) Eval(params ...interface{}) (interface{}, error) {
+	if l := len(params); l < 1+len(f.Params)-f.Optional || l > 1+len(f.Params) {
+		return nil, fmt.Errorf("%s: need %d to %d params, but got %d", f.Name, 1+len(f.Params)-f.Optional, 1+len(f.Params), l)
+	}
+	for i, p := range params {
+		if p == nil {
+			return nil, fmt.Errorf("%s: param %d is nil", f.Name, i+1)
+		}
+	}
+	params = Uniform(params...)
+	args := params[1:]
+	for i, arg := range args {
+		if f.Prepare != nil {
+			prepared, ok, err := f.Prepare(i+1, arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			if ok {
+				args[i] = prepared
+				continue
+			}
+		}
+		if f.Params[i] == StringType {
+			if _, ok := arg.(string); !ok {
+				return nil, fmt.Errorf("%s: param %d should be string, but got %v", f.Name, i+2, arg)
+			}
+		}
+	}
+	return f.eval(params[0], args)
+}
+
+func (f StringFunc) eval(p interface{}, args []interface{}) (interface{}, error) {
+	if v := reflect.ValueOf(p); v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		res := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			r, err := f.eval(v.Index(i).Interface(), args)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = r
+		}
+		return res, nil
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%s: element of list is nil", f.Name)
+	}
+	s, ok := p.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: param base type is not string", f.Name)
+	}
+	res, err := f.Fn(s, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", f.Name, err)
+	}
+	return res, nil
+}
+
+// runeIndex converts the index into int within [0, n]
+func runeIndex(index interface{}, n int) (int, error) {
+	i, err := toInt64(index)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("index should not be negative, but got %d", i)
+	}
+	if i > int64(n) {
+		return n, nil
+	}
+	return int(i), nil
+}
+
+// Len returns the count of characters of string, or the count of elements of list
+func Len(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("len: need one param, but got %d", l)
+	}
+	if s, ok := params[0].(*Set); ok {
+		return float64(s.Len()), nil
+	}
+	v := reflect.ValueOf(params[0])
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), nil
+	}
+	return nil, fmt.Errorf("len: need string or list, but got %v", params[0])
+}
+
+// Concat returns the strings and numbers concatenated
+func Concat(params ...interface{}) (interface{}, error) {
+	var b strings.Builder
+	for _, p := range params {
+		s, err := toString(p)
+		if err != nil {
+			return nil, fmt.Errorf("concat: %v", err)
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+// Split returns the list of substrings of the first param separated by the second param
+func Split(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("split: need two params, but got %d", l)
+	}
+	if params[0] == nil || params[1] == nil {
+		return nil, errors.New("split: params should be string")
+	}
+	params = Uniform(params...)
+	s, ok1 := params[0].(string)
+	sep, ok2 := params[1].(string)
+	if !ok1 || !ok2 {
+		return nil, errors.New("split: params should be string")
+	}
+	ss := strings.Split(s, sep)
+	res := make([]interface{}, len(ss))
+	for i, s := range ss {
+		res[i] = s
+	}
+	return res, nil
+}
+
+// Join returns the strings and numbers of the first param which is a list joined by the second param
+func Join(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("join: need two params, but got %d", l)
+	}
+	sep, ok := params[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("join: separator should be string, but got %v", params[1])
+	}
+	v := reflect.ValueOf(params[0])
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("join: need list, but got %v", params[0])
+	}
+	ss := make([]string, v.Len())
+	for i := range ss {
+		s, err := toString(v.Index(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("join: %v", err)
+		}
+		ss[i] = s
+	}
+	return strings.Join(ss, sep), nil
+}
+
+// toString converts the string or number into string, numbers are in the shortest decimal
+func toString(p interface{}) (string, error) {
+	if p == nil {
+		return "", errors.New("cannot convert nil to string")
+	}
+	switch v := Uniform(p)[0].(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("cannot convert %v to string", p)
+}
@@ -0,0 +1,97 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+)
+
+type named string
+
+func TestStringFuncs(t *testing.T) {
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncContains, []interface{}{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_0)", "iPhone"}, true, false},
+		{FuncContains, []interface{}{[]string{"iPhone", "Android"}, "iPhone"}, []interface{}{true, false}, false},
+		{FuncContains, []interface{}{named("iPhone"), "Phone"}, true, false},
+		{FuncContains, []interface{}{"iPhone", 1}, nil, true},
+		{FuncContains, []interface{}{1, "1"}, nil, true},
+		{FuncContains, []interface{}{nil, "1"}, nil, true},
+		{FuncContains, []interface{}{"iPhone"}, nil, true},
+		{FuncContains, []interface{}{[]interface{}{1, "a", nil}, "a"}, nil, true},
+		{FuncContains, []interface{}{[]interface{}{"a", []interface{}{nil}}, "a"}, nil, true},
+		{FuncStartsWith, []interface{}{"/api/v1", "/api/"}, true, false},
+		{FuncEndsWith, []interface{}{"a@example.com", "@example.com"}, true, false},
+		{FuncEndsWith, []interface{}{[]interface{}{"a@b.com", []interface{}{"c@b.com", "d@e.com"}}, "@b.com"}, []interface{}{true, []interface{}{true, false}}, false},
+		{FuncLower, []interface{}{"ÀÉÎ Straße"}, "àéî straße", false},
+		{FuncUpper, []interface{}{"éclair"}, "ÉCLAIR", false},
+		{FuncUpper, []interface{}{[]interface{}{"cn", "us"}}, []interface{}{"CN", "US"}, false},
+		{FuncLower, []interface{}{[]interface{}{"CN", nil}}, nil, true},
+		{FuncTrim, []interface{}{"　 hello\t\n"}, "hello", false},
+		{FuncTrim, []interface{}{"--hello-", "-"}, "hello", false},
+		{FuncTrim, []interface{}{"hello", "-", "-"}, nil, true},
+		{FuncSubstr, []interface{}{"你好世界", 1, 2}, "好世", false},
+		{FuncSubstr, []interface{}{"你好世界", 2}, "世界", false},
+		{FuncSubstr, []interface{}{"你好世界", 3, 10}, "界", false},
+		{FuncSubstr, []interface{}{"你好世界", 10}, "", false},
+		{FuncSubstr, []interface{}{"你好世界", -1}, nil, true},
+		{FuncSubstr, []interface{}{"你好世界", 1, -1}, nil, true},
+		{FuncSubstr, []interface{}{"你好世界", "1"}, nil, true},
+		{FuncReplace, []interface{}{"138-0000-0000", "-", ""}, "13800000000", false},
+		{FuncIndexOf, []interface{}{"你好@世界", "@"}, 2.0, false},
+		{FuncIndexOf, []interface{}{"hello", "@"}, -1.0, false},
+		{FuncLen, []interface{}{"你好"}, 2.0, false},
+		{FuncLen, []interface{}{named("abc")}, 3.0, false},
+		{FuncLen, []interface{}{[]interface{}{1, 2, 3}}, 3.0, false},
+		{FuncLen, []interface{}{1}, nil, true},
+		{FuncConcat, []interface{}{"CN", "-", 10, "-", 1.5}, "CN-10-1.5", false},
+		{FuncConcat, []interface{}{}, "", false},
+		{FuncConcat, []interface{}{"a", true}, nil, true},
+		{FuncConcat, []interface{}{"a", []interface{}{1, "a", nil}}, nil, true},
+		{FuncConcat, []interface{}{"a", nil}, nil, true},
+		{FuncSplit, []interface{}{"a,b,,c", ","}, []interface{}{"a", "b", "", "c"}, false},
+		{FuncSplit, []interface{}{"a,b", 1}, nil, true},
+		{FuncJoin, []interface{}{[]interface{}{"a", 1, 2.5}, ","}, "a,1,2.5", false},
+		{FuncJoin, []interface{}{[]string{"a", "b"}, ""}, "ab", false},
+		{FuncJoin, []interface{}{"a", ","}, nil, true},
+		{FuncJoin, []interface{}{[]interface{}{nil}, ","}, nil, true},
+		{FuncJoin, []interface{}{[]interface{}{1, "a", nil}, ","}, nil, true},
+		{FuncJoin, []interface{}{[]interface{}{"a", []interface{}{nil}}, ","}, nil, true},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+}
+
+func TestStringFuncSpec(t *testing.T) {
+	spec, err := Describe(FuncSubstr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.MinArgs != 2 || spec.MaxArgs != 3 || !spec.Broadcast || spec.Return != StringType || !spec.Deterministic {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+	if err := spec.CheckArity(4); err == nil {
+		t.Errorf("wanna arity error")
+	}
+}
@@ -0,0 +1,477 @@
+package function
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FuncNow is the function/operator keyword now
+	FuncNow = "now"
+	// FuncTypeDuration is the function/operator keyword t_duration
+	FuncTypeDuration = "t_duration"
+	// FuncTimeAdd is the function/operator keyword time_add
+	FuncTimeAdd = "time_add"
+	// FuncTimeSub is the function/operator keyword time_sub
+	FuncTimeSub = "time_sub"
+	// FuncTimeDiff is the function/operator keyword time_diff
+	FuncTimeDiff = "time_diff"
+	// FuncInZone is the function/operator keyword in_zone
+	FuncInZone = "in_zone"
+	// FuncYear is the function/operator keyword year
+	FuncYear = "year"
+	// FuncMonth is the function/operator keyword month
+	FuncMonth = "month"
+	// FuncDay is the function/operator keyword day
+	FuncDay = "day"
+	// FuncWeekday is the function/operator keyword weekday
+	FuncWeekday = "weekday"
+	// FuncHour is the function/operator keyword hour
+	FuncHour = "hour"
+	// FuncTruncate is the function/operator keyword truncate
+	FuncTruncate = "truncate"
+	// FuncTypeRFC3339 is the function/operator keyword t_rfc3339
+	FuncTypeRFC3339 = "t_rfc3339"
+	// FuncTypeUnix is the function/operator keyword t_unix
+	FuncTypeUnix = "t_unix"
+	// FuncToRFC3339 is the function/operator keyword to_rfc3339
+	FuncToRFC3339 = "to_rfc3339"
+	// FuncToUnix is the function/operator keyword to_unix
+	FuncToUnix = "to_unix"
+)
+
+// Clock returns the current time for now, which can be replaced in tests
+var Clock = time.Now
+
+func init() {
+	MustRegist(FuncNow, Now)
+	MustRegist(FuncTimeAdd, TimeAdd)
+	MustRegist(FuncTimeSub, TimeSub)
+	MustRegist(FuncTimeDiff, TimeDiff)
+	MustRegist(FuncInZone, InZone)
+	MustRegist(FuncTruncate, Truncate)
+	MustRegist(FuncTypeUnix, TypeUnix)
+	MustRegist(FuncToRFC3339, ToRFC3339)
+	MustRegist(FuncToUnix, ToUnix)
+	for _, f := range []StringFunc{
+		{
+			Name: FuncTypeDuration, Return: DurationType,
+			Doc:     "converts strings like 72h, 1h30m or 7d to duration, d is 24 hours and is only allowed as the first unit",
+			Example: `(t_duration "72h")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return parseDuration(s)
+			},
+		},
+		{
+			Name: FuncTypeRFC3339, Return: TimeType,
+			Doc:     "converts strings of RFC 3339 like 2017-09-09T12:00:00+08:00 to time, the fraction of second is optional",
+			Example: `(t_rfc3339 "2017-09-09T12:00:00+08:00")`,
+			Fn: func(s string, args []interface{}) (interface{}, error) {
+				return time.Parse(time.RFC3339Nano, s)
+			},
+		},
+	} {
+		MustRegistFuncer(f.Name, f)
+	}
+	for name, fn := range map[string]func(time.Time) int{
+		FuncYear:    func(t time.Time) int { return t.Year() },
+		FuncMonth:   func(t time.Time) int { return int(t.Month()) },
+		FuncDay:     func(t time.Time) int { return t.Day() },
+		FuncWeekday: func(t time.Time) int { return int(t.Weekday()) },
+		FuncHour:    func(t time.Time) int { return t.Hour() },
+	} {
+		MustRegist(name, datePart(name, fn))
+	}
+
+	describe(FuncSpec{
+		Signature:     Signature{Return: TimeType},
+		Doc:           "the current time, which is given by Clock",
+		Pure:          true,
+		Deterministic: false,
+		Examples:      []string{`(le (time_diff (now) signup) (t_duration "7d"))`},
+	}, FuncNow)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType, AnyType}, Return: TimeType},
+		Doc:           "the time plus the duration, which can be a string like 72h",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(time_add signup (t_duration "72h"))`},
+		Prepare:       prepareDuration,
+	}, FuncTimeAdd)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType, AnyType}, Return: TimeType},
+		Doc:           "the time minus the duration, which can be a string like 72h",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(time_sub (now) "7d")`},
+		Prepare:       prepareDuration,
+	}, FuncTimeSub)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType, TimeType}, Return: DurationType},
+		Doc:           "the duration from the second time to the first one",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(time_diff (now) signup)`},
+	}, FuncTimeDiff)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType, StringType}, Return: TimeType},
+		Doc:           "the same time in the zone of IANA name like Asia/Shanghai, which decides the date parts",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(hour (in_zone (now) "Asia/Shanghai"))`},
+		Prepare:       prepareZone,
+	}, FuncInZone)
+	for _, v := range []struct {
+		name, doc string
+	}{
+		{FuncYear, "year"},
+		{FuncMonth, "month, ranging from 1 to 12"},
+		{FuncDay, "day of month"},
+		{FuncWeekday, "day of week, ranging from 0 of Sunday to 6"},
+		{FuncHour, "hour, ranging from 0 to 23"},
+	} {
+		describe(FuncSpec{
+			Signature:     Signature{Params: []Type{TimeType}, Return: NumberType},
+			Doc:           "the " + v.doc + " of the time in its zone",
+			Pure:          true,
+			Deterministic: true,
+			Examples:      []string{fmt.Sprintf("(%s signup)", v.name)},
+		}, v.name)
+	}
+	describe(FuncSpec{
+		Signature: Signature{Params: []Type{TimeType, StringType}, Return: TimeType},
+		Doc: "the time rounded down to the unit, which is one of minute, hour, day, week (starting from Monday), month and year in the zone of the time, " +
+			"or a duration like 15m since the zero time",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(truncate (now) "day")`},
+		Prepare:       prepareTruncation,
+	}, FuncTruncate)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{NumberType}, Return: TimeType},
+		Doc:           "converts Unix timestamp of seconds to time in UTC, the fraction is kept to nanosecond",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(t_unix created_at)`},
+	}, FuncTypeUnix)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType}, Return: StringType},
+		Doc:           "formats the time in RFC 3339 with the fraction of second if any",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(to_rfc3339 (now))`},
+	}, FuncToRFC3339)
+	describe(FuncSpec{
+		Signature:     Signature{Params: []Type{TimeType}, Return: NumberType},
+		Doc:           "Unix timestamp of seconds with the fraction",
+		Pure:          true,
+		Deterministic: true,
+		Examples:      []string{`(to_unix (now))`},
+	}, FuncToUnix)
+}
+
+// parseDuration is the same as time.ParseDuration, but supports d of 24 hours as the first unit
+func parseDuration(s string) (time.Duration, error) {
+	i := strings.IndexByte(s, 'd')
+	if i < 0 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrIllegalFormat, err)
+		}
+		return d, nil
+	}
+	num := strings.TrimLeft(s[:i], "+-")
+	if len(s[:i])-len(num) > 1 || !isNumeric(strings.Replace(num, ".", "", 1)) {
+		return 0, fmt.Errorf("%w: illegal days of duration %q", ErrIllegalFormat, s)
+	}
+	days, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || math.Abs(days) > float64(math.MaxInt64/int64(24*time.Hour)) {
+		return 0, fmt.Errorf("%w: illegal days of duration %q", ErrIllegalFormat, s)
+	}
+	d := time.Duration(days * float64(24*time.Hour))
+	rest := s[i+1:]
+	if rest == "" {
+		return d, nil
+	}
+	if rest[0] == '+' || rest[0] == '-' {
+		return 0, fmt.Errorf("%w: illegal duration %q", ErrIllegalFormat, s)
+	}
+	r, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrIllegalFormat, err)
+	}
+	if strings.HasPrefix(s, "-") {
+		return d - r, nil
+	}
+	return d + r, nil
+}
+
+// toDuration converts the duration or the string like 72h to duration
+func toDuration(p interface{}) (time.Duration, error) {
+	switch v := p.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return parseDuration(v)
+	}
+	return 0, fmt.Errorf("%v is not duration", p)
+}
+
+// toTime asserts the param is time
+func toTime(p interface{}) (time.Time, error) {
+	t, ok := p.(time.Time)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%v is not time", p)
+	}
+	return t, nil
+}
+
+// prepareDuration converts the duration string which is the second param
+func prepareDuration(i int, param interface{}) (interface{}, bool, error) {
+	if s, ok := param.(string); ok && i == 1 {
+		d, err := parseDuration(s)
+		if err != nil {
+			return nil, false, err
+		}
+		return d, true, nil
+	}
+	return nil, false, nil
+}
+
+// Now returns the current time given by Clock
+func Now(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 0 {
+		return nil, fmt.Errorf("now: need no param, but got %d", l)
+	}
+	return Clock(), nil
+}
+
+// timeAndDuration converts the params of time_add and time_sub
+func timeAndDuration(name string, params []interface{}) (time.Time, time.Duration, error) {
+	if l := len(params); l != 2 {
+		return time.Time{}, 0, fmt.Errorf("%s: need two params, but got %d", name, l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("%s: %v", name, err)
+	}
+	d, err := toDuration(params[1])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, d, nil
+}
+
+// TimeAdd returns the time plus the duration
+func TimeAdd(params ...interface{}) (interface{}, error) {
+	t, d, err := timeAndDuration(FuncTimeAdd, params)
+	if err != nil {
+		return nil, err
+	}
+	return t.Add(d), nil
+}
+
+// TimeSub returns the time minus the duration
+func TimeSub(params ...interface{}) (interface{}, error) {
+	t, d, err := timeAndDuration(FuncTimeSub, params)
+	if err != nil {
+		return nil, err
+	}
+	return t.Add(-d), nil
+}
+
+// TimeDiff returns the duration from the second time to the first one
+func TimeDiff(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("time_diff: need two params, but got %d", l)
+	}
+	a, err := toTime(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("time_diff: %v", err)
+	}
+	b, err := toTime(params[1])
+	if err != nil {
+		return nil, fmt.Errorf("time_diff: %v", err)
+	}
+	return a.Sub(b), nil
+}
+
+// loadLocation loads the location by IANA name, the locations loaded are cached
+func loadLocation(name string) (*time.Location, error) {
+	loc, err := patterns.get("zone:"+name, func() (interface{}, error) {
+		return time.LoadLocation(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loc.(*time.Location), nil
+}
+
+// prepareZone loads the location which is the second param
+func prepareZone(i int, param interface{}) (interface{}, bool, error) {
+	if s, ok := param.(string); ok && i == 1 {
+		loc, err := loadLocation(s)
+		if err != nil {
+			return nil, false, err
+		}
+		return loc, true, nil
+	}
+	return nil, false, nil
+}
+
+// InZone returns the same time in the zone, which is either IANA name or the location prepared
+func InZone(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("in_zone: need two params, but got %d", l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("in_zone: %v", err)
+	}
+	loc, ok := params[1].(*time.Location)
+	if !ok {
+		s, ok := params[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("in_zone: the zone should be string, but got %v", params[1])
+		}
+		if loc, err = loadLocation(s); err != nil {
+			return nil, fmt.Errorf("in_zone: %v", err)
+		}
+	}
+	return t.In(loc), nil
+}
+
+// datePart returns the function which gets the part of date by fn
+func datePart(name string, fn func(time.Time) int) Func {
+	return func(params ...interface{}) (interface{}, error) {
+		if l := len(params); l != 1 {
+			return nil, fmt.Errorf("%s: need one param, but got %d", name, l)
+		}
+		t, err := toTime(params[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return float64(fn(t)), nil
+	}
+}
+
+// truncation is the unit of truncate, either the calendar unit or the duration
+type truncation struct {
+	unit string
+	d    time.Duration
+}
+
+func (u truncation) truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch u.unit {
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, t.Location())
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location())
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(u.d)
+}
+
+func parseTruncation(s string) (truncation, error) {
+	switch s {
+	case "minute", "hour", "day", "week", "month", "year":
+		return truncation{unit: s}, nil
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return truncation{}, err
+	}
+	if d <= 0 {
+		return truncation{}, fmt.Errorf("%w: unit of truncation should be positive, but got %s", ErrIllegalFormat, s)
+	}
+	return truncation{d: d}, nil
+}
+
+// prepareTruncation parses the unit which is the second param
+func prepareTruncation(i int, param interface{}) (interface{}, bool, error) {
+	if s, ok := param.(string); ok && i == 1 {
+		u, err := parseTruncation(s)
+		if err != nil {
+			return nil, false, err
+		}
+		return u, true, nil
+	}
+	return nil, false, nil
+}
+
+// Truncate returns the time rounded down to the unit
+func Truncate(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 2 {
+		return nil, fmt.Errorf("truncate: need two params, but got %d", l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("truncate: %v", err)
+	}
+	u, ok := params[1].(truncation)
+	if !ok {
+		s, ok := params[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("truncate: the unit should be string, but got %v", params[1])
+		}
+		if u, err = parseTruncation(s); err != nil {
+			return nil, fmt.Errorf("truncate: %w", err)
+		}
+	}
+	return u.truncate(t), nil
+}
+
+// TypeUnix converts Unix timestamp of seconds to time in UTC
+func TypeUnix(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("t_unix: need one param, but got %d", l)
+	}
+	if params[0] == nil {
+		return nil, errors.New("t_unix: param is nil")
+	}
+	sec, err := toFloat64(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("t_unix: %v", err)
+	}
+	if math.IsNaN(sec) || math.IsInf(sec, 0) {
+		return nil, fmt.Errorf("t_unix: illegal timestamp %v", sec)
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(math.Round(frac*1e9))).UTC(), nil
+}
+
+// ToRFC3339 formats the time in RFC 3339
+func ToRFC3339(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("to_rfc3339: need one param, but got %d", l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("to_rfc3339: %v", err)
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// ToUnix returns Unix timestamp of seconds with the fraction
+func ToUnix(params ...interface{}) (interface{}, error) {
+	if l := len(params); l != 1 {
+		return nil, fmt.Errorf("to_unix: need one param, but got %d", l)
+	}
+	t, err := toTime(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("to_unix: %v", err)
+	}
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9, nil
+}
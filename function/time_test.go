@@ -0,0 +1,152 @@
+package function
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	inputs := []struct {
+		s string
+		d time.Duration
+	}{
+		{"72h", 72 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"-1.5h", -90 * time.Minute},
+		{"7d", 7 * 24 * time.Hour},
+		{"1.5d", 36 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"-1d12h", -36 * time.Hour},
+		{"+2d", 48 * time.Hour},
+	}
+	for _, input := range inputs {
+		if d, err := parseDuration(input.s); err != nil || d != input.d {
+			t.Errorf("%q wanna: %v, got: %v, %v", input.s, input.d, d, err)
+		}
+	}
+	for _, s := range []string{"", "7", "d", "1e3d", "--1d", "1d-2h", "2h1d", "1dd", "99999999999d", "inf"} {
+		if _, err := parseDuration(s); !errors.Is(err, ErrIllegalFormat) {
+			t.Errorf("%q wanna: %v, got: %v", s, ErrIllegalFormat, err)
+		}
+	}
+}
+
+func TestTimeFuncs(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Sunday in UTC, but Monday in Shanghai
+	moment := time.Date(2017, 9, 10, 20, 30, 15, 500000000, time.UTC)
+	day := 24 * time.Hour
+	inputs := []struct {
+		name   string
+		params []interface{}
+		result interface{}
+		err    bool
+	}{
+		{FuncTimeAdd, []interface{}{moment, 72 * time.Hour}, moment.Add(3 * day), false},
+		{FuncTimeAdd, []interface{}{moment, "1d"}, moment.Add(day), false},
+		{FuncTimeAdd, []interface{}{moment, 1}, nil, true},
+		{FuncTimeAdd, []interface{}{"2017-09-10", "1d"}, nil, true},
+		{FuncTimeSub, []interface{}{moment, "7d"}, moment.Add(-7 * day), false},
+		{FuncTimeDiff, []interface{}{moment, moment.Add(-day)}, day, false},
+		{FuncTimeDiff, []interface{}{moment}, nil, true},
+		{FuncInZone, []interface{}{moment, "Asia/Shanghai"}, moment.In(shanghai), false},
+		{FuncInZone, []interface{}{moment, shanghai}, moment.In(shanghai), false},
+		{FuncInZone, []interface{}{moment, "Mars/Olympus"}, nil, true},
+		{FuncYear, []interface{}{moment}, 2017.0, false},
+		{FuncMonth, []interface{}{moment}, 9.0, false},
+		{FuncDay, []interface{}{moment}, 10.0, false},
+		{FuncWeekday, []interface{}{moment}, 0.0, false},
+		{FuncWeekday, []interface{}{moment.In(shanghai)}, 1.0, false},
+		{FuncHour, []interface{}{moment.In(shanghai)}, 4.0, false},
+		{FuncHour, []interface{}{"20:30"}, nil, true},
+		{FuncTruncate, []interface{}{moment, "minute"}, time.Date(2017, 9, 10, 20, 30, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "hour"}, time.Date(2017, 9, 10, 20, 0, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "day"}, time.Date(2017, 9, 10, 0, 0, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "week"}, time.Date(2017, 9, 4, 0, 0, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment.In(shanghai), "week"}, time.Date(2017, 9, 11, 0, 0, 0, 0, shanghai), false},
+		{FuncTruncate, []interface{}{moment, "month"}, time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "year"}, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "15m"}, time.Date(2017, 9, 10, 20, 30, 0, 0, time.UTC), false},
+		{FuncTruncate, []interface{}{moment, "fortnight"}, nil, true},
+		{FuncTruncate, []interface{}{moment, "-1h"}, nil, true},
+		{FuncTypeUnix, []interface{}{1505075415.5}, moment, false},
+		{FuncTypeUnix, []interface{}{int64(1505075415)}, moment.Truncate(time.Second), false},
+		{FuncTypeUnix, []interface{}{"1505075415"}, nil, true},
+		{FuncToUnix, []interface{}{moment.In(shanghai)}, 1505075415.5, false},
+		{FuncToRFC3339, []interface{}{moment.In(shanghai)}, "2017-09-11T04:30:15.5+08:00", false},
+		{FuncTypeRFC3339, []interface{}{"2017-09-11T04:30:15.5+08:00"}, nil, false},
+		{FuncTypeRFC3339, []interface{}{"2017-09-11 04:30:15"}, nil, true},
+		{FuncTypeDuration, []interface{}{[]interface{}{"72h", "7d"}}, []interface{}{3 * day, 7 * day}, false},
+		{FuncTypeDuration, []interface{}{"7 days"}, nil, true},
+	}
+	for _, input := range inputs {
+		fn, err := Get(input.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := fn(input.params...)
+		if input.err {
+			if err == nil {
+				t.Errorf("%s input: %v, shoud have errors but got none", input.name, input.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s input: %v, shoud not have error but got %s", input.name, input.params, err.Error())
+			continue
+		}
+		if tm, ok := res.(time.Time); ok {
+			if input.result != nil && (!tm.Equal(input.result.(time.Time)) || tm.Location().String() != input.result.(time.Time).Location().String()) {
+				t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+			} else if input.result == nil && !tm.Equal(moment) {
+				t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, moment, res)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(input.result, res) {
+			t.Errorf("%s input: %v wanna: %v, got: %v", input.name, input.params, input.result, res)
+		}
+	}
+}
+
+func TestNow(t *testing.T) {
+	moment := time.Date(2017, 9, 10, 20, 30, 0, 0, time.UTC)
+	defer func(clock func() time.Time) { Clock = clock }(Clock)
+	Clock = func() time.Time { return moment }
+	if res, err := Now(); err != nil || res != moment {
+		t.Errorf("wanna: %v, got: %v, %v", moment, res, err)
+	}
+	if spec, _ := Describe(FuncNow); spec.Deterministic {
+		t.Errorf("wanna now not deterministic")
+	}
+}
+
+func TestCompareDuration(t *testing.T) {
+	if r, err := (Compare{ModeLessThan}).Eval(time.Hour, 2*time.Hour); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := Between(36*time.Hour, 24*time.Hour, 48*time.Hour); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	// duration is compared with number as nanoseconds
+	if r, err := (Compare{ModeLessThan}).Eval(time.Hour, 3600.0); err != nil || r != false {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	if r, err := (Compare{ModeGreaterThan}).Eval(time.Second, 1); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := In(time.Hour, []interface{}{60 * time.Minute}); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := In(float64(time.Hour), []interface{}{time.Hour}); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if r, err := (Equal{}).Eval(time.Second, 1e9); err != nil || r != true {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+}
@@ -0,0 +1,98 @@
+package function
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type describes the type of value within expression, e.g. number, list<string>
+type Type string
+
+const (
+	// AnyType matches any type
+	AnyType Type = "any"
+	// NumberType is the type of number
+	NumberType Type = "number"
+	// StringType is the type of string
+	StringType Type = "string"
+	// BoolType is the type of boolean
+	BoolType Type = "bool"
+	// TimeType is the type of time.Time
+	TimeType Type = "time"
+	// DurationType is the type of time.Duration
+	DurationType Type = "duration"
+	// VersionType is the type of version converted by t_version
+	VersionType Type = "version"
+	// SemverType is the type of Semver converted by t_semver
+	SemverType Type = "semver"
+	// IPType is the type of IP converted by t_ip
+	IPType Type = "ip"
+	// MapType is the type of map with string keys
+	MapType Type = "map"
+	// FuncType is the type of function
+	FuncType Type = "func"
+
+	// GenericType matches any type, but all of its occurrences within a Signature must be the same type
+	GenericType Type = "T"
+	// OrderedType is same as GenericType but only matches the types which can be compared
+	OrderedType Type = "O"
+)
+
+var basicTypes = map[Type]bool{
+	AnyType:      true,
+	NumberType:   true,
+	StringType:   true,
+	BoolType:     true,
+	TimeType:     true,
+	DurationType: true,
+	VersionType:  true,
+	SemverType:   true,
+	IPType:       true,
+	MapType:      true,
+	FuncType:     true,
+	GenericType:  true,
+	OrderedType:  true,
+}
+
+// ListOf returns the type of list whose elements are type of t
+func ListOf(t Type) Type {
+	return "list<" + t + ">"
+}
+
+// Elem returns the element type if t is type of list
+func (t Type) Elem() (Type, bool) {
+	s := string(t)
+	if strings.HasPrefix(s, "list<") && strings.HasSuffix(s, ">") {
+		return Type(s[len("list<") : len(s)-1]), true
+	}
+	return "", false
+}
+
+// Ordered returns whether the values of type t can be compared by Compare
+func (t Type) Ordered() bool {
+	switch t {
+	case NumberType, StringType, TimeType, DurationType, VersionType, SemverType, IPType:
+		return true
+	}
+	return false
+}
+
+// ParseType parses type string like "number" or "list<version>"
+func ParseType(s string) (Type, error) {
+	s = strings.TrimSpace(s)
+	if s == "boolean" {
+		return BoolType, nil
+	}
+	t := Type(s)
+	if elem, ok := t.Elem(); ok {
+		e, err := ParseType(string(elem))
+		if err != nil {
+			return "", err
+		}
+		return ListOf(e), nil
+	}
+	if !basicTypes[t] {
+		return "", fmt.Errorf("unknown type %q", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,39 @@
+package function
+
+import (
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+	inputs := []struct {
+		s   string
+		t   Type
+		err bool
+	}{
+		{"number", NumberType, false},
+		{" string ", StringType, false},
+		{"boolean", BoolType, false},
+		{"list<version>", ListOf(VersionType), false},
+		{"list<list<time>>", ListOf(ListOf(TimeType)), false},
+
+		{"int", "", true},
+		{"list<int>", "", true},
+		{"list<number", "", true},
+	}
+	for _, input := range inputs {
+		r, err := ParseType(input.s)
+		if input.err {
+			if err == nil {
+				t.Errorf("input: %s, shoud have errors but got none", input.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("input: %s, shoud not have error but got %s", input.s, err.Error())
+			continue
+		}
+		if r != input.t {
+			t.Errorf("input: %s wanna: %v, got: %v", input.s, input.t, r)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+package function
+
+// the time zone database is embedded, so that the zones of in_zone, in_window and cron_match don't depend on the host
+import _ "time/tzdata"
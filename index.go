@@ -0,0 +1,270 @@
+package evaluator
+
+import (
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// index is the inverted index over the eq, in and between predicates within the top-level and of rules.
+// Each rule is indexed by one of its predicates, the rule cannot be matched unless the predicate is true,
+// so only the rules whose predicates are true, along with the rules not indexed, are the candidates
+type index struct {
+	// equal maps the variable and value onto the positions of rules
+	equal map[string]map[interface{}][]int
+	// ranges are the between predicates of each variable
+	ranges map[string]*intervals
+	// always are the positions of rules not indexed
+	always []int
+	// by is the variable which each rule is indexed by, empty for the rules not indexed
+	by []string
+}
+
+// buildIndex indexes the rules by their positions within entries
+func buildIndex(entries []*entry) *index {
+	x := &index{
+		equal:  make(map[string]map[interface{}][]int),
+		ranges: make(map[string]*intervals),
+		by:     make([]string, len(entries)),
+	}
+	for i, e := range entries {
+		x.add(i, e.Expression.exp)
+	}
+	for _, r := range x.ranges {
+		r.build()
+	}
+	return x
+}
+
+// conjuncts returns the clauses within the top-level and
+func (exp sexp) conjuncts() list {
+	if exp.canonical() != function.FuncAnd {
+		return list{exp}
+	}
+	var res list
+	for _, arg := range exp.args() {
+		res = append(res, arg.conjuncts()...)
+	}
+	return res
+}
+
+func (x *index) add(pos int, exp sexp) {
+	conjuncts := exp.conjuncts()
+	// eq and in are preferred to between, as there are fewer candidates looked up by hash
+	for _, c := range conjuncts {
+		name, values, ok := c.membership()
+		if !ok {
+			continue
+		}
+		m, ok := x.equal[name]
+		if !ok {
+			m = make(map[interface{}][]int)
+			x.equal[name] = m
+		}
+		for _, v := range values {
+			key := v.constant()
+			if rules := m[key]; len(rules) == 0 || rules[len(rules)-1] != pos {
+				m[key] = append(rules, pos)
+			}
+		}
+		x.by[pos] = name
+		return
+	}
+	for _, c := range conjuncts {
+		name, r, ok := c.numberRange()
+		if !ok {
+			continue
+		}
+		in, ok := x.ranges[name]
+		if !ok {
+			in = &intervals{}
+			x.ranges[name] = in
+		}
+		in.low = append(in.low, r.left)
+		in.high = append(in.high, r.right)
+		in.rules = append(in.rules, pos)
+		x.by[pos] = name
+		return
+	}
+	x.always = append(x.always, pos)
+}
+
+// filter looks up the index against the params lazily, the param of a variable is got only when
+// a rule indexed by it is reached
+type filter struct {
+	x  *index
+	ps Params
+	// keys are the values of the params to look up the index
+	keys map[string]lookupKey
+	// allowed maps the variable onto the positions of the rules whose predicates are true, nil means all
+	allowed map[string]map[int]bool
+}
+
+type lookupKey struct {
+	v  interface{}
+	ok bool
+}
+
+func (x *index) filter(ps Params) *filter {
+	return &filter{x: x, ps: ps, keys: make(map[string]lookupKey), allowed: make(map[string]map[int]bool)}
+}
+
+// key returns the value of the param to look up the index, false if it's missing or cannot be looked up
+func (f *filter) key(name string) (interface{}, bool) {
+	k, ok := f.keys[name]
+	if !ok {
+		v, err := f.ps.Get(name)
+		k.v, k.ok = indexKey(v)
+		k.ok = k.ok && err == nil
+		f.keys[name] = k
+	}
+	return k.v, k.ok
+}
+
+// ruledOut returns whether the predicate of the rule at pos is false.
+// The rules are never ruled out if the param is missing or cannot be looked up, so that they are evaluated exactly as before
+func (f *filter) ruledOut(pos int) bool {
+	name := f.x.by[pos]
+	if name == "" {
+		return false
+	}
+	allowed, ok := f.allowed[name]
+	if !ok {
+		allowed = f.lookup(name)
+		f.allowed[name] = allowed
+	}
+	return allowed != nil && !allowed[pos]
+}
+
+// candidates returns the positions of the candidate rules in order, which gets the params of all the variables indexed
+func (f *filter) candidates() []int {
+	res := append([]int(nil), f.x.always...)
+	for name := range f.x.equal {
+		res = f.appendAllowed(res, name)
+	}
+	for name := range f.x.ranges {
+		if _, ok := f.x.equal[name]; !ok {
+			res = f.appendAllowed(res, name)
+		}
+	}
+	sort.Ints(res)
+	unique := res[:0]
+	for i, pos := range res {
+		if i == 0 || pos != res[i-1] {
+			unique = append(unique, pos)
+		}
+	}
+	return unique
+}
+
+// appendAllowed appends the positions of the rules indexed by the variable whose predicates are true
+func (f *filter) appendAllowed(res []int, name string) []int {
+	key, ok := f.key(name)
+	if !ok {
+		for _, rules := range f.x.equal[name] {
+			res = append(res, rules...)
+		}
+		if r, ok := f.x.ranges[name]; ok {
+			res = append(res, r.rules...)
+		}
+		return res
+	}
+	res = append(res, f.x.equal[name][key]...)
+	if r, ok := f.x.ranges[name]; ok {
+		if n, isNumber := key.(float64); isNumber {
+			res = r.query(n, 0, len(r.rules), res)
+		} else {
+			res = append(res, r.rules...)
+		}
+	}
+	return res
+}
+
+// lookup returns the positions of the rules indexed by the variable whose predicates are true
+func (f *filter) lookup(name string) map[int]bool {
+	key, ok := f.key(name)
+	if !ok {
+		return nil
+	}
+	res := make(map[int]bool)
+	for _, pos := range f.x.equal[name][key] {
+		res[pos] = true
+	}
+	if r, ok := f.x.ranges[name]; ok {
+		if n, isNumber := key.(float64); isNumber {
+			for _, pos := range r.query(n, 0, len(r.rules), nil) {
+				res[pos] = true
+			}
+		} else {
+			for _, pos := range r.rules {
+				res[pos] = true
+			}
+		}
+	}
+	return res
+}
+
+// indexKey returns the value to look up the index, which is the same as compared by eq and in
+func indexKey(v interface{}) (key interface{}, ok bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			ok = false
+		}
+	}()
+	key = function.Uniform(v)[0]
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		return nil, false
+	}
+	return key, true
+}
+
+// intervals is a static interval tree, the intervals sorted by low form an implicit binary search tree,
+// whose node is the middle of range [l, r) and max records the max high within the range
+type intervals struct {
+	low, high, max []float64
+	rules          []int
+}
+
+func (in *intervals) Len() int           { return len(in.rules) }
+func (in *intervals) Less(i, j int) bool { return in.low[i] < in.low[j] }
+func (in *intervals) Swap(i, j int) {
+	in.low[i], in.low[j] = in.low[j], in.low[i]
+	in.high[i], in.high[j] = in.high[j], in.high[i]
+	in.rules[i], in.rules[j] = in.rules[j], in.rules[i]
+}
+
+func (in *intervals) build() {
+	sort.Sort(in)
+	in.max = make([]float64, len(in.rules))
+	var build func(l, r int) float64
+	build = func(l, r int) float64 {
+		if l >= r {
+			return math.Inf(-1)
+		}
+		mid := (l + r) / 2
+		in.max[mid] = math.Max(in.high[mid], math.Max(build(l, mid), build(mid+1, r)))
+		return in.max[mid]
+	}
+	build(0, len(in.rules))
+}
+
+// query appends the rules whose intervals contain v within range [l, r)
+func (in *intervals) query(v float64, l, r int, res []int) []int {
+	if l >= r {
+		return res
+	}
+	mid := (l + r) / 2
+	if in.max[mid] < v {
+		return res
+	}
+	res = in.query(v, l, mid, res)
+	if in.low[mid] > v {
+		return res
+	}
+	if in.high[mid] >= v {
+		res = append(res, in.rules[mid])
+	}
+	return in.query(v, mid+1, r, res)
+}
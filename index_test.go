@@ -0,0 +1,187 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// randomRule generates rule made of the predicates on os, country, years and level
+func randomRule(r *rand.Rand) string {
+	preds := []func() string{
+		func() string { return fmt.Sprintf(`(eq os "os%d")`, r.Intn(5)) },
+		func() string { return fmt.Sprintf(`(= "c%d" country)`, r.Intn(50)) },
+		func() string {
+			return fmt.Sprintf(`(in country ("c%d" "c%d" "c%d"))`, r.Intn(50), r.Intn(50), r.Intn(50))
+		},
+		func() string {
+			low := r.Intn(80)
+			return fmt.Sprintf(`(between years %d %d)`, low, low+r.Intn(20))
+		},
+		func() string { return fmt.Sprintf(`(ge level %d)`, r.Intn(10)) },
+		func() string { return fmt.Sprintf(`(or (eq os "os%d") (lt years %d))`, r.Intn(5), r.Intn(80)) },
+		func() string {
+			return fmt.Sprintf(`(between app_version (t_version "1.%d") (t_version "2.%d"))`, r.Intn(9), r.Intn(9))
+		},
+	}
+	// most rules target by country, some of them are not indexable
+	first := preds[1+r.Intn(3)]()
+	if r.Intn(10) == 0 {
+		first = preds[4+r.Intn(3)]()
+	}
+	n := r.Intn(3)
+	if n == 0 {
+		return first
+	}
+	expr := "(and " + first
+	for i := 0; i < n; i++ {
+		expr += " " + preds[r.Intn(len(preds))]()
+	}
+	return expr + ")"
+}
+
+func randomRuleSet(t testing.TB, r *rand.Rand, n int) *RuleSet {
+	rs := NewRuleSet()
+	for i := 0; i < n; i++ {
+		e, err := New(randomRule(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rs.Add(Rule{Name: fmt.Sprint(i), Expression: e, Priority: r.Intn(3)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rs
+}
+
+// randomParams generates params for randomRule, some of which are missing or mistyped if broken
+func randomParams(r *rand.Rand, broken bool) MapParams {
+	version, _ := Eval(fmt.Sprintf(`(t_version "1.%d")`, r.Intn(12)), nil)
+	ps := MapParams{
+		"os":          fmt.Sprintf("os%d", r.Intn(6)),
+		"country":     fmt.Sprintf("c%d", r.Intn(55)),
+		"years":       r.Intn(100),
+		"level":       r.Intn(10),
+		"app_version": version,
+	}
+	if !broken {
+		return ps
+	}
+	switch r.Intn(10) {
+	case 0:
+		delete(ps, "os")
+	case 1:
+		ps["years"] = "unknown"
+	case 2:
+		delete(ps, "years")
+	}
+	return ps
+}
+
+func bruteForce(rs *RuleSet, params Params, n int) ([]Rule, error) {
+	c := rs.compile()
+	return evaluateRules(c.entries, c.positions, newMemoParams(params), n, nil)
+}
+
+// subsetOf returns whether the errors are all within the others, as the rules ruled out by the index are not evaluated
+func subsetOf(err, others error) bool {
+	var es, all RuleErrors
+	errors.As(err, &es)
+	errors.As(others, &all)
+	for _, e := range es {
+		found := false
+		for _, o := range all {
+			if reflect.DeepEqual(e, o) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	rs := randomRuleSet(t, r, 2000)
+	for i := 0; i < 300; i++ {
+		params := randomParams(r, true)
+		want, wantErr := bruteForce(rs, params, -1)
+		got, err := rs.MatchAll(params)
+		if !reflect.DeepEqual(ruleNames(got), ruleNames(want)) {
+			t.Fatalf("params %v wanna: %v, got: %v", params, ruleNames(want), ruleNames(got))
+		}
+		if !subsetOf(err, wantErr) {
+			t.Fatalf("params %v wanna error within: %v, got: %v", params, wantErr, err)
+		}
+		want, wantErr = bruteForce(rs, params, 3)
+		got, err = rs.MatchTopN(params, 3)
+		if !reflect.DeepEqual(ruleNames(got), ruleNames(want)) || !subsetOf(err, wantErr) {
+			t.Fatalf("params %v wanna: %v, %v, got: %v, %v", params, ruleNames(want), wantErr, ruleNames(got), err)
+		}
+	}
+}
+
+func TestIndexSharingFunctionName(t *testing.T) {
+	rs := newRuleSet(t,
+		Rule{Name: "monday", Expression: mustNew(t, `(and (eq day 1) (eq country "US"))`)},
+		Rule{Name: "weekend", Expression: mustNew(t, `(in day (6 7))`)},
+	)
+	if by := rs.compile().index.by; !reflect.DeepEqual(by, []string{"day", "day"}) {
+		t.Errorf("wanna the rules indexed by day, got: %v", by)
+	}
+	rules, err := rs.MatchAll(MapParams{"day": 6, "country": "US"})
+	if got := ruleNames(rules); !reflect.DeepEqual(got, []string{"weekend"}) || err != nil {
+		t.Errorf("wanna: [weekend], got: %v, %v", got, err)
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	in := &intervals{
+		low:   []float64{5, 1, 3, 8, 2},
+		high:  []float64{9, 2, 4, 8, 10},
+		rules: []int{0, 1, 2, 3, 4},
+	}
+	in.build()
+	for v := 0.0; v <= 11; v += 0.5 {
+		var want []int
+		for i := range in.rules {
+			if in.low[i] <= v && v <= in.high[i] {
+				want = append(want, in.rules[i])
+			}
+		}
+		got := in.query(v, 0, len(in.rules), nil)
+		sort.Ints(got)
+		sort.Ints(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v wanna: %v, got: %v", v, want, got)
+		}
+	}
+}
+
+func benchmarkMatchAll(b *testing.B, n int, indexed bool) {
+	r := rand.New(rand.NewSource(4))
+	rs := randomRuleSet(b, r, n)
+	params := make([]MapParams, 100)
+	for i := range params {
+		params[i] = randomParams(r, false)
+	}
+	rs.compile()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if indexed {
+			rs.MatchAll(params[i%len(params)])
+		} else {
+			bruteForce(rs, params[i%len(params)], -1)
+		}
+	}
+}
+
+func BenchmarkMatchAllIndexed10k(b *testing.B)     { benchmarkMatchAll(b, 10000, true) }
+func BenchmarkMatchAllBruteForce10k(b *testing.B)  { benchmarkMatchAll(b, 10000, false) }
+func BenchmarkMatchAllIndexed100k(b *testing.B)    { benchmarkMatchAll(b, 100000, true) }
+func BenchmarkMatchAllBruteForce100k(b *testing.B) { benchmarkMatchAll(b, 100000, false) }
@@ -0,0 +1,605 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// ErrUnexpectedToken means the infix expression has a token where it is not allowed
+var ErrUnexpectedToken = errors.New("unexpected token")
+
+// NewInfix will return a Expression by parsing the given infix expression string, e.g.
+//   (gender = "female") and (age % 2 != 0) and app_version between t_version("2.7.1") and t_version("2.9.1")
+// It produces the same tree as the s-expression, so both forms can be evaluated and analysed alike.
+// The error of parsing is *SyntaxError as New
+func NewInfix(expr string) (Expression, error) {
+	tokens, err := lexInfix(expr)
+	if err != nil {
+		return Expression{}, err
+	}
+	if len(tokens) == 1 {
+		return Expression{}, syntaxError(expr, 0, ErrNilInput)
+	}
+	p := infixParser{tokens: tokens, src: expr}
+	exp, err := p.parseOr()
+	if err != nil {
+		return Expression{}, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return Expression{}, syntaxError(expr, t.pos, fmt.Errorf("%w %q", ErrLeftOverText, t.text))
+	}
+	exp, offset, err := exp.compile()
+	if err != nil {
+		return Expression{}, syntaxError(expr, offset, err)
+	}
+	return Expression{exp: exp, src: expr}, nil
+}
+
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// value is set for number and string
+	value interface{}
+	pos   int
+}
+
+// punctuations sorted by length, so that the longest one matches first
+var infixPunctuations = []string{
+	"==", "!=", "<>", ">=", "<=", "&&", "||",
+	"(", ")", "[", "]", ",", "+", "-", "*", "/", "%", "=", "<", ">", "!", "&", "|",
+}
+
+func lexInfix(expr string) ([]token, error) {
+	var tokens []token
+	data := []byte(expr)
+	for i := 0; i < len(data); {
+		r, width := utf8.DecodeRune(data[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += width
+		case r == '\'' || r == '"' || r == '`':
+			s, advance, err := lexInfixString(data[i:])
+			if err != nil {
+				return nil, syntaxError(expr, i, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(data[i : i+advance]), value: s, pos: i})
+			i += advance
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(data) && data[i+1] >= '0' && data[i+1] <= '9':
+			j := i
+			for j < len(data) && (data[j] >= '0' && data[j] <= '9' || data[j] == '.' ||
+				data[j] == 'e' || data[j] == 'E' ||
+				(data[j] == '+' || data[j] == '-') && (data[j-1] == 'e' || data[j-1] == 'E')) {
+				j++
+			}
+			v, err := strconv.ParseFloat(string(data[i:j]), 64)
+			if err != nil {
+				return nil, syntaxError(expr, i, fmt.Errorf("%w %q", ErrUnexpectedToken, data[i:j]))
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(data[i:j]), value: v, pos: i})
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(data) {
+				r, w := utf8.DecodeRune(data[j:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				j += w
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(data[i:j]), pos: i})
+			i = j
+		default:
+			matched := false
+			for _, p := range infixPunctuations {
+				if strings.HasPrefix(expr[i:], p) {
+					tokens = append(tokens, token{kind: tokenPunct, text: p, pos: i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, syntaxError(expr, i, fmt.Errorf("%w %q", ErrUnexpectedToken, r))
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(data)}), nil
+}
+
+// lexInfixString scans string surrounded with ', " or `, backslash escapes the next character
+func lexInfixString(data []byte) (string, int, error) {
+	delim := data[0]
+	var b strings.Builder
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			if i+1 < len(data) {
+				i++
+				b.WriteByte(data[i])
+			}
+		case delim:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(data[i])
+		}
+	}
+	return "", 0, ErrUnexpectedEnd
+}
+
+type infixParser struct {
+	tokens []token
+	cur    int
+	// src is the expression parsed, for the position of errors
+	src string
+}
+
+func (p *infixParser) peek() token {
+	return p.tokens[p.cur]
+}
+
+func (p *infixParser) next() token {
+	t := p.tokens[p.cur]
+	if t.kind != tokenEOF {
+		p.cur++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the keywords or punctuations given
+func (p *infixParser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenPunct && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(t.text, text) && (t.kind == tokenPunct || isInfixKeyword(text)) {
+			p.cur++
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *infixParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return p.unexpected()
+	}
+	return nil
+}
+
+func (p *infixParser) unexpected() error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return syntaxError(p.src, t.pos, ErrUnexpectedEnd)
+	}
+	return syntaxError(p.src, t.pos, fmt.Errorf("%w %q", ErrUnexpectedToken, t.text))
+}
+
+var infixKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "between": true, "true": true, "false": true,
+}
+
+func isInfixKeyword(s string) bool {
+	return infixKeywords[strings.ToLower(s)]
+}
+
+// call returns the tree calling function name with params, pos is the position of the call
+func call(pos int, name string, params ...sexp) sexp {
+	return sexp{i: append(list{{i: varString(name), pos: pos}}, params...), pos: pos}
+}
+
+// parseOr parses expression with the lowest precedence, and successive operands are flattened into one call
+func (p *infixParser) parseOr() (sexp, error) {
+	return p.parseSuccessive(function.FuncOr, p.parseAnd, "or", "||", "|")
+}
+
+func (p *infixParser) parseAnd() (sexp, error) {
+	return p.parseSuccessive(function.FuncAnd, p.parseNot, "and", "&&", "&")
+}
+
+func (p *infixParser) parseSuccessive(name string, operand func() (sexp, error), ops ...string) (sexp, error) {
+	exp, err := operand()
+	if err != nil {
+		return sexp{}, err
+	}
+	l := list{exp}
+	for {
+		if _, ok := p.accept(ops...); !ok {
+			break
+		}
+		exp, err := operand()
+		if err != nil {
+			return sexp{}, err
+		}
+		l = append(l, exp)
+	}
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return call(l[0].pos, name, l...), nil
+}
+
+func (p *infixParser) parseNot() (sexp, error) {
+	pos := p.peek().pos
+	if _, ok := p.accept("not", "!"); ok {
+		exp, err := p.parseNot()
+		if err != nil {
+			return sexp{}, err
+		}
+		return call(pos, function.FuncNot, exp), nil
+	}
+	return p.parseComparison()
+}
+
+var infixComparisons = map[string]string{
+	"=":  function.FuncEqual,
+	"==": function.FuncEqual,
+	"!=": function.FuncNotEqual,
+	"<>": function.FuncNotEqual,
+	">":  function.FuncGreaterThan,
+	"<":  function.FuncLessThan,
+	">=": function.FuncGreaterThanOrEqualTo,
+	"<=": function.FuncLessThanOrEqualTo,
+}
+
+func (p *infixParser) parseComparison() (sexp, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return sexp{}, err
+	}
+	if op, ok := p.accept("==", "!=", "<>", ">=", "<=", "=", "<", ">"); ok {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return sexp{}, err
+		}
+		return call(left.pos, infixComparisons[op], left, right), nil
+	}
+
+	negative := false
+	if t := p.peek(); t.kind == tokenIdent && strings.EqualFold(t.text, "not") {
+		if n := p.tokens[p.cur+1]; n.kind == tokenIdent && (strings.EqualFold(n.text, "in") || strings.EqualFold(n.text, "between")) {
+			p.cur++
+			negative = true
+		}
+	}
+	var exp sexp
+	if _, ok := p.accept("in"); ok {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return sexp{}, err
+		}
+		exp = call(left.pos, function.FuncIn, left, right)
+	} else if _, ok := p.accept("between"); ok {
+		low, err := p.parseAdditive()
+		if err != nil {
+			return sexp{}, err
+		}
+		if err := p.expect("and"); err != nil {
+			return sexp{}, err
+		}
+		high, err := p.parseAdditive()
+		if err != nil {
+			return sexp{}, err
+		}
+		exp = call(left.pos, function.FuncBetween, left, low, high)
+	} else {
+		return left, nil
+	}
+	if negative {
+		return call(left.pos, function.FuncNot, exp), nil
+	}
+	return exp, nil
+}
+
+func (p *infixParser) parseAdditive() (sexp, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *infixParser) parseMultiplicative() (sexp, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses left associative operators, successive + and * are flattened into one call
+func (p *infixParser) parseBinary(operand func() (sexp, error), ops ...string) (sexp, error) {
+	left, err := operand()
+	if err != nil {
+		return sexp{}, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return sexp{}, err
+		}
+		if op == "%" {
+			op = function.FuncModulo
+		}
+		if l, ok := left.i.(list); ok && (op == "+" || op == "*") && isCall(left, op) {
+			left = sexp{i: append(l, right)}
+		} else {
+			left = call(left.pos, op, left, right)
+		}
+	}
+}
+
+func (p *infixParser) parseUnary() (sexp, error) {
+	pos := p.peek().pos
+	if _, ok := p.accept("-"); ok {
+		exp, err := p.parseUnary()
+		if err != nil {
+			return sexp{}, err
+		}
+		if v, ok := exp.i.(float64); ok {
+			return sexp{i: -v, pos: pos}, nil
+		}
+		return call(pos, function.OperatorSubtract, sexp{i: 0.0, pos: pos}, exp), nil
+	}
+	if _, ok := p.accept("+"); ok {
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *infixParser) parsePrimary() (sexp, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		p.next()
+		if _, ok := p.accept("("); ok && t.kind == tokenString {
+			// the quoted name calls the function named as a keyword or an operator, e.g. "-"(a)
+			args, err := p.parseArguments(")")
+			if err != nil {
+				return sexp{}, err
+			}
+			return sexp{i: append(list{{i: varString(t.value.(string)), pos: t.pos}}, args...), pos: t.pos}, nil
+		}
+		return sexp{i: t.value, pos: t.pos}, nil
+	case tokenIdent:
+		if isInfixKeyword(t.text) {
+			if strings.EqualFold(t.text, "true") || strings.EqualFold(t.text, "false") {
+				p.next()
+				return sexp{i: strings.EqualFold(t.text, "true"), pos: t.pos}, nil
+			}
+			return sexp{}, p.unexpected()
+		}
+		p.next()
+		name := sexp{i: varString(t.text), pos: t.pos}
+		if _, ok := p.accept("("); !ok {
+			return name, nil
+		}
+		args, err := p.parseArguments(")")
+		if err != nil {
+			return sexp{}, err
+		}
+		return sexp{i: append(list{name}, args...), pos: t.pos}, nil
+	case tokenPunct:
+		if _, ok := p.accept("("); ok {
+			exp, err := p.parseOr()
+			if err != nil {
+				return sexp{}, err
+			}
+			return exp, p.expect(")")
+		}
+		if _, ok := p.accept("["); ok {
+			elements, err := p.parseArguments("]")
+			if err != nil {
+				return sexp{}, err
+			}
+			return sexp{i: elements, pos: t.pos}, nil
+		}
+	}
+	return sexp{}, p.unexpected()
+}
+
+// parseArguments parses comma separated expressions till the closing punctuation
+func (p *infixParser) parseArguments(closing string) (list, error) {
+	l := list{}
+	if _, ok := p.accept(closing); ok {
+		return l, nil
+	}
+	for {
+		exp, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, exp)
+		if _, ok := p.accept(closing); ok {
+			return l, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isCall returns whether exp is a call of function name, the operator alias is considered as well
+func isCall(exp sexp, names ...string) bool {
+	l, ok := exp.i.(list)
+	if !ok || len(l) == 0 {
+		return false
+	}
+	head, ok := l[0].i.(varString)
+	if !ok {
+		return false
+	}
+	for _, name := range names {
+		if string(head) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// precedence of infix operators, the bigger binds tighter
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceNot
+	precedenceComparison
+	precedenceAdditive
+	precedenceMultiplicative
+	precedencePrimary
+)
+
+var infixOperators = map[string]struct {
+	symbol     string
+	precedence int
+}{
+	function.FuncOr:                       {"or", precedenceOr},
+	function.OperatorOr:                   {"or", precedenceOr},
+	function.FuncAnd:                      {"and", precedenceAnd},
+	function.OperatorAnd:                  {"and", precedenceAnd},
+	function.FuncNot:                      {"not", precedenceNot},
+	function.OperatorNot:                  {"not", precedenceNot},
+	function.FuncEqual:                    {"=", precedenceComparison},
+	function.OperatorEqual:                {"=", precedenceComparison},
+	function.FuncNotEqual:                 {"!=", precedenceComparison},
+	function.OperatorNotEqual:             {"!=", precedenceComparison},
+	function.FuncGreaterThan:              {">", precedenceComparison},
+	function.OperatorGreaterThan:          {">", precedenceComparison},
+	function.FuncLessThan:                 {"<", precedenceComparison},
+	function.OperatorLessThan:             {"<", precedenceComparison},
+	function.FuncGreaterThanOrEqualTo:     {">=", precedenceComparison},
+	function.OperatorGreaterThanOrEqualTo: {">=", precedenceComparison},
+	function.FuncLessThanOrEqualTo:        {"<=", precedenceComparison},
+	function.OperatorLessThanOrEqualTo:    {"<=", precedenceComparison},
+	function.FuncIn:                       {"in", precedenceComparison},
+	function.FuncBetween:                  {"between", precedenceComparison},
+	function.OperatorAdd:                  {"+", precedenceAdditive},
+	function.OperatorSubtract:             {"-", precedenceAdditive},
+	function.OperatorMultiply:             {"*", precedenceMultiplicative},
+	function.OperatorDivide:               {"/", precedenceMultiplicative},
+	function.FuncModulo:                   {"%", precedenceMultiplicative},
+	function.OperatorModulo:               {"%", precedenceMultiplicative},
+}
+
+// Infix returns the Expression in the infix form, which can be parsed by NewInfix
+func (e Expression) Infix() string {
+	s, _ := e.exp.infix()
+	return s
+}
+
+// infix returns the infix form along with its precedence
+func (exp sexp) infix() (string, int) {
+	switch v := exp.i.(type) {
+	case folded:
+		return v.src.infix()
+	case list:
+		return v.infix()
+	case string:
+		return quote(v), precedencePrimary
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), precedencePrimary
+	default:
+		return fmt.Sprint(v), precedencePrimary
+	}
+}
+
+func (l list) infix() (string, int) {
+	var name string
+	if len(l) > 0 {
+		if head, ok := l[0].i.(varString); ok {
+			if _, err := function.Get(string(head)); err == nil {
+				name = string(head)
+			}
+		}
+	}
+	if name == "" {
+		return "[" + l.infixArguments() + "]", precedencePrimary
+	}
+	args := l[1:]
+	op, ok := infixOperators[name]
+	switch {
+	case !ok:
+	case op.precedence == precedenceNot:
+		if len(args) == 1 {
+			return op.symbol + " " + args[0].infixOperand(op.precedence, false), op.precedence
+		}
+	case name == function.FuncBetween:
+		if len(args) == 3 {
+			return fmt.Sprintf("%s between %s and %s",
+				args[0].infixOperand(op.precedence, true),
+				args[1].infixOperand(op.precedence, true),
+				args[2].infixOperand(op.precedence, true)), op.precedence
+		}
+	case op.precedence == precedenceComparison:
+		if len(args) == 2 {
+			return args[0].infixOperand(op.precedence, true) + " " + op.symbol + " " + args[1].infixOperand(op.precedence, true), op.precedence
+		}
+	case len(args) == 2 || (len(args) > 2 && isAssociative(name)):
+		ss := make([]string, len(args))
+		for i, arg := range args {
+			// the right operand of - / % needs parenthesis with same precedence
+			ss[i] = arg.infixOperand(op.precedence, i > 0 && !isAssociative(name))
+		}
+		return strings.Join(ss, " "+op.symbol+" "), op.precedence
+	}
+	if !isInfixIdent(name) {
+		// keywords and operators cannot be called by name, so they are quoted
+		name = quote(name)
+	}
+	return name + "(" + args.infixArguments() + ")", precedencePrimary
+}
+
+// isInfixIdent returns whether s is lexed as an identifier other than the keywords
+func isInfixIdent(s string) bool {
+	if s == "" || isInfixKeyword(s) {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || r != '.' && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l list) infixArguments() string {
+	ss := make([]string, len(l))
+	for i, e := range l {
+		ss[i], _ = e.infix()
+	}
+	return strings.Join(ss, ", ")
+}
+
+// infixOperand returns the infix form of an operand with parenthesis if needed
+func (exp sexp) infixOperand(precedence int, strict bool) string {
+	s, p := exp.infix()
+	if p < precedence || (strict && p == precedence) {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func isAssociative(name string) bool {
+	switch name {
+	case function.FuncAnd, function.OperatorAnd, function.FuncOr, function.OperatorOr,
+		function.OperatorAdd, function.OperatorMultiply:
+		return true
+	}
+	return false
+}
+
+// quote quotes the string with ", the backslash and " are escaped
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,165 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewInfix(t *testing.T) {
+	type input struct {
+		infix string
+		sexp  string
+	}
+	inputs := []input{
+		{`(gender = "female") and ((years % 2) != 0)`, `(and (eq gender "female") (ne (mod years 2) 0))`},
+		{`gender == 'female' && years % 2 <> 0`, `(and (eq gender "female") (ne (mod years 2) 0))`},
+		{`a or b and not c`, `(or a (and b (not c)))`},
+		{`a or b or c`, `(or a b c)`},
+		{`1 + 2 * 3 - 4 / 2`, `(- (+ 1 (* 2 3)) (/ 4 2))`},
+		{`1 + 2 + 3`, `(+ 1 2 3)`},
+		{`10 - 2 - 3`, `(- (- 10 2) 3)`},
+		{`-2 * -x`, `(* -2 (- 0 x))`},
+		{`app_version between t_version("2.7.1") and t_version("2.9.1") and years >= 18`,
+			`(and (between app_version (t_version "2.7.1") (t_version "2.9.1")) (ge years 18))`},
+		{`gender in ["female", "male"]`, `(in gender ("female" "male"))`},
+		{`gender not in ["female"]`, `(not (in gender ("female")))`},
+		{`years NOT BETWEEN 1 AND 2`, `(not (between years 1 2))`},
+		{`overlap(region, [2890, 3780])`, `(overlap region (2890 3780))`},
+		{`td_time("2017-09-09 12:00:00") < td_time(now)`, `(lt (td_time "2017-09-09 12:00:00") (td_time now))`},
+		{`gender in []`, `(in gender ())`},
+		{`"say \"hi\""`, `'say "hi"'`},
+		{`x`, `x`},
+	}
+	for _, input := range inputs {
+		e, err := NewInfix(input.infix)
+		if err != nil {
+			t.Errorf("infix `%s`: %v", input.infix, err)
+			continue
+		}
+		want, err := New(input.sexp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.exp.equal(want.exp) {
+			t.Errorf("infix `%s` wanna: %v, got: %v", input.infix, want.exp, e.exp)
+		}
+	}
+}
+
+func TestNewInfixBoolean(t *testing.T) {
+	e, err := NewInfix(`flag = true or not false`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := e.EvalBool(MapParams{"flag": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != true {
+		t.Errorf("wanna: %v, got: %v", true, r)
+	}
+}
+
+func TestNewInfixIncorrect(t *testing.T) {
+	type input struct {
+		infix string
+		err   error
+	}
+	inputs := []input{
+		{``, ErrNilInput},
+		{`  `, ErrNilInput},
+		{`a = `, ErrUnexpectedEnd},
+		{`(a = b`, ErrUnexpectedEnd},
+		{`"abc`, ErrUnexpectedEnd},
+		{`a = b c`, ErrLeftOverText},
+		{`a = = b`, ErrUnexpectedToken},
+		{`a between 1 or 2`, ErrUnexpectedToken},
+		{`a # b`, ErrUnexpectedToken},
+		{`f(a,)`, ErrUnexpectedToken},
+		{`and`, ErrUnexpectedToken},
+	}
+	for _, input := range inputs {
+		_, err := NewInfix(input.infix)
+		if !errors.Is(err, input.err) {
+			t.Errorf("infix `%s` wanna: %v, got: %v", input.infix, input.err, err)
+		}
+	}
+}
+
+func TestInfix(t *testing.T) {
+	type input struct {
+		sexp  string
+		infix string
+	}
+	inputs := []input{
+		{`(and (= gender "female") (!= (% years 2) 0))`, `gender = "female" and years % 2 != 0`},
+		{`(or (and a b) (not (or c d)))`, `a and b or not (c or d)`},
+		{`(and (or a b) c)`, `(a or b) and c`},
+		{`(- 10 (- 2 3))`, `10 - (2 - 3)`},
+		{`(* (+ 1 2) 3 4.5)`, `(1 + 2) * 3 * 4.5`},
+		{`(between app_version (t_version "2.7.1") (t_version "2.9.1"))`, `app_version between t_version("2.7.1") and t_version("2.9.1")`},
+		{`(in gender ("female" 'say "hi"'))`, `gender in ["female", "say \"hi\""]`},
+		{`(overlap region (2890 3780))`, `overlap(region, [2890, 3780])`},
+		{`(eq a b c)`, `eq(a, b, c)`},
+		{`(not (eq a b))`, `not a = b`},
+		{`(eq (not a) b)`, `(not a) = b`},
+		{`(eq (mod a 2) 0)`, `a % 2 = 0`},
+		{`(and x)`, `"and"(x)`},
+		{`(or (and x) y)`, `"and"(x) or y`},
+		{`(in a b c)`, `"in"(a, b, c)`},
+		{`(not a b)`, `"not"(a, b)`},
+		{`(between a b)`, `"between"(a, b)`},
+		{`(- a)`, `"-"(a)`},
+		{`(- 10 (- a))`, `10 - "-"(a)`},
+		{`(+ a)`, `"+"(a)`},
+		{`(= a b c)`, `"="(a, b, c)`},
+	}
+	for _, input := range inputs {
+		e, err := New(input.sexp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := e.Infix(); s != input.infix {
+			t.Errorf("expression `%s` wanna: %s, got: %s", input.sexp, input.infix, s)
+		}
+		back, err := NewInfix(e.Infix())
+		if err != nil {
+			t.Errorf("infix `%s`: %v", e.Infix(), err)
+			continue
+		}
+		if back.Infix() != e.Infix() {
+			t.Errorf("infix `%s` is not stable, got: %s", e.Infix(), back.Infix())
+		}
+	}
+}
+
+func TestInfixRoundTrip(t *testing.T) {
+	for _, sexp := range []string{
+		`(and (eq gender "female") (ne (mod years 2) 0))`,
+		`(and x)`,
+		`(or (and x) y)`,
+		`(in a b c)`,
+		`(in a)`,
+		`(not a b)`,
+		`(between a b)`,
+		`(- a)`,
+		`(- 10 (- a))`,
+		`(+ a)`,
+		`(eq a b c)`,
+		`(= a b c)`,
+		`(eq x "a\\")`,
+	} {
+		e, err := New(sexp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := NewInfix(e.Infix())
+		if err != nil {
+			t.Errorf("infix `%s` of %s cannot be parsed: %v", e.Infix(), sexp, err)
+			continue
+		}
+		if !back.exp.equal(e.exp) {
+			t.Errorf("infix `%s` shoud be parsed back into %s, got: %s", e.Infix(), e.exp, back.exp)
+		}
+	}
+}
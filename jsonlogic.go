@@ -0,0 +1,315 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// ErrUnsupportedOperator means the operator cannot be converted between JsonLogic and Expression
+var ErrUnsupportedOperator = errors.New("unsupported operator")
+
+// jsonLogicFuncs maps JsonLogic operators onto the registered functions.
+// The loose "==" and "!=" are not supported, as the functions are strictly typed
+var jsonLogicFuncs = map[string]string{
+	"===": function.FuncEqual,
+	"!==": function.FuncNotEqual,
+	">":   function.FuncGreaterThan,
+	">=":  function.FuncGreaterThanOrEqualTo,
+	"<":   function.FuncLessThan,
+	"<=":  function.FuncLessThanOrEqualTo,
+	"!":   function.FuncNot,
+	"and": function.FuncAnd,
+	"or":  function.FuncOr,
+	"in":  function.FuncIn,
+	"%":   function.FuncModulo,
+	"+":   function.OperatorAdd,
+	"-":   function.OperatorSubtract,
+	"*":   function.OperatorMultiply,
+	"/":   function.OperatorDivide,
+}
+
+// funcJSONLogics maps the registered functions back to JsonLogic operators
+var funcJSONLogics = map[string]string{
+	function.FuncEqual:                    "===",
+	function.OperatorEqual:                "===",
+	function.FuncNotEqual:                 "!==",
+	function.OperatorNotEqual:             "!==",
+	function.FuncGreaterThan:              ">",
+	function.OperatorGreaterThan:          ">",
+	function.FuncGreaterThanOrEqualTo:     ">=",
+	function.OperatorGreaterThanOrEqualTo: ">=",
+	function.FuncLessThan:                 "<",
+	function.OperatorLessThan:             "<",
+	function.FuncLessThanOrEqualTo:        "<=",
+	function.OperatorLessThanOrEqualTo:    "<=",
+	function.FuncNot:                      "!",
+	function.OperatorNot:                  "!",
+	function.FuncAnd:                      "and",
+	function.OperatorAnd:                  "and",
+	function.FuncOr:                       "or",
+	function.OperatorOr:                   "or",
+	function.FuncIn:                       "in",
+	function.FuncBetween:                  "<=",
+	function.FuncModulo:                   "%",
+	function.OperatorModulo:               "%",
+	function.OperatorAdd:                  "+",
+	function.OperatorSubtract:             "-",
+	function.OperatorMultiply:             "*",
+	function.OperatorDivide:               "/",
+}
+
+// FromJSONLogic converts a JsonLogic document into an Expression.
+// Operators without a counterpart are reported with ErrUnsupportedOperator and their JSON path
+func FromJSONLogic(data []byte) (Expression, error) {
+	var rule interface{}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return Expression{}, fmt.Errorf("jsonlogic: %v", err)
+	}
+	exp, err := fromJSONLogic(rule, "$")
+	if err != nil {
+		return Expression{}, err
+	}
+	exp, _, err = exp.compile()
+	if err != nil {
+		return Expression{}, fmt.Errorf("jsonlogic: %w", err)
+	}
+	return Expression{exp: exp}, nil
+}
+
+func fromJSONLogic(rule interface{}, path string) (sexp, error) {
+	switch v := rule.(type) {
+	case bool, float64, string:
+		return sexp{i: v}, nil
+	case []interface{}:
+		l := make(list, len(v))
+		for i, e := range v {
+			exp, err := fromJSONLogic(e, indexPath(path, i))
+			if err != nil {
+				return sexp{}, err
+			}
+			l[i] = exp
+		}
+		return sexp{i: l}, nil
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return sexp{}, fmt.Errorf("jsonlogic: operation at %s must have exactly one key, but got %d", path, len(v))
+		}
+		for op, args := range v {
+			return fromJSONLogicOperation(op, args, keyPath(path, op))
+		}
+	}
+	return sexp{}, fmt.Errorf("jsonlogic: %w %v at %s", ErrUnsupportedOperator, rule, path)
+}
+
+func fromJSONLogicOperation(op string, args interface{}, path string) (sexp, error) {
+	unsupported := fmt.Errorf("jsonlogic: %w %q at %s", ErrUnsupportedOperator, op, path)
+
+	if op == "var" {
+		if l, ok := args.([]interface{}); ok {
+			if len(l) != 1 {
+				return sexp{}, unsupported
+			}
+			args = l[0]
+		}
+		name, ok := args.(string)
+		if !ok || name == "" {
+			return sexp{}, unsupported
+		}
+		// the dotted path is the access of the nested maps, e.g. pie.filling turns to (get pie "filling")
+		keys := strings.Split(name, ".")
+		for _, key := range keys {
+			if key == "" {
+				return sexp{}, unsupported
+			}
+		}
+		exp := sexp{i: varString(keys[0])}
+		for _, key := range keys[1:] {
+			exp = call(0, function.FuncGet, exp, sexp{i: key})
+		}
+		return exp, nil
+	}
+
+	fn, ok := jsonLogicFuncs[op]
+	if !ok {
+		return sexp{}, unsupported
+	}
+	var ps list
+	if l, ok := args.([]interface{}); ok {
+		ps = make(list, len(l))
+		for i, arg := range l {
+			exp, err := fromJSONLogic(arg, indexPath(path, i))
+			if err != nil {
+				return sexp{}, err
+			}
+			ps[i] = exp
+		}
+	} else {
+		exp, err := fromJSONLogic(args, path)
+		if err != nil {
+			return sexp{}, err
+		}
+		ps = list{exp}
+	}
+
+	switch op {
+	case "and", "or":
+		if len(ps) == 0 {
+			return sexp{}, unsupported
+		}
+		if len(ps) == 1 {
+			return ps[0], nil
+		}
+	case "!", "in", "%", "/", "===", "!==", ">", ">=":
+		if (op == "!" && len(ps) != 1) || (op != "!" && len(ps) != 2) {
+			return sexp{}, unsupported
+		}
+		if op == "in" {
+			// substring test of JsonLogic is not supported
+			if _, ok := ps[1].i.(string); ok {
+				return sexp{}, unsupported
+			}
+		}
+	case "<":
+		if len(ps) == 3 {
+			return call(0, function.FuncAnd, call(0, fn, ps[0], ps[1]), call(0, fn, ps[1], ps[2])), nil
+		} else if len(ps) != 2 {
+			return sexp{}, unsupported
+		}
+	case "<=":
+		if len(ps) == 3 {
+			return call(0, function.FuncBetween, ps[1], ps[0], ps[2]), nil
+		} else if len(ps) != 2 {
+			return sexp{}, unsupported
+		}
+	case "-":
+		if len(ps) == 1 {
+			return call(0, fn, sexp{i: 0.0}, ps[0]), nil
+		} else if len(ps) != 2 {
+			return sexp{}, unsupported
+		}
+	case "+", "*":
+		// unary plus casts to number in JsonLogic
+		if len(ps) < 2 {
+			return sexp{}, unsupported
+		}
+	}
+	return call(0, fn, ps...), nil
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func keyPath(path, key string) string {
+	if identifierRegexp.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+// JSONLogic converts the Expression into a JsonLogic document.
+// Functions without a JsonLogic counterpart are reported with ErrUnsupportedOperator
+func (e Expression) JSONLogic() ([]byte, error) {
+	rule, err := e.exp.jsonLogic()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rule); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+func (exp sexp) jsonLogic() (interface{}, error) {
+	switch v := exp.i.(type) {
+	case folded:
+		return v.src.jsonLogic()
+	case varString:
+		// the symbol is read from params first as the argument of function
+		return map[string]interface{}{"var": string(v)}, nil
+	case list:
+		var name string
+		if len(v) > 0 {
+			if head, ok := v[0].i.(varString); ok {
+				if _, err := function.Get(string(head)); err == nil {
+					name = string(head)
+				}
+			}
+		}
+		if name == "" {
+			array := make([]interface{}, len(v))
+			for i, e := range v {
+				r, err := e.jsonLogic()
+				if err != nil {
+					return nil, err
+				}
+				array[i] = r
+			}
+			return array, nil
+		}
+		if name == function.FuncGet {
+			if path, ok := exp.dottedVar(); ok {
+				return map[string]interface{}{"var": path}, nil
+			}
+		}
+		op, ok := funcJSONLogics[name]
+		if !ok {
+			return nil, fmt.Errorf("jsonlogic: %w %q", ErrUnsupportedOperator, name)
+		}
+		args := make([]interface{}, len(v)-1)
+		for i, e := range v[1:] {
+			r, err := e.jsonLogic()
+			if err != nil {
+				return nil, err
+			}
+			args[i] = r
+		}
+		if name == function.FuncBetween {
+			if len(args) != 3 {
+				return nil, fmt.Errorf("jsonlogic: between: need three params, but got %d", len(args))
+			}
+			args[0], args[1] = args[1], args[0]
+		}
+		return map[string]interface{}{op: args}, nil
+	default:
+		return v, nil
+	}
+}
+
+// dottedVar returns the dotted path of var if exp is the access of the nested maps, e.g. (get pie "filling") is pie.filling
+func (exp sexp) dottedVar() (string, bool) {
+	if v, ok := exp.i.(varString); ok {
+		if strings.Contains(string(v), ".") {
+			return "", false
+		}
+		return string(v), true
+	}
+	if name, ok := exp.function(); !ok || name != function.FuncGet {
+		return "", false
+	}
+	args := exp.args()
+	if len(args) != 2 {
+		return "", false
+	}
+	key, ok := args[1].i.(string)
+	if !ok || key == "" || strings.Contains(key, ".") {
+		return "", false
+	}
+	base, ok := args[0].dottedVar()
+	if !ok {
+		return "", false
+	}
+	return base + "." + key, true
+}
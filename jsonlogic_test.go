@@ -0,0 +1,139 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nullne/evaluator/function"
+)
+
+func TestJSONLogicSuite(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/jsonlogic.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []interface{}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	var passed, unsupported int
+	for _, c := range cases {
+		tc, ok := c.([]interface{})
+		if !ok {
+			// comment
+			continue
+		}
+		rule, _ := json.Marshal(tc[0])
+		params, _ := tc[1].(map[string]interface{})
+		// the fourth element is the reason why the case is not supported
+		if len(tc) == 4 {
+			unsupported++
+			e, err := FromJSONLogic(rule)
+			if errors.Is(err, ErrUnsupportedOperator) {
+				continue
+			} else if err != nil {
+				t.Errorf("rule: %s, wanna: %v, got: %v", rule, ErrUnsupportedOperator, err)
+				continue
+			}
+			if res, err := e.Eval(MapParams(params)); err == nil {
+				t.Errorf("rule: %s is unsupported by %s, shoud have errors but got %v", rule, tc[3], res)
+			}
+			continue
+		}
+		e, err := FromJSONLogic(rule)
+		if err != nil {
+			t.Errorf("rule: %s, shoud not have error but got %s", rule, err.Error())
+			continue
+		}
+		res, err := e.Eval(MapParams(params))
+		if err != nil {
+			t.Errorf("rule: %s, shoud not have error but got %s", rule, err.Error())
+			continue
+		}
+		got, want := function.Uniform(res), function.Uniform(tc[2])
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("rule: %s wanna: %v, got: %v", rule, want, got)
+			continue
+		}
+		passed++
+	}
+	if passed == 0 || unsupported == 0 {
+		t.Errorf("passed: %d, unsupported: %d", passed, unsupported)
+	}
+}
+
+func TestJSONLogicUnsupported(t *testing.T) {
+	inputs := []struct {
+		rule string
+		path string
+	}{
+		{`{"if": [true, 1, 2]}`, `$.if`},
+		{`{"and": [true, {"or": [false, {"max": [1, 2]}]}]}`, `$.and[1].or[1].max`},
+		{`{"!": {"var": ["a", 1]}}`, `$["!"].var`},
+		{`{"in": ["Spring", "Springfield"]}`, `$.in`},
+		{`{"===": [{"var": "a"}, null]}`, `$["==="][1]`},
+		{`{"===": [{"var": "pie..filling"}, 1]}`, `$["==="][0].var`},
+		{`{"==": [{"var": "a"}, 1]}`, `$["=="]`},
+		{`{"and": [{"!=": [{"var": "a"}, "1"]}]}`, `$.and[0]["!="]`},
+	}
+	for _, input := range inputs {
+		_, err := FromJSONLogic([]byte(input.rule))
+		if !errors.Is(err, ErrUnsupportedOperator) {
+			t.Errorf("rule: %s, should be unsupported but got %v", input.rule, err)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), " at "+input.path) {
+			t.Errorf("rule: %s, wanna path: %s, got: %s", input.rule, input.path, err.Error())
+		}
+	}
+}
+
+func TestJSONLogicRoundTrip(t *testing.T) {
+	inputs := []struct {
+		expr string
+		rule string
+	}{
+		{`(eq gender "male")`, `{"===":[{"var":"gender"},"male"]}`},
+		{`(and (between years 18 80) (in region (1 2)))`, `{"and":[{"<=":[18,{"var":"years"},80]},{"in":[{"var":"region"},[1,2]]}]}`},
+		{`(! (>= (% years 2) 1))`, `{"!":[{">=":[{"%":[{"var":"years"},2]},1]}]}`},
+		{`(eq (get (get pie "filling") "name") "apple")`, `{"===":[{"var":"pie.filling.name"},"apple"]}`},
+		{`(and (eq day 3) (in keys ("a")))`, `{"and":[{"===":[{"var":"day"},3]},{"in":[{"var":"keys"},["a"]]}]}`},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule, err := e.JSONLogic()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(rule) != input.rule {
+			t.Errorf("expression `%s` wanna: %s, got: %s", input.expr, input.rule, rule)
+		}
+		back, err := FromJSONLogic(rule)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		params := MapParams{"gender": "male", "years": 20, "region": 2, "pie": map[string]interface{}{"filling": map[string]string{"name": "apple"}}, "day": 3, "keys": "a"}
+		r1, err1 := e.Eval(params)
+		r2, err2 := back.Eval(params)
+		if r1 != r2 || (err1 == nil) != (err2 == nil) {
+			t.Errorf("expression `%s` wanna: %v, got: %v", input.expr, r1, r2)
+		}
+	}
+
+	e, err := New(`(eq (t_version app_version) (t_version "2.7.1"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.JSONLogic(); !errors.Is(err, ErrUnsupportedOperator) {
+		t.Errorf("t_version should be unsupported but got %v", err)
+	}
+}
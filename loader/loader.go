@@ -0,0 +1,524 @@
+// Package loader loads the rules from the files within a directory into evaluator.RuleSet, and reloads them on change.
+//
+// A file of the extension .sexp holds one s-expression and .infix holds one infix expression,
+// the rule is named after the path relative to the directory without the extension, e.g. ios/adult for ios/adult.sexp.
+// A file of the extension .json holds a list of rules:
+//
+//	[{"name": "adult", "expr": "(ge age 18)", "priority": 10, "metadata": {"discount": 0.8}}]
+//
+// where syntax "infix" means the expr is an infix expression, and metadata becomes the payload of the rule.
+// A file of the extension .yaml or .yml holds the same list in YAML:
+//
+//	# rules/vip.yaml
+//	- name: adult
+//	  expr: (ge age 18)
+//	  priority: 10
+//	  metadata:
+//	    discount: 0.8
+//
+// which is parsed without the dependency beyond the standard library, so the YAML features beyond the common subset,
+// such as anchors, aliases, tags and multi-line quoted scalars, are reported as ErrUnsupportedFormat.
+// The syntax error of expr is located within the file rather than the expr.
+// The other files and those starting with dot are ignored
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/nullne/evaluator"
+)
+
+const (
+	// ExtSExpression is the extension of the file holding one s-expression
+	ExtSExpression = ".sexp"
+	// ExtInfix is the extension of the file holding one infix expression
+	ExtInfix = ".infix"
+	// ExtJSON is the extension of the file holding a list of rules
+	ExtJSON = ".json"
+	// ExtYAML and ExtYML are the extensions of the YAML file holding a list of rules
+	ExtYAML = ".yaml"
+	ExtYML  = ".yml"
+)
+
+// SyntaxInfix is the syntax of the infix expression within the list of rules
+const SyntaxInfix = "infix"
+
+var (
+	// ErrNoName means the rule within the list has no name
+	ErrNoName = errors.New("rule without name")
+	// ErrUnknownSyntax means the syntax of rule is neither s-expression nor infix
+	ErrUnknownSyntax = errors.New("unknown syntax")
+	// ErrUnsupportedFormat means the YAML file uses the features beyond the subset supported, e.g. anchors
+	ErrUnsupportedFormat = errors.New("unsupported format")
+)
+
+// FileError is the error of loading the file, the last good version of the file is kept if any
+type FileError struct {
+	Path string
+	// Rule is the name of rule within the list, empty for the error of the whole file
+	Rule string
+	// Pos is where the error occurs within the file, zero means unknown
+	Pos evaluator.Position
+	Err error
+}
+
+func (e *FileError) Error() string {
+	s := e.Path
+	if e.Pos.Line > 0 {
+		s += ":" + e.Pos.String()
+	}
+	if e.Rule != "" {
+		s += fmt.Sprintf(": rule %q", e.Rule)
+	}
+	return fmt.Sprintf("%s: %v", s, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Errors collects all the FileError of loading
+type Errors []*FileError
+
+func (es Errors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// RuleJSON is the rule within the list of the JSON or YAML file
+type RuleJSON struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+	// Syntax is either empty for s-expression or infix
+	Syntax   string                 `json:"syntax,omitempty"`
+	Priority int                    `json:"priority,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Loader loads the rules from the directory, it's safe for concurrent use.
+// Each reload builds a new RuleSet and swaps it in at once, so the RuleSet got is never half updated
+type Loader struct {
+	dir string
+	set atomic.Value
+	// mu serializes the reloading
+	mu    sync.Mutex
+	files map[string]*file
+	// conflicts are the rules named after the rules of other files
+	conflicts []*FileError
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// file is the state of the file loaded
+type file struct {
+	modTime time.Time
+	size    int64
+	content []byte
+	// rules are of the last good version
+	rules []evaluator.Rule
+	err   *FileError
+}
+
+// New loads the rules from the directory. The broken files are returned as Errors along with the Loader,
+// which holds the rules of the other files. Any other error means the directory cannot be read
+func New(dir string) (*Loader, error) {
+	l := &Loader{dir: dir, files: make(map[string]*file)}
+	l.set.Store(evaluator.NewRuleSet())
+	if _, err := l.reload(); err != nil {
+		var es Errors
+		if !errors.As(err, &es) {
+			return nil, err
+		}
+		return l, err
+	}
+	return l, nil
+}
+
+// RuleSet returns the rules loaded currently. It's replaced rather than modified on reloading,
+// so the caller must not modify it
+func (l *Loader) RuleSet() *evaluator.RuleSet {
+	return l.set.Load().(*evaluator.RuleSet)
+}
+
+// Reload reloads the files changed since the last loading, the broken files are returned as Errors
+func (l *Loader) Reload() error {
+	_, err := l.reload()
+	return err
+}
+
+// reload reloads the files changed and swaps in the new RuleSet if anything changed
+func (l *Loader) reload() (changed bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := make(map[string]bool)
+	err = filepath.Walk(l.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == l.dir {
+				return err
+			}
+			// the file may be removed during walking
+			return nil
+		}
+		if path != l.dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !supported(path) {
+			return nil
+		}
+		seen[path] = true
+		f, ok := l.files[path]
+		if ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			return nil
+		}
+		if !ok {
+			f = &file{}
+			l.files[path] = f
+		}
+		f.modTime, f.size = info.ModTime(), info.Size()
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			f.err = &FileError{Path: path, Err: err}
+			changed = true
+			return nil
+		}
+		if ok && f.err == nil && bytes.Equal(content, f.content) {
+			return nil
+		}
+		changed = true
+		f.content = content
+		rules, ferr := l.parse(path, content)
+		if ferr != nil {
+			f.err = ferr
+			return nil
+		}
+		f.rules, f.err = rules, nil
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("loader: %w", err)
+	}
+	for path := range l.files {
+		if !seen[path] {
+			delete(l.files, path)
+			changed = true
+		}
+	}
+	if changed {
+		l.set.Store(l.build())
+	}
+	return changed, l.errors()
+}
+
+// build builds the RuleSet from the files in the order of path, the rule named after an existing one is ignored
+func (l *Loader) build() *evaluator.RuleSet {
+	rs := evaluator.NewRuleSet()
+	l.conflicts = nil
+	for _, path := range l.paths() {
+		for _, r := range l.files[path].rules {
+			// the rules of a file are unique, so the error comes from another file
+			if err := rs.Add(r); err != nil {
+				l.conflicts = append(l.conflicts, &FileError{Path: path, Rule: r.Name, Err: err})
+			}
+		}
+	}
+	return rs
+}
+
+func (l *Loader) paths() []string {
+	paths := make([]string, 0, len(l.files))
+	for path := range l.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// errors returns the errors of the files in the order of path followed by the conflicts, nil if there is none
+func (l *Loader) errors() error {
+	var es Errors
+	for _, path := range l.paths() {
+		if err := l.files[path].err; err != nil {
+			es = append(es, err)
+		}
+	}
+	es = append(es, l.conflicts...)
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+func supported(path string) bool {
+	switch filepath.Ext(path) {
+	case ExtSExpression, ExtInfix, ExtJSON, ExtYAML, ExtYML:
+		return true
+	}
+	return false
+}
+
+// parse parses the rules within the content of file
+func (l *Loader) parse(path string, content []byte) ([]evaluator.Rule, *FileError) {
+	ext := filepath.Ext(path)
+	switch ext {
+	case ExtJSON:
+		return parseJSON(path, content)
+	case ExtYAML, ExtYML:
+		return parseYAML(path, content)
+	}
+	name, err := filepath.Rel(l.dir, strings.TrimSuffix(path, ext))
+	if err != nil {
+		return nil, &FileError{Path: path, Err: err}
+	}
+	syntax := ""
+	if ext == ExtInfix {
+		syntax = SyntaxInfix
+	}
+	// the trailing spaces are trimmed only, so that the positions of errors are kept
+	e, err := compile(strings.TrimRightFunc(string(content), unicode.IsSpace), syntax)
+	if err != nil {
+		ferr := &FileError{Path: path, Err: err}
+		var se *evaluator.SyntaxError
+		if errors.As(err, &se) {
+			ferr.Pos, ferr.Err = se.Pos, se.Err
+		}
+		return nil, ferr
+	}
+	return []evaluator.Rule{{Name: filepath.ToSlash(name), Expression: e}}, nil
+}
+
+func parseJSON(path string, content []byte) ([]evaluator.Rule, *FileError) {
+	var list []RuleJSON
+	if err := json.Unmarshal(content, &list); err != nil {
+		ferr := &FileError{Path: path, Err: err}
+		var se *json.SyntaxError
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &se) {
+			// the offset is after the invalid character
+			ferr.Pos = position(content, int(se.Offset)-1)
+		} else if errors.As(err, &te) {
+			// the offset is after the value
+			ferr.Pos = position(content, int(te.Offset))
+		}
+		return nil, ferr
+	}
+	return buildRules(path, content, list, func(i int) []int {
+		return jsonExprOffsets(content, i)
+	})
+}
+
+func parseYAML(path string, content []byte) ([]evaluator.Rule, *FileError) {
+	root, offset, err := parseYAMLNode(content)
+	if err != nil {
+		return nil, &FileError{Path: path, Pos: position(content, offset), Err: err}
+	}
+	list, exprs, offset, err := yamlRules(root)
+	if err != nil {
+		return nil, &FileError{Path: path, Pos: position(content, offset), Err: err}
+	}
+	return buildRules(path, content, list, func(i int) []int {
+		return exprs[i]
+	})
+}
+
+// buildRules compiles the list of rules within the file, exprOffsets returns the offsets within content
+// of the bytes of the expr of the i-th rule, which locates the syntax error
+func buildRules(path string, content []byte, list []RuleJSON, exprOffsets func(i int) []int) ([]evaluator.Rule, *FileError) {
+	rules := make([]evaluator.Rule, 0, len(list))
+	names := make(map[string]bool)
+	for i, r := range list {
+		if r.Name == "" {
+			return nil, &FileError{Path: path, Err: fmt.Errorf("%w at index %d", ErrNoName, i)}
+		}
+		if names[r.Name] {
+			return nil, &FileError{Path: path, Rule: r.Name, Err: evaluator.ErrRuleExists}
+		}
+		names[r.Name] = true
+		e, err := compile(r.Expr, r.Syntax)
+		if err != nil {
+			ferr := &FileError{Path: path, Rule: r.Name, Err: err}
+			var se *evaluator.SyntaxError
+			if errors.As(err, &se) {
+				if offsets := exprOffsets(i); se.Pos.Offset < len(offsets) {
+					ferr.Pos, ferr.Err = position(content, offsets[se.Pos.Offset]), se.Err
+				}
+			}
+			return nil, ferr
+		}
+		rule := evaluator.Rule{Name: r.Name, Expression: e, Priority: r.Priority}
+		if r.Metadata != nil {
+			rule.Payload = r.Metadata
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// jsonExprOffsets returns the offsets within content of the bytes of the expr of the i-th rule decoded,
+// along with the offset of the closing quote. It's nil if the expr is not found
+func jsonExprOffsets(content []byte, i int) []int {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil
+	}
+	for n := 0; n < i; n++ {
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			return nil
+		}
+	}
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil
+	}
+	var offsets []int
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		// the value starts after the colon
+		start := int(dec.InputOffset())
+		for start < len(content) && (content[start] == ':' || unicode.IsSpace(rune(content[start]))) {
+			start++
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil
+		}
+		// the field is matched case-insensitively and the last one wins, as json.Unmarshal does
+		if k, _ := key.(string); strings.EqualFold(k, "expr") && len(value) > 0 && value[0] == '"' {
+			offsets = stringOffsets(content, start)
+		}
+	}
+	return offsets
+}
+
+// stringOffsets returns the offsets of the bytes decoded from the JSON string starting at start, along with the closing quote
+func stringOffsets(content []byte, start int) []int {
+	var offsets []int
+	for i := start + 1; i < len(content); {
+		switch content[i] {
+		case '"':
+			return append(offsets, i)
+		case '\\':
+			n, size := 2, 1
+			if i+5 < len(content) && content[i+1] == 'u' {
+				r := hexRune(content[i+2 : i+6])
+				n = 6
+				if utf16.IsSurrogate(r) && i+11 < len(content) && content[i+6] == '\\' && content[i+7] == 'u' {
+					if pair := utf16.DecodeRune(r, hexRune(content[i+8:i+12])); pair != unicode.ReplacementChar {
+						r, n = pair, 12
+					}
+				}
+				if utf16.IsSurrogate(r) {
+					r = unicode.ReplacementChar
+				}
+				size = utf8.RuneLen(r)
+			}
+			for j := 0; j < size; j++ {
+				offsets = append(offsets, i)
+			}
+			i += n
+		default:
+			offsets = append(offsets, i)
+			i++
+		}
+	}
+	return offsets
+}
+
+// hexRune returns the rune of 4 hexadecimal digits, the replacement character if illegal
+func hexRune(b []byte) rune {
+	r, err := strconv.ParseUint(string(b), 16, 16)
+	if err != nil {
+		return unicode.ReplacementChar
+	}
+	return rune(r)
+}
+
+func compile(expr, syntax string) (evaluator.Expression, error) {
+	switch syntax {
+	case "":
+		return evaluator.New(expr)
+	case SyntaxInfix:
+		return evaluator.NewInfix(expr)
+	}
+	return evaluator.Expression{}, fmt.Errorf("%w %q", ErrUnknownSyntax, syntax)
+}
+
+// position returns the position of offset within content
+func position(content []byte, offset int) evaluator.Position {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	before := content[:offset]
+	return evaluator.Position{
+		Offset: offset,
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: offset - bytes.LastIndexByte(before, '\n'),
+	}
+}
+
+// Watch polls the directory every interval and reloads the files changed in the background until Close.
+// report is called with the result of each reload changing anything, the error is nil if no file is broken
+func (l *Loader) Watch(interval time.Duration, report func(error)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing != nil {
+		return
+	}
+	l.closing, l.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.closing:
+				return
+			case <-ticker.C:
+				changed, err := l.reload()
+				if changed && report != nil {
+					report(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops watching and waits for the reloading in progress
+func (l *Loader) Close() {
+	l.mu.Lock()
+	closing, done := l.closing, l.done
+	l.mu.Unlock()
+	if closing == nil {
+		return
+	}
+	l.closeOnce.Do(func() {
+		close(closing)
+	})
+	<-done
+}
@@ -0,0 +1,280 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nullne/evaluator"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "loader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// writeFile replaces the file by renaming, and bumps the modification time so that the change is seen however fast it is
+func writeFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var mod time.Time
+	if info, err := os.Stat(path); err == nil {
+		mod = info.ModTime()
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path))
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(tmp); err == nil && !info.ModTime().After(mod) {
+		mod = mod.Add(time.Second)
+		if err := os.Chtimes(tmp, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ruleNames(rules []evaluator.Rule) string {
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestLoader(t *testing.T) {
+	dir := tempDir(t)
+	writeFile(t, dir, "adult.sexp", "(ge years 18)\n")
+	writeFile(t, dir, "ios/mobile.infix", `os in ["ios", "ipados"]`)
+	writeFile(t, dir, "vip.json", `[
+	{"name": "gold", "expr": "(eq level \"gold\")", "priority": 10, "metadata": {"discount": 0.8}},
+	{"name": "silver", "expr": "level = \"silver\"", "syntax": "infix"}
+]`)
+	writeFile(t, dir, ".hidden.sexp", "(")
+	writeFile(t, dir, "README.md", "not a rule")
+
+	l, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := l.RuleSet()
+	if got := ruleNames(rs.Rules()); got != "gold adult ios/mobile silver" {
+		t.Errorf("wanna: gold adult ios/mobile silver, got: %s", got)
+	}
+	rules, err := rs.MatchAll(evaluator.MapParams{"years": 20, "os": "ios", "level": "gold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ruleNames(rules); got != "gold adult ios/mobile" {
+		t.Errorf("wanna: gold adult ios/mobile, got: %s", got)
+	}
+	if payload := rules[0].Payload.(map[string]interface{}); payload["discount"] != 0.8 {
+		t.Errorf("wanna discount: 0.8, got: %v", payload)
+	}
+
+	// a broken file keeps the last good version
+	writeFile(t, dir, "adult.sexp", "(and\n  (ge years 21))\n  (lt years 60)\n")
+	err = l.Reload()
+	var es Errors
+	if !errors.As(err, &es) || len(es) != 1 {
+		t.Fatalf("wanna one error, got: %v", err)
+	}
+	if want := filepath.Join(dir, "adult.sexp") + ":3:3: left over text"; es[0].Error() != want || !errors.Is(es[0], evaluator.ErrLeftOverText) {
+		t.Errorf("wanna: %s, got: %v", want, es[0])
+	}
+	if l.RuleSet() == rs {
+		t.Errorf("wanna the RuleSet rebuilt")
+	}
+	if r, ok := l.RuleSet().Get("adult"); !ok || r.Expression.String() != "(ge years 18)" {
+		t.Errorf("wanna the last good version of adult, got: %v, %v", r.Expression, ok)
+	}
+
+	// fixing the file, breaking the list and removing a file
+	writeFile(t, dir, "adult.sexp", "(ge years 21)")
+	writeFile(t, dir, "vip.json", `[{"name": "gold", "expr": "(eq level"}]`)
+	if err := os.Remove(filepath.Join(dir, "ios/mobile.infix")); err != nil {
+		t.Fatal(err)
+	}
+	err = l.Reload()
+	if !errors.As(err, &es) || len(es) != 1 || es[0].Rule != "gold" || !errors.Is(es[0], evaluator.ErrLeftOverText) {
+		t.Errorf("wanna the error of rule gold, got: %v", err)
+	}
+	rs = l.RuleSet()
+	if got := ruleNames(rs.Rules()); got != "gold adult silver" {
+		t.Errorf("wanna: gold adult silver, got: %s", got)
+	}
+	if r, _ := rs.Get("adult"); r.Expression.String() != "(ge years 21)" {
+		t.Errorf("wanna adult reloaded, got: %v", r.Expression)
+	}
+
+	// the rule named after one of another file is ignored, and nothing changes until the files change
+	writeFile(t, dir, "vip.json", `[{"name": "adult", "expr": "(eq years 1)"}]`)
+	err = l.Reload()
+	if !errors.As(err, &es) || len(es) != 1 || !errors.Is(es[0], evaluator.ErrRuleExists) {
+		t.Errorf("wanna the conflict of rule adult, got: %v", err)
+	}
+	rs = l.RuleSet()
+	if err := l.Reload(); err == nil || l.RuleSet() != rs {
+		t.Errorf("wanna the RuleSet unchanged along with the conflict, got: %v", err)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	type input struct {
+		name, content string
+		err           string
+	}
+	inputs := []input{
+		{"a.sexp", "(eq a \"1)", "a.sexp:1:7: unexpected end"},
+		{"b.infix", "a = 1 and\n  b >", "b.infix:2:6: unexpected end"},
+		{"c.json", "[{\"name\": \"c\",\n  \"expr\": (eq a 1)}]", "c.json:2:11: invalid character '(' looking for beginning of value"},
+		{"d.json", `[{"name": "d", "expr": "(eq a 1)", "priority": "high"}]`, "d.json:1:54: json: cannot unmarshal string"},
+		{"e.json", `[{"expr": "(eq a 1)"}]`, "e.json: rule without name at index 0"},
+		{"f.json", `[{"name": "f", "expr": "(eq a 1)", "syntax": "lisp"}]`, `f.json: rule "f": unknown syntax "lisp"`},
+		{"g.json", `[{"name": "g", "expr": "(eq a 1)"}, {"name": "g", "expr": "(eq a 2)"}]`, `g.json: rule "g": rule exists`},
+		{"j.json", "[{\"name\": \"j\",\n  \"expr\": \"(eq a \\\"1)\"}]", `j.json:2:18: rule "j": unexpected end`},
+		{"k.json", "[{\"name\": \"k\", \"expr\": \"(eq a 1)\"},\n {\"metadata\": {\"expr\": 1}, \"name\": \"k2\", \"expr\": \"(eq \\u00e9 \\n 1))\"}]", `k.json:2:67: rule "k2": unmatched parenthesis`},
+		{"l.json", `[{"name": "l", "syntax": "infix", "expr": "a = 1 and\n  b >"}]`, `l.json:1:60: rule "l": unexpected end`},
+		{"h.yaml", "- name: h\n  expr: (eq a \"1)\n", `h.yaml:2:15: rule "h": unexpected end`},
+		{"i.yml", "- name: &i i\n  expr: (eq a 1)\n", "i.yml:1:9: unsupported format: anchors, aliases and tags"},
+		{"m.yaml", "- name: m\n  expr: |\n    (and\n      (eq a 1)))\n", `m.yaml:4:16: rule "m": unmatched parenthesis`},
+		{"n.yaml", "name: n\nexpr: (eq a 1)\n", "n.yaml:1:1: illegal YAML: need a list of rules"},
+		{"o.yaml", "- name: o\n  expr: (eq a 1)\n  priority: high\n", "o.yaml:3:13: illegal YAML: priority should be integer"},
+	}
+	for _, input := range inputs {
+		dir := tempDir(t)
+		writeFile(t, dir, input.name, input.content)
+		l, err := New(dir)
+		if l == nil || l.RuleSet().Len() != 0 || err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, input.err)) {
+			t.Errorf("file %s wanna: %s, got: %v", input.name, input.err, err)
+		}
+	}
+	if _, err := New(filepath.Join(tempDir(t), "missing")); err == nil {
+		t.Errorf("wanna error of missing directory")
+	}
+}
+
+func TestLoaderYAML(t *testing.T) {
+	dir := tempDir(t)
+	writeFile(t, dir, "vip.yaml", `# rules of vip
+- name: gold
+  expr: (eq level "gold")
+  priority: 10
+  metadata:
+    discount: 0.8
+    tags: [vip, gold]
+- name: silver
+  syntax: infix
+  expr: >-
+    level = "silver"
+    and years >= 18
+`)
+	writeFile(t, dir, "empty.yml", "# nothing yet\n")
+	l, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := l.RuleSet()
+	if got := ruleNames(rs.Rules()); got != "gold silver" {
+		t.Errorf("wanna: gold silver, got: %s", got)
+	}
+	rules, err := rs.MatchAll(evaluator.MapParams{"level": "silver", "years": 20})
+	if err != nil || ruleNames(rules) != "silver" {
+		t.Errorf("wanna: silver, got: %s, %v", ruleNames(rules), err)
+	}
+	gold, _ := rs.Get("gold")
+	if payload := gold.Payload.(map[string]interface{}); payload["discount"] != 0.8 || len(payload["tags"].([]interface{})) != 2 {
+		t.Errorf("wanna discount: 0.8 and two tags, got: %v", payload)
+	}
+}
+
+func TestLoaderWatch(t *testing.T) {
+	dir := tempDir(t)
+	const rules = 20
+	write := func(version int) {
+		var ss []string
+		for i := 0; i < rules; i++ {
+			ss = append(ss, fmt.Sprintf(`{"name": "r%d", "expr": "(eq v %d)", "metadata": {"version": %d}}`, i, version, version))
+		}
+		writeFile(t, dir, "rules.json", "["+strings.Join(ss, ",\n")+"]")
+	}
+	write(0)
+	l, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	reports := make(chan error, 100)
+	l.Watch(time.Millisecond, func(err error) {
+		reports <- err
+	})
+
+	// readers never see the rules of different versions within a RuleSet
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				versions := make(map[interface{}]bool)
+				for _, r := range l.RuleSet().Rules() {
+					versions[r.Payload.(map[string]interface{})["version"]] = true
+				}
+				if len(versions) != 1 {
+					t.Errorf("wanna rules of one version, got: %v", versions)
+					return
+				}
+			}
+		}()
+	}
+	for version := 1; version <= 5; version++ {
+		write(version)
+		select {
+		case err := <-reports:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("version %d is not reloaded", version)
+		}
+	}
+	writeFile(t, dir, "rules.json", "[")
+	select {
+	case err := <-reports:
+		if err == nil {
+			t.Error("wanna error of the broken file")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the broken file is not reloaded")
+	}
+	close(stop)
+	wg.Wait()
+	rules2, err := l.RuleSet().MatchAll(evaluator.MapParams{"v": 5})
+	if err != nil || len(rules2) != rules {
+		t.Errorf("wanna %d rules of version 5, got: %d, %v", rules, len(rules2), err)
+	}
+	l.Close()
+	l.Close()
+}
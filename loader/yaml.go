@@ -0,0 +1,623 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrIllegalYAML means the YAML file is malformed
+var ErrIllegalYAML = errors.New("illegal YAML")
+
+type yamlKind uint8
+
+const (
+	yamlScalar yamlKind = iota
+	yamlSequence
+	yamlMapping
+)
+
+// yamlNode is the node of the YAML subset
+type yamlNode struct {
+	kind yamlKind
+	// value of scalar is resolved into nil, bool, float64 or string
+	value interface{}
+	// offsets are the offsets within the file of the bytes of the string scalar, along with the end
+	offsets []int
+	// items are the elements of sequence or the values of mapping
+	items []*yamlNode
+	// keys are the keys of mapping in order
+	keys []string
+	// pos is the offset of the node within the file
+	pos int
+}
+
+// get returns the value of key within the mapping
+func (n *yamlNode) get(key string) *yamlNode {
+	for i, k := range n.keys {
+		if k == key {
+			return n.items[i]
+		}
+	}
+	return nil
+}
+
+// null tells whether the node is missing or null
+func (n *yamlNode) null() bool {
+	return n == nil || n.kind == yamlScalar && n.value == nil
+}
+
+// interfaces converts the node into the values as encoding/json decodes into interface{}
+func (n *yamlNode) interfaces() interface{} {
+	switch n.kind {
+	case yamlSequence:
+		l := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			l[i] = item.interfaces()
+		}
+		return l
+	case yamlMapping:
+		m := make(map[string]interface{}, len(n.keys))
+		for i, k := range n.keys {
+			m[k] = n.items[i].interfaces()
+		}
+		return m
+	}
+	return n.value
+}
+
+// yamlLine is the line within the file, the line break is excluded
+type yamlLine struct {
+	// indent is the count of leading spaces
+	indent int
+	// start is the offset of the first byte after the indent, end is the offset of the line break
+	start, end int
+}
+
+type yamlParser struct {
+	content []byte
+	lines   []yamlLine
+	// i is the current line
+	i int
+}
+
+// parseYAMLNode parses the subset of YAML: block sequences and mappings, flow sequences and mappings within a line,
+// plain, single-quoted and double-quoted scalars within a line, literal and folded block scalars, and comments.
+// The features beyond it, such as anchors, aliases, tags and multiple documents, are reported as ErrUnsupportedFormat.
+// The offset of error within content is returned as well
+func parseYAMLNode(content []byte) (*yamlNode, int, error) {
+	p := &yamlParser{content: content}
+	for start := 0; start <= len(content); {
+		end := bytes.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		l := yamlLine{start: start, end: end}
+		if l.end > l.start && content[l.end-1] == '\r' {
+			l.end--
+		}
+		for l.start < l.end && content[l.start] == ' ' {
+			l.start++
+		}
+		l.indent = l.start - start
+		// the line of spaces and tabs is blank
+		if len(bytes.TrimLeft(content[l.start:l.end], " \t")) == 0 {
+			l.start = l.end
+		}
+		p.lines = append(p.lines, l)
+		start = end + 1
+	}
+
+	p.skip()
+	if p.i < len(p.lines) && p.text(p.lines[p.i]) == "---" {
+		p.i++
+	}
+	n, offset, err := p.parseBlock(-1)
+	if err != nil {
+		return nil, offset, err
+	}
+	p.skip()
+	if p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if text := p.text(l); text == "---" || text == "..." {
+			return nil, l.start, fmt.Errorf("%w: multiple documents", ErrUnsupportedFormat)
+		}
+		return nil, l.start, fmt.Errorf("%w: unexpected indentation", ErrIllegalYAML)
+	}
+	return n, 0, nil
+}
+
+func (p *yamlParser) text(l yamlLine) string {
+	return string(p.content[l.start:l.end])
+}
+
+// skip skips the blank lines and the comments
+func (p *yamlParser) skip() {
+	for ; p.i < len(p.lines); p.i++ {
+		if l := p.lines[p.i]; l.start < l.end && p.content[l.start] != '#' {
+			return
+		}
+	}
+}
+
+// spaces returns the offset of the first non-space byte since i
+func (p *yamlParser) spaces(i, end int) int {
+	for i < end && p.content[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// trailing checks there is nothing but the comment since i
+func (p *yamlParser) trailing(i, end int) (int, error) {
+	if j := p.spaces(i, end); j < end && (p.content[j] != '#' || p.content[j-1] != ' ') {
+		return j, fmt.Errorf("%w: unexpected %q", ErrIllegalYAML, p.content[j])
+	}
+	return 0, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the node starting at the current line, which is nil if the line is not indented more than parent
+func (p *yamlParser) parseBlock(parent int) (*yamlNode, int, error) {
+	p.skip()
+	if p.i >= len(p.lines) || p.lines[p.i].indent <= parent {
+		return nil, 0, nil
+	}
+	l := p.lines[p.i]
+	if p.content[l.start] == '\t' {
+		return nil, l.start, fmt.Errorf("%w: tab is not allowed for indentation", ErrIllegalYAML)
+	}
+	text := p.text(l)
+	if isSequenceItem(text) {
+		return p.parseSequence(l.indent)
+	}
+	if _, _, ok, _ := p.key(l); ok {
+		return p.parseMapping(l.indent)
+	}
+	if text[0] == '|' || text[0] == '>' {
+		return p.parseBlockScalar(parent, l.start)
+	}
+	n, next, offset, err := p.parseValue(l.start, l.end, false)
+	if err != nil {
+		return nil, offset, err
+	}
+	if offset, err := p.trailing(next, l.end); err != nil {
+		return nil, offset, err
+	}
+	p.i++
+	return n, 0, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, int, error) {
+	n := &yamlNode{kind: yamlSequence, pos: p.lines[p.i].start}
+	for p.skip(); p.i < len(p.lines); p.skip() {
+		l := &p.lines[p.i]
+		if l.indent != indent || !isSequenceItem(p.text(*l)) {
+			break
+		}
+		// the item on the same line is regarded as indented to its column, e.g. - name: a
+		if start := p.spaces(l.start+1, l.end); start < l.end && p.content[start] != '#' {
+			l.indent += start - l.start
+			l.start = start
+		} else {
+			p.i++
+		}
+		item, offset, err := p.parseBlock(indent)
+		if err != nil {
+			return nil, offset, err
+		}
+		if item == nil {
+			item = &yamlNode{pos: l.start}
+		}
+		n.items = append(n.items, item)
+	}
+	return n, 0, nil
+}
+
+// key returns the key of mapping along with the offset after the colon
+func (p *yamlParser) key(l yamlLine) (string, int, bool, error) {
+	c := p.content[l.start]
+	if c == '"' || c == '\'' {
+		n, next, _, err := p.parseQuoted(l.start, l.end)
+		if err != nil || next >= l.end || p.content[next] != ':' || next+1 < l.end && p.content[next+1] != ' ' {
+			return "", 0, false, err
+		}
+		return n.value.(string), next + 1, true, nil
+	}
+	if strings.ContainsRune("[]{},#&*!|>%@`", rune(c)) || isSequenceItem(p.text(l)) {
+		return "", 0, false, nil
+	}
+	for i := l.start; i < l.end; i++ {
+		switch {
+		case p.content[i] == '#' && p.content[i-1] == ' ':
+			return "", 0, false, nil
+		case p.content[i] == ':' && (i+1 == l.end || p.content[i+1] == ' '):
+			return strings.TrimRight(string(p.content[l.start:i]), " "), i + 1, true, nil
+		}
+	}
+	return "", 0, false, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, int, error) {
+	n := &yamlNode{kind: yamlMapping, pos: p.lines[p.i].start}
+	for p.skip(); p.i < len(p.lines); p.skip() {
+		l := p.lines[p.i]
+		if l.indent != indent {
+			break
+		}
+		key, i, ok, err := p.key(l)
+		if err != nil {
+			return nil, l.start, err
+		} else if !ok {
+			return nil, l.start, fmt.Errorf("%w: need key of mapping", ErrIllegalYAML)
+		}
+		if n.get(key) != nil {
+			return nil, l.start, fmt.Errorf("%w: duplicate key %q", ErrIllegalYAML, key)
+		}
+		var value *yamlNode
+		var offset int
+		switch i = p.spaces(i, l.end); {
+		case i == l.end || p.content[i] == '#':
+			p.i++
+			p.skip()
+			// the sequence may be as indented as the key
+			if p.i < len(p.lines) && p.lines[p.i].indent == indent && isSequenceItem(p.text(p.lines[p.i])) {
+				value, offset, err = p.parseSequence(indent)
+			} else {
+				value, offset, err = p.parseBlock(indent)
+			}
+		case p.content[i] == '|' || p.content[i] == '>':
+			value, offset, err = p.parseBlockScalar(indent, i)
+		default:
+			var next int
+			value, next, offset, err = p.parseValue(i, l.end, false)
+			if err == nil {
+				offset, err = p.trailing(next, l.end)
+			}
+			p.i++
+		}
+		if err != nil {
+			return nil, offset, err
+		}
+		if value == nil {
+			value = &yamlNode{pos: i}
+		}
+		n.keys = append(n.keys, key)
+		n.items = append(n.items, value)
+	}
+	return n, 0, nil
+}
+
+// parseValue parses the scalar or the flow collection at i within the line, the offset after it is returned
+func (p *yamlParser) parseValue(i, end int, flow bool) (*yamlNode, int, int, error) {
+	switch c := p.content[i]; c {
+	case '"', '\'':
+		return p.parseQuoted(i, end)
+	case '[', '{':
+		return p.parseFlow(i, end)
+	case '&', '*', '!':
+		return nil, 0, i, fmt.Errorf("%w: anchors, aliases and tags", ErrUnsupportedFormat)
+	case '%', '@', '`', '|', '>', ']', '}', ',', '#':
+		return nil, 0, i, fmt.Errorf("%w: unexpected %q", ErrIllegalYAML, c)
+	}
+	// the plain scalar ends before the comment, or the indicators of flow collection
+	j := i
+	for ; j < end; j++ {
+		c := p.content[j]
+		if c == '#' && p.content[j-1] == ' ' {
+			break
+		}
+		if flow && (c == ',' || c == ']' || c == '}' || c == ':' && (j+1 == end || strings.IndexByte(" ,]}", p.content[j+1]) >= 0)) {
+			break
+		}
+	}
+	next := j
+	for j > i && p.content[j-1] == ' ' {
+		j--
+	}
+	s := string(p.content[i:j])
+	n := &yamlNode{value: resolve(s), pos: i}
+	if _, ok := n.value.(string); ok {
+		n.offsets = make([]int, 0, j-i+1)
+		for k := i; k <= j; k++ {
+			n.offsets = append(n.offsets, k)
+		}
+	}
+	return n, next, 0, nil
+}
+
+var yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// resolve resolves the plain scalar by the core schema of YAML 1.2, numbers are float64 as encoding/json does
+func resolve(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return float64(i)
+		}
+	}
+	return s
+}
+
+// yamlEscapes are the single-character escapes within the double-quoted scalar
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b",
+	' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// parseQuoted parses the single-quoted or double-quoted scalar at i within the line
+func (p *yamlParser) parseQuoted(i, end int) (*yamlNode, int, int, error) {
+	quote := p.content[i]
+	var b strings.Builder
+	var offsets []int
+	write := func(s string, offset int) {
+		b.WriteString(s)
+		for k := 0; k < len(s); k++ {
+			offsets = append(offsets, offset)
+		}
+	}
+	for j := i + 1; j < end; {
+		c := p.content[j]
+		switch {
+		case c == quote && quote == '\'' && j+1 < end && p.content[j+1] == '\'':
+			write("'", j)
+			j += 2
+		case c == quote:
+			offsets = append(offsets, j)
+			return &yamlNode{value: b.String(), offsets: offsets, pos: i}, j + 1, 0, nil
+		case c == '\\' && quote == '"':
+			if j+1 == end {
+				return nil, 0, j, fmt.Errorf("%w: multi-line quoted scalar", ErrUnsupportedFormat)
+			}
+			if s, ok := yamlEscapes[p.content[j+1]]; ok {
+				write(s, j)
+				j += 2
+				continue
+			}
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[p.content[j+1]]
+			if size == 0 || j+2+size > end {
+				return nil, 0, j, fmt.Errorf("%w: illegal escape", ErrIllegalYAML)
+			}
+			r, err := strconv.ParseUint(string(p.content[j+2:j+2+size]), 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return nil, 0, j, fmt.Errorf("%w: illegal escape", ErrIllegalYAML)
+			}
+			write(string(rune(r)), j)
+			j += 2 + size
+		default:
+			write(string(c), j)
+			j++
+		}
+	}
+	return nil, 0, i, fmt.Errorf("%w: multi-line quoted scalar", ErrUnsupportedFormat)
+}
+
+// parseFlow parses the flow sequence or mapping at i within the line
+func (p *yamlParser) parseFlow(i, end int) (*yamlNode, int, int, error) {
+	n := &yamlNode{kind: yamlSequence, pos: i}
+	closing := byte(']')
+	if p.content[i] == '{' {
+		n.kind, closing = yamlMapping, '}'
+	}
+	for j := p.spaces(i+1, end); ; j = p.spaces(j, end) {
+		if j < end && p.content[j] == closing {
+			return n, j + 1, 0, nil
+		}
+		if len(n.items) > 0 {
+			if j >= end || p.content[j] != ',' {
+				break
+			}
+			if j = p.spaces(j+1, end); j < end && p.content[j] == closing {
+				return n, j + 1, 0, nil
+			}
+		}
+		if j >= end {
+			break
+		}
+		if n.kind == yamlMapping {
+			key, next, offset, err := p.parseValue(j, end, true)
+			if err != nil {
+				return nil, 0, offset, err
+			}
+			k, ok := key.value.(string)
+			if !ok || key.kind != yamlScalar {
+				return nil, 0, j, fmt.Errorf("%w: key of mapping should be string", ErrIllegalYAML)
+			}
+			if n.get(k) != nil {
+				return nil, 0, j, fmt.Errorf("%w: duplicate key %q", ErrIllegalYAML, k)
+			}
+			if j = p.spaces(next, end); j >= end || p.content[j] != ':' {
+				return nil, 0, j, fmt.Errorf("%w: need colon after key", ErrIllegalYAML)
+			}
+			n.keys = append(n.keys, k)
+			j = p.spaces(j+1, end)
+			if j >= end {
+				break
+			}
+		}
+		item, next, offset, err := p.parseValue(j, end, true)
+		if err != nil {
+			return nil, 0, offset, err
+		}
+		n.items = append(n.items, item)
+		j = next
+	}
+	return nil, 0, i, fmt.Errorf("%w: multi-line flow collection", ErrUnsupportedFormat)
+}
+
+// parseBlockScalar parses the literal or folded block scalar whose header is at i, the content is indented more than parent
+func (p *yamlParser) parseBlockScalar(parent int, i int) (*yamlNode, int, error) {
+	l := p.lines[p.i]
+	literal := p.content[i] == '|'
+	chomp := byte(0)
+	j := i + 1
+	if j < l.end && (p.content[j] == '-' || p.content[j] == '+') {
+		chomp = p.content[j]
+		j++
+	}
+	if j < l.end && p.content[j] >= '1' && p.content[j] <= '9' {
+		return nil, j, fmt.Errorf("%w: indentation indicator", ErrUnsupportedFormat)
+	}
+	if offset, err := p.trailing(j, l.end); err != nil {
+		return nil, offset, err
+	}
+	p.i++
+
+	var lines []yamlLine
+	indent := -1
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		if line.start == line.end {
+			lines = append(lines, line)
+			continue
+		}
+		if line.indent <= parent {
+			break
+		}
+		if indent < 0 {
+			indent = line.indent
+		} else if line.indent < indent {
+			return nil, line.start, fmt.Errorf("%w: less indented line within block scalar", ErrIllegalYAML)
+		}
+		lines = append(lines, line)
+	}
+	// the trailing blank lines are left for the chomping
+	body := len(lines)
+	for body > 0 && lines[body-1].start == lines[body-1].end {
+		body--
+	}
+	// the blank lines after the block scalar are not part of it, but they don't matter
+	p.i -= len(lines) - body
+
+	n := &yamlNode{pos: i}
+	var b strings.Builder
+	var offsets []int
+	write := func(s string, offset int) {
+		b.WriteString(s)
+		for k := 0; k < len(s); k++ {
+			offsets = append(offsets, offset)
+		}
+	}
+	blank := func(line yamlLine) bool {
+		return line.start == line.end
+	}
+	more := func(line yamlLine) bool {
+		return !blank(line) && line.indent > indent
+	}
+	for k, line := range lines[:body] {
+		if k > 0 {
+			prev := lines[k-1]
+			switch {
+			case literal || blank(prev) || more(prev):
+				write("\n", prev.end)
+			case blank(line):
+			case more(line):
+				write("\n", prev.end)
+			default:
+				write(" ", prev.end)
+			}
+		}
+		// the line is indented by the block indentation at least
+		start := line.start - line.indent + indent
+		if start > line.end {
+			start = line.end
+		}
+		for o := start; o < line.end; o++ {
+			offsets = append(offsets, o)
+		}
+		b.Write(p.content[start:line.end])
+	}
+	if body > 0 {
+		switch chomp {
+		case 0:
+			write("\n", lines[body-1].end)
+		case '+':
+			for k := body - 1; k < len(lines); k++ {
+				write("\n", lines[k].end)
+			}
+		}
+	}
+	end := i
+	if body > 0 {
+		end = lines[body-1].end
+	}
+	n.value, n.offsets = b.String(), append(offsets, end)
+	return n, 0, nil
+}
+
+// yamlRules converts the sequence of rules along with the offsets of their exprs, the offset of error is returned as well
+func yamlRules(root *yamlNode) ([]RuleJSON, [][]int, int, error) {
+	if root.null() {
+		return nil, nil, 0, nil
+	}
+	if root.kind != yamlSequence {
+		return nil, nil, root.pos, fmt.Errorf("%w: need a list of rules", ErrIllegalYAML)
+	}
+	list := make([]RuleJSON, len(root.items))
+	exprs := make([][]int, len(root.items))
+	for i, item := range root.items {
+		if item.kind != yamlMapping {
+			return nil, nil, item.pos, fmt.Errorf("%w: rule should be mapping", ErrIllegalYAML)
+		}
+		r := &list[i]
+		for _, field := range []struct {
+			key string
+			s   *string
+		}{{"name", &r.Name}, {"expr", &r.Expr}, {"syntax", &r.Syntax}} {
+			n := item.get(field.key)
+			if n.null() {
+				continue
+			}
+			s, ok := n.value.(string)
+			if !ok || n.kind != yamlScalar {
+				return nil, nil, n.pos, fmt.Errorf("%w: %s should be string", ErrIllegalYAML, field.key)
+			}
+			*field.s = s
+			if field.key == "expr" {
+				exprs[i] = n.offsets
+			}
+		}
+		if n := item.get("priority"); !n.null() {
+			f, ok := n.value.(float64)
+			if !ok || n.kind != yamlScalar || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+				return nil, nil, n.pos, fmt.Errorf("%w: priority should be integer", ErrIllegalYAML)
+			}
+			r.Priority = int(f)
+		}
+		if n := item.get("metadata"); !n.null() {
+			if n.kind != yamlMapping {
+				return nil, nil, n.pos, fmt.Errorf("%w: metadata should be mapping", ErrIllegalYAML)
+			}
+			r.Metadata = n.interfaces().(map[string]interface{})
+		}
+	}
+	return list, exprs, 0, nil
+}
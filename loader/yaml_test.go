@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	inputs := []struct {
+		content string
+		res     string
+	}{
+		{"", "null"},
+		{"# comment\n---\n- a\n- 'b'\n", `["a","b"]`},
+		{"- name: adult\n  expr: (ge age 18) # comment\n  priority: 10\n  metadata:\n    discount: 0.8\n    tags: [a, \"b\", {c: ~}]\n",
+			`[{"expr":"(ge age 18)","metadata":{"discount":0.8,"tags":["a","b",{"c":null}]},"name":"adult","priority":10}]`},
+		{"-\n  name: 'it''s'\n  expr: \"(eq a \\\"\\u00e9\\\")\"\n", `[{"expr":"(eq a \"é\")","name":"it's"}]`},
+		{"key:\n- 1\n-\n- x: true\n  y: 0x10\n", `{"key":[1,null,{"x":true,"y":16}]}`},
+		{"a: |\n  (and\n    (eq a 1))\n\nb: x\n", `{"a":"(and\n  (eq a 1))\n","b":"x"}`},
+		{"a: >-\n  a = 1\n  and b = 2\n\n  c\n", `{"a":"a = 1 and b = 2\nc"}`},
+		{"a: |+\n  x\n\nb: |-\n  y\n", `{"a":"x\n\n","b":"y"}`},
+		{"{a: [1, 2], b: {c: d}, e: 'x: y'}", `{"a":[1,2],"b":{"c":"d"},"e":"x: y"}`},
+	}
+	for _, input := range inputs {
+		n, _, err := parseYAMLNode([]byte(input.content))
+		if err != nil {
+			t.Errorf("%q shoud not have error but got %v", input.content, err)
+			continue
+		}
+		var v interface{}
+		if n != nil {
+			v = n.interfaces()
+		}
+		if b, _ := json.Marshal(v); string(b) != input.res {
+			t.Errorf("%q wanna: %s, got: %s", input.content, input.res, b)
+		}
+	}
+
+	errs := []struct {
+		content string
+		err     error
+		offset  int
+	}{
+		{"- &x a\n", ErrUnsupportedFormat, 2},
+		{"- !!str a\n", ErrUnsupportedFormat, 2},
+		{"- \"a\n  b\"\n", ErrUnsupportedFormat, 2},
+		{"- [a,\n  b]\n", ErrUnsupportedFormat, 2},
+		{"a: |2\n  x\n", ErrUnsupportedFormat, 4},
+		{"- a\n---\n- b\n", ErrUnsupportedFormat, 4},
+		{"- a\n  b\n", ErrIllegalYAML, 6},
+		{"- a: 1\n  a: 2\n", ErrIllegalYAML, 9},
+		{"\t- a\n", ErrIllegalYAML, 0},
+		{"a: \"x\" y\n", ErrIllegalYAML, 7},
+		{"a: \"\\q\"\n", ErrIllegalYAML, 4},
+		{"a: [1,, 2]\n", ErrIllegalYAML, 6},
+	}
+	for _, input := range errs {
+		_, offset, err := parseYAMLNode([]byte(input.content))
+		if !errors.Is(err, input.err) || offset != input.offset {
+			t.Errorf("%q wanna: %v at %d, got: %v at %d", input.content, input.err, input.offset, err, offset)
+		}
+	}
+}
+
+func TestYAMLOffsets(t *testing.T) {
+	content := "- a: x y\n- b: 'it''s'\n- c: \"\\u00e9\\n\"\n- d: |-\n    ab\n    c\n"
+	n, _, err := parseYAMLNode([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []struct {
+		key     string
+		offsets []int
+	}{
+		{"a", []int{5, 6, 7, 8}},
+		{"b", []int{15, 16, 17, 19, 20}},
+		{"c", []int{28, 28, 34, 36}},
+		{"d", []int{50, 51, 52, 57, 58}},
+	}
+	for i, input := range inputs {
+		got := n.items[i].get(input.key).offsets
+		if !reflect.DeepEqual(got, input.offsets) {
+			t.Errorf("%s wanna: %v, got: %v", input.key, input.offsets, got)
+		}
+	}
+}
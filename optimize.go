@@ -0,0 +1,270 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// folded is the value evaluated in advance, src is the tree which the value comes from
+type folded struct {
+	v   interface{}
+	src sexp
+	// raw is the value before it's prepared by FuncSpec.Prepare, if prepared is true
+	raw      interface{}
+	prepared bool
+}
+
+// isConstant returns whether the value of exp is known without params
+func (exp sexp) isConstant() bool {
+	switch exp.i.(type) {
+	case float64, string, bool, folded:
+		return true
+	}
+	return false
+}
+
+// constant returns the value of a constant exp
+func (exp sexp) constant() interface{} {
+	if f, ok := exp.i.(folded); ok {
+		return f.v
+	}
+	return exp.i
+}
+
+// value returns the value of a constant exp as it's written, rather than the one prepared by FuncSpec.Prepare
+func (exp sexp) value() interface{} {
+	if f, ok := exp.i.(folded); ok && f.prepared {
+		return f.raw
+	}
+	return exp.constant()
+}
+
+// function returns the name of the function if exp is a call of registered function
+func (exp sexp) function() (string, bool) {
+	l, ok := exp.i.(list)
+	if !ok || len(l) == 0 {
+		return "", false
+	}
+	head, ok := l[0].i.(varString)
+	if !ok {
+		return "", false
+	}
+	if _, err := function.Get(string(head)); err != nil {
+		return "", false
+	}
+	return string(head), true
+}
+
+// optimize pre-computes any subtree made only of literals and pure functions, the subtree is left as it is if errors occur.
+// The constant params are prepared by FuncSpec.Prepare as well
+func (exp sexp) optimize() sexp {
+	res, _, _ := exp.compile()
+	return res
+}
+
+// compile is the same as optimize, but returns the first error of preparing params along with its offset.
+// The param is left as it is if it fails to be prepared
+func (exp sexp) compile() (sexp, int, error) {
+	f := &folder{}
+	res := f.fold(exp)
+	return res, f.pos, f.err
+}
+
+// folder folds the constant subtrees and keeps the first error of preparing params
+type folder struct {
+	// partial tells whether the logic operators are simplified as well
+	partial bool
+	err     error
+	pos     int
+}
+
+// fold evaluates the constant subtrees in advance. The logic operators are simplified as well in partial mode,
+// e.g. (and true x) turns to x, which may not be the exact same as before if x is not boolean
+func (f *folder) fold(exp sexp) sexp {
+	l, ok := exp.i.(list)
+	if !ok {
+		return exp
+	}
+	name, isCall := exp.function()
+	start := 0
+	if isCall {
+		start = 1
+	}
+	args := make(list, len(l))
+	copy(args, l)
+	constant := true
+	for i := start; i < len(args); i++ {
+		args[i] = f.fold(args[i])
+		constant = constant && args[i].isConstant()
+	}
+	res := sexp{i: args, pos: exp.pos}
+
+	var spec function.FuncSpec
+	if isCall {
+		spec, _ = function.Describe(name)
+		if spec.Prepare != nil {
+			f.prepare(name, spec, args)
+		}
+	}
+	if isCall && f.partial {
+		if simplified, ok := simplifyLogic(name, args[1:], exp.pos); ok {
+			return simplified
+		}
+	}
+	if isCall {
+		if !constant || !spec.Pure || !spec.Deterministic {
+			return res
+		}
+	} else if !constant {
+		return res
+	}
+	v, err := res.evaluateSafely()
+	if err != nil {
+		return res
+	}
+	return sexp{i: folded{v: v, src: res}, pos: exp.pos}
+}
+
+// evaluateSafely evaluates exp without params, the panic of function is turned into error
+func (exp sexp) evaluateSafely() (v interface{}, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			v, err = nil, fmt.Errorf("%v", e)
+		}
+	}()
+	return exp.evaluate(nil)
+}
+
+// prepare replaces the constant params of the call with the prepared ones
+func (f *folder) prepare(name string, spec function.FuncSpec, args list) {
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !arg.isConstant() {
+			continue
+		}
+		v, ok, err := spec.Prepare(i-1, arg.constant())
+		if err != nil {
+			if f.err == nil {
+				f.err, f.pos = fmt.Errorf("%s: %w", name, err), arg.pos
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		raw := arg.value()
+		if fv, ok := arg.i.(folded); ok {
+			arg = fv.src
+		}
+		args[i] = sexp{i: folded{v: v, src: arg, raw: raw, prepared: true}, pos: arg.pos}
+	}
+}
+
+// simplifyLogic simplifies and, or with constant params
+func simplifyLogic(name string, args list, pos int) (sexp, bool) {
+	var mode uint8
+	switch name {
+	case function.FuncAnd, function.OperatorAnd:
+		mode = function.ModeAnd
+	case function.FuncOr, function.OperatorOr:
+		mode = function.ModeOr
+	default:
+		return sexp{}, false
+	}
+	// the value which decides the result by itself, false for and, true for or
+	decisive := mode == function.ModeOr
+	rest := make(list, 0, len(args))
+	for _, arg := range args {
+		if !arg.isConstant() {
+			rest = append(rest, arg)
+			continue
+		}
+		b, ok := arg.constant().(bool)
+		if !ok {
+			return sexp{}, false
+		}
+		if b == decisive {
+			return sexp{i: decisive, pos: pos}, true
+		}
+	}
+	switch len(rest) {
+	case 0:
+		return sexp{i: !decisive, pos: pos}, true
+	case 1:
+		return rest[0], true
+	case len(args):
+		return sexp{}, false
+	}
+	return call(pos, name, rest...), true
+}
+
+// substitute replaces the variables with the known params, arg tells whether exp is the argument of function
+func (exp sexp) substitute(ps Params, arg bool) sexp {
+	switch v := exp.i.(type) {
+	case varString:
+		name := string(v)
+		if _, err := function.Get(name); err == nil && !arg {
+			return exp
+		}
+		p, err := ps.Get(name)
+		if err != nil {
+			return exp
+		}
+		return sexp{i: folded{v: p, src: literal(p, exp)}, pos: exp.pos}
+	case list:
+		l := make(list, len(v))
+		for i, e := range v {
+			l[i] = e.substitute(ps, i > 0)
+		}
+		return sexp{i: l, pos: exp.pos}
+	}
+	return exp
+}
+
+// literal returns the tree of literals representing v, or the fallback if v cannot be represented
+func literal(v interface{}, fallback sexp) (res sexp) {
+	defer func() {
+		if e := recover(); e != nil {
+			res = fallback
+		}
+	}()
+	switch t := function.Uniform(v)[0].(type) {
+	case float64, string, bool:
+		return sexp{i: t, pos: fallback.pos}
+	case []interface{}:
+		l := make(list, len(t))
+		for i, e := range t {
+			r := literal(e, fallback)
+			if _, ok := r.i.(varString); ok {
+				return fallback
+			}
+			l[i] = r
+		}
+		return sexp{i: l, pos: fallback.pos}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		l := list{{i: varString(function.FuncMap), pos: fallback.pos}}
+		for _, k := range keys {
+			r := literal(t[k], fallback)
+			if _, ok := r.i.(varString); ok {
+				return fallback
+			}
+			l = append(l, sexp{i: k, pos: fallback.pos}, r)
+		}
+		return sexp{i: l, pos: fallback.pos}
+	}
+	return fallback
+}
+
+// Partial substitutes the known params and returns the simplified residual Expression, which can be evaluated by the rest params later.
+// Params.Get returning error means the param is unknown yet
+func (e Expression) Partial(params Params) Expression {
+	f := &folder{partial: true}
+	return Expression{exp: f.fold(e.exp.substitute(params, false)), src: e.src}
+}
@@ -0,0 +1,255 @@
+package evaluator
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nullne/evaluator/function"
+)
+
+func TestOptimize(t *testing.T) {
+	// the panic of function is left to evaluating
+	function.MustRegistSpec("test_panic", func(params ...interface{}) (interface{}, error) {
+		var m map[string]int
+		m["a"] = 1
+		return nil, nil
+	}, function.FuncSpec{Pure: true, Deterministic: true})
+	type input struct {
+		expr string
+		// folded subtrees in source form
+		folded []string
+	}
+	inputs := []input{
+		{`(between app_version (t_version "2.7.1") (t_version "2.9.1"))`, []string{`(t_version "2.7.1")`, `(t_version "2.9.1")`}},
+		{`(lt now (td_time "2017-09-09 12:00:00"))`, []string{`(td_time "2017-09-09 12:00:00")`}},
+		{`(eq years (+ 1 (* 2 3)))`, []string{`(+ 1 (* 2 3))`}},
+		{`(in years (1 2 3))`, []string{`(1 2 3)`}},
+		{`(eq (mod 5 2) 1)`, []string{`(eq (mod 5 2) 1)`}},
+		{`(eq years (t_version "2.x"))`, nil},
+		{`(eq years (/ 1 0))`, nil},
+		{`(eq years (mod 1 0))`, nil},
+		{`(eq years (% 1 0))`, nil},
+		{`(eq years (test_panic))`, nil},
+		{`(eq years (+ years 1))`, nil},
+		{`(get {"CN" 1.2 "US" {"NY" 1.1}} country)`, []string{`{"CN" 1.2 "US" {"NY" 1.1}}`}},
+		{`(eq years (get {"a" years} "a"))`, nil},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		var walk func(exp sexp)
+		walk = func(exp sexp) {
+			switch v := exp.i.(type) {
+			case folded:
+				got = append(got, v.src.source())
+			case list:
+				for _, e := range v {
+					walk(e)
+				}
+			}
+		}
+		walk(e.exp)
+		if len(got) != len(input.folded) {
+			t.Errorf("expression `%s` wanna folded: %v, got: %v", input.expr, input.folded, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != input.folded[i] {
+				t.Errorf("expression `%s` wanna folded: %v, got: %v", input.expr, input.folded, got)
+			}
+		}
+		if e.String() != input.expr {
+			t.Errorf("expression `%s` is printed as %s", input.expr, e.String())
+		}
+	}
+}
+
+func TestOptimizeImpure(t *testing.T) {
+	count := 0
+	counter := func(params ...interface{}) (interface{}, error) {
+		count++
+		return float64(count), nil
+	}
+	if err := function.Regist("test_counter", counter); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(`(+ (test_counter) 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		r, err := e.Eval(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != float64(i+1) {
+			t.Errorf("wanna: %v, got: %v", i+1, r)
+		}
+	}
+}
+
+func TestOptimizeCalendar(t *testing.T) {
+	function.RegistCalendar("test-refresh", function.Holidays{})
+	e, err := New(`(and (is_holiday (t_rfc3339 "2017-09-18T12:00:00Z") "test-refresh") (not (in_window (t_rfc3339 "2017-09-18T12:00:00Z") "Mon 09:00-18:00" "UTC" "test-refresh")))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := e.EvalBool(nil); err != nil || r {
+		t.Errorf("wanna: false, got: %v, %v", r, err)
+	}
+	// the calendar registered again after New is used
+	holidays, err := function.NewHolidays("2017-09-18")
+	if err != nil {
+		t.Fatal(err)
+	}
+	function.RegistCalendar("test-refresh", holidays)
+	if r, err := e.EvalBool(nil); err != nil || !r {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+}
+
+func TestPartial(t *testing.T) {
+	type input struct {
+		expr     string
+		params   MapParams
+		residual string
+	}
+	now := time.Now()
+	inputs := []input{
+		{`(and (eq tenant "a") (between years 18 30))`, MapParams{"tenant": "a"}, `(between years 18 30)`},
+		{`(and (eq tenant "a") (between years 18 30))`, MapParams{"tenant": "b"}, `false`},
+		{`(and (eq tenant "a") (between years 18 30) (ne gender "male"))`, MapParams{"tenant": "a"}, `(and (between years 18 30) (ne gender "male"))`},
+		{`(or (in tenant ("a" "b")) (between years 18 30))`, MapParams{"tenant": "a"}, `true`},
+		{`(or (in tenant ("a" "b")) (between years 18 30))`, MapParams{"tenant": "c"}, `(between years 18 30)`},
+		{`(or (overlap region (1 2)) (eq gender "male"))`, MapParams{"region": []int{3, 4}}, `(eq gender "male")`},
+		{`(eq gender (+ base 1))`, MapParams{"base": 1}, `(eq gender (+ 1 1))`},
+		{`(between years min max)`, MapParams{"min": 18, "years": 20}, `(between 20 18 max)`},
+		{`(between created start end)`, MapParams{"start": now, "end": now}, `(between created start end)`},
+		{`(and (eq tenant "a") (between years 18 30))`, MapParams{}, `(and (eq tenant "a") (between years 18 30))`},
+		{`(and (eq tenant "a") (between years 18 30))`, MapParams{"tenant": "a", "years": 20}, `true`},
+		{`(and (eq labels {"env" "prod"}) (eq gender "male"))`, MapParams{"labels": map[string]string{"env": "prod"}}, `(eq gender "male")`},
+		{`(get rates country 1.0)`, MapParams{"rates": map[string]float64{"US": 1, "CN": 1.2}}, `(get {"CN" 1.2 "US" 1} country 1)`},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := e.Partial(input.params)
+		if r.String() != input.residual {
+			t.Errorf("expression `%s` with %v wanna: %s, got: %s", input.expr, input.params, input.residual, r.String())
+		}
+	}
+
+	e, err := New(`(and (eq tenant "a") (between years start end))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := e.Partial(MapParams{"tenant": "a", "start": 18, "end": 30})
+	for _, years := range []int{10, 18, 20, 30, 40} {
+		params := MapParams{"tenant": "a", "start": 18, "end": 30, "years": years}
+		want, err := e.EvalBool(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.EvalBool(MapParams{"years": years})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("years: %d, wanna: %v, got: %v", years, want, got)
+		}
+	}
+}
+
+func BenchmarkBetweenVersion(b *testing.B) {
+	expr, err := New(`(between app_version (t_version "2.7.1") (t_version "2.9.1"))`)
+	if err != nil {
+		b.Error(err)
+	}
+	params := MapParams{"app_version": 20007000200000000000.0}
+	for n := 0; n < b.N; n++ {
+		_, err := expr.EvalBool(params)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	e, err := New(`(and (matches email ".*@corp\.com$") (eq (regex_extract path "^/v(\d+)/" 1) "2"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := e.exp.i.(list)[1].i.(list)[2]
+	if f, ok := re.i.(folded); !ok || reflect.TypeOf(f.v) != reflect.TypeOf(&regexp.Regexp{}) {
+		t.Errorf("wanna the pattern compiled, got: %#v", re.i)
+	}
+	if got := e.String(); got != `(and (matches email ".*@corp\.com$") (eq (regex_extract path "^/v(\d+)/" 1) "2"))` {
+		t.Errorf("wanna the source kept, got: %s", got)
+	}
+	r, err := e.EvalBool(MapParams{"email": "a@corp.com", "path": "/v2/users"})
+	if err != nil || !r {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+
+	e, err = New(`(in_polygon lat lng "{\"type\": \"Polygon\", \"coordinates\": [[[121, 31], [122, 31], [122, 32], [121, 31]]]}")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := e.exp.i.(list)[3].i.(folded); !ok || reflect.TypeOf(f.v) != reflect.TypeOf(&function.Polygon{}) {
+		t.Errorf("wanna the polygon indexed, got: %#v", e.exp.i.(list)[3].i)
+	}
+
+	e, err = New(`(and (in user_id (1 2 3)) (overlap ("a" "b") tags))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, overlap := e.exp.i.(list)[1].i.(list)[2], e.exp.i.(list)[2].i.(list)[1]
+	for _, v := range []sexp{in, overlap} {
+		if f, ok := v.i.(folded); !ok || reflect.TypeOf(f.v) != reflect.TypeOf(&function.Set{}) {
+			t.Errorf("wanna the list looked up by hash, got: %#v", v.i)
+		}
+	}
+	if got := e.String(); got != `(and (in user_id (1 2 3)) (overlap ("a" "b") tags))` {
+		t.Errorf("wanna the source kept, got: %s", got)
+	}
+	if r, err := e.EvalBool(MapParams{"user_id": 2, "tags": []string{"b"}}); err != nil || !r {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if p := e.Partial(MapParams{"user_id": 4}); p.String() != `false` {
+		t.Errorf("wanna: false, got: %s", p)
+	}
+
+	_, err = New("(or (eq a 1)\n  (matches email \"(.*@corp\"))")
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Pos.String() != "2:18" || !strings.HasPrefix(se.Err.Error(), "matches: error parsing regexp") {
+		t.Errorf("wanna error of the pattern at 2:18, got: %v", err)
+	}
+	_, err = NewInfix(`matches(email, "` + strings.Repeat("a", function.MaxPatternSize+1) + `")`)
+	if !errors.Is(err, function.ErrPatternTooLarge) || !errors.As(err, &se) || se.Pos.Offset != 15 {
+		t.Errorf("wanna: %v at offset 15, got: %v", function.ErrPatternTooLarge, err)
+	}
+
+	// the pattern from params is compiled on evaluation
+	e, err = New(`(matches email pattern)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err := e.EvalBool(MapParams{"email": "a@corp.com", "pattern": "@corp"}); err != nil || !r {
+		t.Errorf("wanna: true, got: %v, %v", r, err)
+	}
+	if _, err := e.EvalBool(MapParams{"email": "a@corp.com", "pattern": "("}); err == nil {
+		t.Errorf("wanna error of the illegal pattern")
+	}
+	if p := e.Partial(MapParams{"pattern": "("}); p.String() != `(matches email "(")` {
+		t.Errorf("wanna the illegal pattern left as it is, got: %s", p)
+	}
+}
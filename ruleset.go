@@ -0,0 +1,266 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrRuleExists means the rule with the same name has been added
+	ErrRuleExists = errors.New("rule exists")
+	// ErrRuleNotFound means there is no rule with the name
+	ErrRuleNotFound = errors.New("rule not found")
+)
+
+// Rule is a named Expression with priority and payload
+type Rule struct {
+	Name       string
+	Expression Expression
+	// Priority decides the order of matching, the higher the earlier. Rules of the same priority are matched in the order of adding
+	Priority int
+	// Payload is anything attached to the rule, e.g. the config to apply when matched
+	Payload interface{}
+}
+
+// RuleError is the error occurred when evaluating the rule
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e RuleError) Error() string {
+	return fmt.Sprintf("rule %q: %v", e.Rule, e.Err)
+}
+
+// RuleErrors collects all the RuleError during matching
+type RuleErrors []RuleError
+
+func (es RuleErrors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// RuleSet holds the rules and matches them against params, it's safe for concurrent use
+type RuleSet struct {
+	mu    sync.RWMutex
+	seq   uint64
+	rules map[string]*entry
+	// compiled is the rules in the order of matching along with the index, nil means it needs rebuilding
+	compiled *compiled
+}
+
+type compiled struct {
+	entries []*entry
+	// positions are the positions of all the entries
+	positions []int
+	index     *index
+}
+
+type entry struct {
+	Rule
+	seq uint64
+}
+
+// NewRuleSet returns an empty RuleSet
+func NewRuleSet() *RuleSet {
+	return &RuleSet{rules: make(map[string]*entry)}
+}
+
+// Add adds the rule, ErrRuleExists is returned if the name is taken
+func (rs *RuleSet) Add(r Rule) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if _, ok := rs.rules[r.Name]; ok {
+		return fmt.Errorf("%w: %q", ErrRuleExists, r.Name)
+	}
+	rs.seq++
+	rs.rules[r.Name] = &entry{Rule: r, seq: rs.seq}
+	rs.compiled = nil
+	return nil
+}
+
+// Remove removes the rule by name, ErrRuleNotFound is returned if there is no such rule
+func (rs *RuleSet) Remove(name string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if _, ok := rs.rules[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrRuleNotFound, name)
+	}
+	delete(rs.rules, name)
+	rs.compiled = nil
+	return nil
+}
+
+// Replace replaces the rule of the same name and keeps its order among the rules of the same priority,
+// ErrRuleNotFound is returned if there is no such rule
+func (rs *RuleSet) Replace(r Rule) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	old, ok := rs.rules[r.Name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrRuleNotFound, r.Name)
+	}
+	rs.rules[r.Name] = &entry{Rule: r, seq: old.seq}
+	rs.compiled = nil
+	return nil
+}
+
+// Get returns the rule by name
+func (rs *RuleSet) Get(name string) (Rule, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	e, ok := rs.rules[name]
+	if !ok {
+		return Rule{}, false
+	}
+	return e.Rule, true
+}
+
+// Len returns the count of rules
+func (rs *RuleSet) Len() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.rules)
+}
+
+// Rules returns all the rules in the order of matching
+func (rs *RuleSet) Rules() []Rule {
+	entries := rs.compile().entries
+	res := make([]Rule, len(entries))
+	for i, e := range entries {
+		res[i] = e.Rule
+	}
+	return res
+}
+
+// compile sorts the rules in the order of matching and builds the index if needed
+func (rs *RuleSet) compile() *compiled {
+	rs.mu.RLock()
+	c := rs.compiled
+	rs.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.compiled == nil {
+		sorted := make([]*entry, 0, len(rs.rules))
+		for _, e := range rs.rules {
+			sorted = append(sorted, e)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].Priority != sorted[j].Priority {
+				return sorted[i].Priority > sorted[j].Priority
+			}
+			return sorted[i].seq < sorted[j].seq
+		})
+		positions := make([]int, len(sorted))
+		for i := range positions {
+			positions[i] = i
+		}
+		rs.compiled = &compiled{entries: sorted, positions: positions, index: buildIndex(sorted)}
+	}
+	return rs.compiled
+}
+
+// MatchAll returns all the rules matched in the order of matching.
+// The rules failed to evaluate are regarded as unmatched, and their errors are returned as RuleErrors.
+// Only the candidate rules given by the index are evaluated, so the errors of the rules ruled out by the index are not reported
+func (rs *RuleSet) MatchAll(params Params) ([]Rule, error) {
+	return rs.match(params, -1)
+}
+
+// FirstMatch returns the first rule matched, the rules after it are not evaluated.
+// The errors of the rules evaluated are returned as RuleErrors
+func (rs *RuleSet) FirstMatch(params Params) (Rule, bool, error) {
+	rules, err := rs.match(params, 1)
+	if len(rules) == 0 {
+		return Rule{}, false, err
+	}
+	return rules[0], true, err
+}
+
+// MatchTopN returns at most n rules matched in the order of matching, the rules after them are not evaluated.
+// The errors of the rules evaluated are returned as RuleErrors
+func (rs *RuleSet) MatchTopN(params Params, n int) ([]Rule, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	return rs.match(params, n)
+}
+
+// match evaluates the candidate rules in order until n rules matched, n < 0 means no limit.
+// All the candidates are looked up at once for MatchAll, otherwise the rules are checked one by one,
+// so that the params of the index are got only when a rule indexed by them is reached
+func (rs *RuleSet) match(params Params, n int) ([]Rule, error) {
+	c := rs.compile()
+	memo := newMemoParams(params)
+	f := c.index.filter(memo)
+	if n < 0 {
+		return evaluateRules(c.entries, f.candidates(), memo, n, nil)
+	}
+	return evaluateRules(c.entries, c.positions, memo, n, f)
+}
+
+// evaluateRules evaluates the rules at the positions in order until n rules matched, n < 0 means no limit.
+// The rules ruled out by the filter are skipped, nil filter rules out nothing
+func evaluateRules(entries []*entry, positions []int, params Params, n int, f *filter) ([]Rule, error) {
+	var matched []Rule
+	var errs RuleErrors
+	for _, i := range positions {
+		if f != nil && f.ruledOut(i) {
+			continue
+		}
+		e := entries[i]
+		ok, err := e.Expression.EvalBool(params)
+		if err != nil {
+			errs = append(errs, RuleError{Rule: e.Name, Err: err})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		matched = append(matched, e.Rule)
+		if len(matched) == n {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return matched, errs
+	}
+	return matched, nil
+}
+
+// memoParams caches the params, so every Get on the underlying Params happens once
+type memoParams struct {
+	ps    Params
+	cache map[string]memo
+}
+
+type memo struct {
+	v   interface{}
+	err error
+}
+
+func newMemoParams(ps Params) *memoParams {
+	return &memoParams{ps: ps, cache: make(map[string]memo)}
+}
+
+// Get implements the interface Params
+func (p *memoParams) Get(name string) (interface{}, error) {
+	if m, ok := p.cache[name]; ok {
+		return m.v, m.err
+	}
+	if p.ps == nil {
+		return nil, ErrNotFound
+	}
+	v, err := p.ps.Get(name)
+	p.cache[name] = memo{v: v, err: err}
+	return v, err
+}
@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// countingParams counts the calls of Get by name
+type countingParams struct {
+	MapParams
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func (p *countingParams) Get(name string) (interface{}, error) {
+	p.mu.Lock()
+	p.count[name]++
+	p.mu.Unlock()
+	return p.MapParams.Get(name)
+}
+
+func newRuleSet(t testing.TB, rules ...Rule) *RuleSet {
+	rs := NewRuleSet()
+	for _, r := range rules {
+		if err := rs.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rs
+}
+
+func mustNew(t testing.TB, expr string) Expression {
+	e, err := New(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func ruleNames(rules []Rule) []string {
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestRuleSet(t *testing.T) {
+	rs := newRuleSet(t,
+		Rule{Name: "ios", Expression: mustNew(t, `(eq os "ios")`), Payload: 1},
+		Rule{Name: "adult", Expression: mustNew(t, `(ge years 18)`), Priority: 10, Payload: 2},
+		Rule{Name: "broken", Expression: mustNew(t, `(gt years "x")`), Priority: 5},
+		Rule{Name: "mobile", Expression: mustNew(t, `(in os ("ios" "android"))`), Payload: 3},
+		Rule{Name: "vip", Expression: mustNew(t, `(eq level "vip")`), Priority: 10},
+	)
+	if err := rs.Add(Rule{Name: "ios"}); !errors.Is(err, ErrRuleExists) {
+		t.Errorf("wanna: %v, got: %v", ErrRuleExists, err)
+	}
+	if rs.Len() != 5 {
+		t.Errorf("wanna: 5 rules, got: %d", rs.Len())
+	}
+	if got := fmt.Sprint(ruleNames(rs.Rules())); got != "[adult vip broken ios mobile]" {
+		t.Errorf("wanna order: [adult vip broken ios mobile], got: %s", got)
+	}
+
+	params := &countingParams{MapParams: MapParams{"os": "ios", "years": 20, "level": "normal"}, count: make(map[string]int)}
+	rules, err := rs.MatchAll(params)
+	if got := fmt.Sprint(ruleNames(rules)); got != "[adult ios mobile]" {
+		t.Errorf("wanna: [adult ios mobile], got: %s", got)
+	}
+	var errs RuleErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Rule != "broken" {
+		t.Errorf("wanna error of rule broken, got: %v", err)
+	}
+	if rules[1].Payload != 1 {
+		t.Errorf("wanna payload: 1, got: %v", rules[1].Payload)
+	}
+	for name, count := range params.count {
+		if count != 1 {
+			t.Errorf("param %s is got %d times", name, count)
+		}
+	}
+
+	params.count = make(map[string]int)
+	r, ok, err := rs.FirstMatch(params)
+	if !ok || r.Name != "adult" || err != nil {
+		t.Errorf("wanna: adult, got: %v, %v, %v", r.Name, ok, err)
+	}
+	if params.count["os"] != 0 {
+		t.Errorf("rules after the first match are evaluated")
+	}
+
+	rules, err = rs.MatchTopN(params, 2)
+	if got := fmt.Sprint(ruleNames(rules)); got != "[adult ios]" || err == nil {
+		t.Errorf("wanna: [adult ios], got: %s, %v", got, err)
+	}
+
+	if err := rs.Replace(Rule{Name: "adult", Expression: mustNew(t, `(ge years 21)`), Priority: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Remove("broken"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Remove("broken"); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("wanna: %v, got: %v", ErrRuleNotFound, err)
+	}
+	if err := rs.Replace(Rule{Name: "broken"}); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("wanna: %v, got: %v", ErrRuleNotFound, err)
+	}
+	rules, err = rs.MatchAll(params)
+	if got := fmt.Sprint(ruleNames(rules)); got != "[ios mobile]" || err != nil {
+		t.Errorf("wanna: [ios mobile], got: %s, %v", got, err)
+	}
+	if _, ok, _ := rs.FirstMatch(MapParams{"os": "web", "years": 1, "level": "normal"}); ok {
+		t.Errorf("wanna no match")
+	}
+}
+
+func TestRuleSetConcurrent(t *testing.T) {
+	rs := NewRuleSet()
+	exps := make([]Expression, 50)
+	for j := range exps {
+		exps[j] = mustNew(t, fmt.Sprintf(`(eq years %d)`, j))
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				name := fmt.Sprintf("rule-%d-%d", i, j)
+				if err := rs.Add(Rule{Name: name, Expression: exps[j]}); err != nil {
+					t.Error(err)
+				}
+				if _, err := rs.MatchAll(MapParams{"years": j}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	rules, err := rs.MatchAll(MapParams{"years": 7})
+	if err != nil || len(rules) != 8 {
+		t.Errorf("wanna 8 rules, got: %d, %v", len(rules), err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -29,6 +30,8 @@ var (
 	ErrLeftOverText = errors.New("left over text")
 	// ErrUnmatchedParenthesis indicated the mismatching parenthesis
 	ErrUnmatchedParenthesis = errors.New("unmatched parenthesis")
+	// ErrUnpairedKey means the key within the map literal {k v} has no value
+	ErrUnpairedKey = errors.New("key without value")
 )
 
 // string without quoted is variable name
@@ -42,6 +45,8 @@ func (q varString) String() string {
 type sexp struct {
 	// type of i must NOT be sexp
 	i interface{}
+	// pos is the byte offset within the source
+	pos int
 }
 
 func (exp sexp) evaluate(ps Params) (interface{}, error) {
@@ -51,8 +56,14 @@ func (exp sexp) evaluate(ps Params) (interface{}, error) {
 		}
 
 		params := make([]interface{}, 0, len(l))
-		for _, p := range l {
-			v, err := p.evaluate(ps)
+		for i, p := range l {
+			var v interface{}
+			var err error
+			if i == 0 {
+				v, err = p.evaluate(ps)
+			} else {
+				v, err = p.evaluateArg(ps)
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -71,91 +82,231 @@ func (exp sexp) evaluate(ps Params) (interface{}, error) {
 		}
 		return ps.Get(s)
 	}
+	if f, ok := exp.i.(folded); ok {
+		return f.v, nil
+	}
 	return exp.i, nil
 }
 
-func (exp sexp) properties() []string {
-	if l, isList := exp.i.(list); isList {
-		if len(l) == 0 {
-			return nil
-		}
-		var props []string
-		for _, p := range l {
-			props = append(props, p.properties()...)
-		}
-		return props
+// evaluateArg evaluates exp as the argument of function. The symbol is read from params first,
+// and it's the function only if params miss it, so that a variable may share its name with a function
+func (exp sexp) evaluateArg(ps Params) (interface{}, error) {
+	val, ok := exp.i.(varString)
+	if !ok || ps == nil {
+		return exp.evaluate(ps)
 	}
-	if val, ok := exp.i.(varString); ok {
-		s := string(val)
-		if _, err := function.Get(s); err == nil {
-			return nil
+	v, err := ps.Get(string(val))
+	if err == nil {
+		return v, nil
+	}
+	if fn, ferr := function.Get(string(val)); ferr == nil {
+		return fn, nil
+	}
+	return nil, err
+}
+
+// properties returns the variables in the order of occurrence along with the duplicates.
+// A symbol passed to function is variable even if it shares the name with a function, as it's read from params first,
+// unless the function declares the param as type of func
+func (exp sexp) properties() []string {
+	var props []string
+	var walk func(exp sexp, arg bool)
+	walk = func(exp sexp, arg bool) {
+		switch v := exp.i.(type) {
+		case varString:
+			if _, err := function.Get(string(v)); err == nil && !arg {
+				return
+			}
+			props = append(props, string(v))
+		case list:
+			name, isCall := exp.function()
+			for i, e := range v {
+				if _, ok := e.i.(varString); ok && isCall && i > 0 && funcParam(name, i-1) {
+					continue
+				}
+				walk(e, i > 0)
+			}
 		}
-		return []string{s}
 	}
-	return nil
+	walk(exp, false)
+	return props
 }
 
+// leftParen marks the position of a left parenthesis while parsing
+type leftParen int
+
+// leftBrace marks the position of a left brace of map literal while parsing
+type leftBrace int
+
 func parse(exp string) (sexp, error) {
+	root, _, err := parseAt(exp)
+	return root, err
+}
+
+// parseAt parses the expression like parse, and returns the byte offset where the error occurs as well
+func parseAt(exp string) (sexp, int, error) {
 	data := []byte(exp)
 	tokens := queue.New()
 ss:
 	for i := 0; i < len(data); {
+		start := i + skipSpaces(data[i:])
 		advance, token, err := scan(data[i:])
 		if err != nil {
-			return sexp{}, err
+			return sexp{}, start, err
 		}
 		i += advance
-		if t, ok := token.(byte); ok && t == ')' {
+		if t, ok := token.(byte); ok && t == '(' {
+			tokens.PushBack(leftParen(start))
+			continue
+		}
+		if t, ok := token.(byte); ok && t == '{' {
+			tokens.PushBack(leftBrace(start))
+			continue
+		}
+		if t, ok := token.(byte); ok && (t == ')' || t == '}') {
 			ins := queue.New()
 			for e := tokens.Back(); e != nil; e = tokens.Back() {
 				tokens.Remove(e)
-				if v, ok := e.Value.(byte); ok && v == '(' {
+				pos, isParen := e.Value.(leftParen)
+				brace, isBrace := e.Value.(leftBrace)
+				if isParen && t == ')' {
 					exps := make(list, 0, ins.Len())
 					for e := ins.Back(); e != nil; e = e.Prev() {
-						if p, ok := e.Value.(sexp); ok {
-							exps = append(exps, p)
-						} else {
-							exps = append(exps, sexp{e.Value})
-						}
+						exps = append(exps, e.Value.(sexp))
 					}
-					tokens.PushBack(sexp{exps})
+					tokens.PushBack(sexp{i: exps, pos: int(pos)})
 					continue ss
 				}
+				if isBrace && t == '}' {
+					// the map literal {k v} is the call of map
+					exps := make(list, 1, ins.Len()+1)
+					exps[0] = sexp{i: varString(function.FuncMap), pos: int(brace)}
+					for e := ins.Back(); e != nil; e = e.Prev() {
+						exps = append(exps, e.Value.(sexp))
+					}
+					if len(exps)%2 == 0 {
+						return sexp{}, exps[len(exps)-1].pos, ErrUnpairedKey
+					}
+					tokens.PushBack(sexp{i: exps, pos: int(brace)})
+					continue ss
+				}
+				if isParen || isBrace {
+					break
+				}
 				ins.PushBack(e.Value)
 			}
-			return sexp{}, ErrUnmatchedParenthesis
+			return sexp{}, start, ErrUnmatchedParenthesis
 		}
-		tokens.PushBack(token)
+		tokens.PushBack(sexp{i: token, pos: start})
 	}
 
 	if tokens.Len() == 0 {
-		return sexp{}, ErrNilInput
+		return sexp{}, 0, ErrNilInput
 	} else if tokens.Len() != 1 {
-		return sexp{}, ErrLeftOverText
+		// the left parenthesis unclosed is where the text is left over, otherwise the second expression
+		for e := tokens.Back(); e != nil; e = e.Prev() {
+			if pos, ok := e.Value.(leftParen); ok {
+				return sexp{}, int(pos), ErrLeftOverText
+			}
+			if pos, ok := e.Value.(leftBrace); ok {
+				return sexp{}, int(pos), ErrLeftOverText
+			}
+		}
+		return sexp{}, tokens.Front().Next().Value.(sexp).pos, ErrLeftOverText
 	}
-	if exp, ok := tokens.Back().Value.(sexp); ok {
-		if l, ok := exp.i.(list); ok && len(l) == 0 {
-			return sexp{}, ErrNilInput
+	root, ok := tokens.Back().Value.(sexp)
+	if !ok {
+		if pos, ok := tokens.Back().Value.(leftBrace); ok {
+			return sexp{}, int(pos), ErrUnmatchedParenthesis
 		}
-		return exp, nil
+		return sexp{}, int(tokens.Back().Value.(leftParen)), ErrUnmatchedParenthesis
 	}
-	return sexp{tokens.Back().Value}, nil
+	if l, ok := root.i.(list); ok && len(l) == 0 {
+		return sexp{}, root.pos, ErrNilInput
+	}
+	return root, 0, nil
 }
 
 func (exp sexp) String() string {
 	return fmt.Sprintf("%v", exp.i)
 }
 
-func (exp sexp) dump(i int) {
-	fmt.Printf("%*s%v: ", i*3, "", reflect.TypeOf(exp.i))
-	if l, isList := exp.i.(list); isList {
-		fmt.Printf("%d elements: %s\n", len(l), l)
-		for _, e := range l {
-			e.dump(i + 1)
+// source returns the s-expression of the tree, which can be parsed back
+func (exp sexp) source() string {
+	switch v := exp.i.(type) {
+	case folded:
+		return v.src.source()
+	case list:
+		ss := make([]string, len(v))
+		for i, e := range v {
+			ss[i] = e.source()
+		}
+		if len(v) > 0 && v[0].i == varString(function.FuncMap) {
+			return "{" + strings.Join(ss[1:], " ") + "}"
+		}
+		return "(" + strings.Join(ss, " ") + ")"
+	case string:
+		return literalOf(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// literalOf returns the string literal of v, doubling the backslashes before the delimiter
+func literalOf(v string) string {
+	delim := byte('"')
+	for _, d := range []byte{'"', '\'', '`'} {
+		if strings.IndexByte(v, d) < 0 {
+			delim = d
+			break
+		}
+	}
+	var b strings.Builder
+	b.WriteByte(delim)
+	n := 0
+	for i := 0; i < len(v); i++ {
+		if v[i] == delim {
+			b.WriteString(strings.Repeat(`\`, n+1))
+		}
+		if v[i] == '\\' {
+			n++
+		} else {
+			n = 0
+		}
+		b.WriteByte(v[i])
+	}
+	b.WriteString(strings.Repeat(`\`, n))
+	b.WriteByte(delim)
+	return b.String()
+}
+
+// equal returns whether the two trees are the same regardless of the positions
+func (exp sexp) equal(other sexp) bool {
+	if f, ok := exp.i.(folded); ok {
+		exp = f.src
+	}
+	if f, ok := other.i.(folded); ok {
+		other = f.src
+	}
+	l, ok := exp.i.(list)
+	if !ok {
+		if _, ok := other.i.(list); ok {
+			return false
 		}
-	} else {
-		fmt.Println(exp)
+		return reflect.DeepEqual(exp.i, other.i)
 	}
+	o, ok := other.i.(list)
+	if !ok || len(l) != len(o) {
+		return false
+	}
+	for i := range l {
+		if !l[i].equal(o[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 type list []sexp
@@ -171,16 +322,22 @@ func (l list) String() string {
 	return b + "]"
 }
 
-func scan(data []byte) (advance int, token interface{}, err error) {
-	length := len(data)
+// skipSpaces returns the count of leading space bytes
+func skipSpaces(data []byte) int {
 	start := 0
-	for width := 0; start < length; start += width {
+	for width := 0; start < len(data); start += width {
 		var r rune
 		r, width = utf8.DecodeRune(data[start:])
 		if !unicode.IsSpace(r) {
 			break
 		}
 	}
+	return start
+}
+
+func scan(data []byte) (advance int, token interface{}, err error) {
+	length := len(data)
+	start := skipSpaces(data)
 	if start >= length {
 		return start, nil, nil
 	}
@@ -191,7 +348,7 @@ func scan(data []byte) (advance int, token interface{}, err error) {
 	}
 
 	for width, i := 0, start; i < length; i += width {
-		if b := data[i]; b == ')' || b == '(' {
+		if b := data[i]; b == ')' || b == '(' || b == '}' || b == '{' {
 			if i == start {
 				return start + 1, data[i], nil
 			}
@@ -216,47 +373,27 @@ func convert(data []byte) interface{} {
 	return varString(data)
 }
 
-// scanStringWithQuotesStriped scan string surrounded with ', " or something like this, a single character
+// scanStringWithQuotesStriped scan string surrounded with ', " or something like this, a single character.
+// The backslashes before the delimiter escape each other and the delimiter, the others are kept as they are
 func scanStringWithQuotesStriped(data []byte) (advance int, token []byte, err error) {
 	length := len(data)
 	if length == 0 {
 		return 0, nil, nil
 	}
-	// escape character positions
-	ecps := make([]int, 0, len(data))
-	defer func() {
-		// remove escape character
-		if err != nil {
-			return
-		}
-		tokenLen := len(token)
-		tmp := make([]byte, 0, tokenLen)
-		s := 0
-		for _, p := range ecps {
-			tmp = append(tmp, token[s:p]...)
-			s = p + 1
-		}
-		if s < tokenLen {
-			tmp = append(tmp, token[s:]...)
-		}
-		token = tmp
-	}()
-
 	delim := data[0]
-	ecp := -1
+	token = make([]byte, 0, length)
 	for i := 1; i < length; i++ {
-		if data[i] == '\\' {
-			ecp = i
+		if data[i] != delim {
+			token = append(token, data[i])
+			continue
 		}
-		if data[i] == delim {
-			escapeEscaped := continuousCharacterCountFromBack(data[1:i], '\\')%2 == 0
-			if ecp == i-1 && !escapeEscaped {
-				ecps = append(ecps, ecp-1)
-			} else {
-				advance, token = i+1, data[1:i]
-				return
-			}
+		// keep half of the backslashes, the odd one escapes the delimiter
+		n := continuousCharacterCountFromBack(data[1:i], '\\')
+		token = token[:len(token)-n+n/2]
+		if n%2 == 0 {
+			return i + 1, token, nil
 		}
+		token = append(token, delim)
 	}
 	return 0, nil, ErrUnexpectedEnd
 }
@@ -28,6 +28,14 @@ func TestFscan(t *testing.T) {
 		{`(a b) c`, ErrLeftOverText},
 		{`(a b) ( c d )`, ErrLeftOverText},
 		{`'(' "(" "\"(\"" a b c)`, ErrUnmatchedParenthesis},
+		{`(`, ErrUnmatchedParenthesis},
+		{`{}`, nil},
+		{`(get {"a" 1 "b" (c {d e})} x)`, nil},
+		{`{"a" 1 "b"}`, ErrUnpairedKey},
+		{`(a {b c)}`, ErrUnmatchedParenthesis},
+		{`{`, ErrUnmatchedParenthesis},
+		{`{a b`, ErrLeftOverText},
+		{`{a b} c`, ErrLeftOverText},
 	}
 	for _, input := range inputs {
 		_, err := parse(input.exp)
@@ -37,20 +45,24 @@ func TestFscan(t *testing.T) {
 	}
 }
 
-func ExampleExpression_ignore() {
-	s := `(!(a b )())`
-	exp, err := parse(s)
+func TestParsePosition(t *testing.T) {
+	exp, err := parse(`(and
+  (eq "a" b)
+  (c))`)
 	if err != nil {
-		return
+		t.Fatal(err)
+	}
+	l := exp.i.(list)
+	eq := l[1].i.(list)
+	c := l[2].i.(list)
+	positions := []int{exp.pos, l[0].pos, l[1].pos, eq[0].pos, eq[1].pos, eq[2].pos, l[2].pos, c[0].pos}
+	want := []int{0, 1, 7, 8, 11, 15, 20, 21}
+	for i := range want {
+		if positions[i] != want[i] {
+			t.Errorf("wanna: %v, got: %v", want, positions)
+			break
+		}
 	}
-	exp.dump(0)
-	// Output:
-	// evaluator.list: 3 elements: [! [a b] []]
-	//    evaluator.varString: !
-	//    evaluator.list: 2 elements: [a b]
-	//       evaluator.varString: a
-	//       evaluator.varString: b
-	//    evaluator.list: 0 elements: []
 }
 
 func TestFscanStringWithQuotesStriped(t *testing.T) {
@@ -69,8 +81,9 @@ func TestFscanStringWithQuotesStriped(t *testing.T) {
 		{`"str ing"`, 9, `str ing`, nil},
 		{`'string'`, 8, `string`, nil},
 		{`'str\'ing'`, 10, `str'ing`, nil},
-		{`'str\\'ing'`, 7, `str\\`, nil},
-		{`'str\\\'ing'`, 12, `str\\'ing`, nil},
+		{`'str\\'ing'`, 7, `str\`, nil},
+		{`'str\\\'ing'`, 12, `str\'ing`, nil},
+		{`'str\ing\\'`, 11, `str\ing\`, nil},
 		{`'`, 0, ``, ErrUnexpectedEnd},
 		{`string`, 0, ``, ErrUnexpectedEnd},
 		{`'str\\\'`, 0, ``, ErrUnexpectedEnd},
@@ -85,3 +98,20 @@ func TestFscanStringWithQuotesStriped(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceRoundTrip(t *testing.T) {
+	for _, s := range []string{``, `a`, `a\`, `a\\`, `\a\`, `a"b`, `a"b'c`, "a\"b'c`d", "a\\\"b'c`", "a\\\\\"b'c`\\"} {
+		src := sexp{i: s}.source()
+		if src[0] != '"' && src[0] != '\'' && src[0] != '`' {
+			t.Errorf("%s shoud be printed as a literal, but %s", s, src)
+		}
+		e, err := New(`(eq x ` + src + `)`)
+		if err != nil {
+			t.Errorf("%s is printed as %s, which cannot be parsed: %v", s, src, err)
+			continue
+		}
+		if r, err := e.EvalBool(MapParams{"x": s}); err != nil || !r {
+			t.Errorf("%s is printed as %s, which is parsed as another string", s, src)
+		}
+	}
+}
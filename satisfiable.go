@@ -0,0 +1,563 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// ErrNotAnalyzable means the expression is beyond the subset which can be analyzed,
+// only eq ne gt lt ge le between in overlap and or not comparing variables with literals of number, string or version are supported
+var ErrNotAnalyzable = errors.New("not analyzable")
+
+// IsSatisfiable returns whether there are params making the Expression true, and the witness params if so
+func IsSatisfiable(e Expression) (MapParams, bool, error) {
+	return satisfy(e.exp)
+}
+
+// Implies returns whether b is true whenever a is true. The counterexample making a true but b false is returned if not
+func Implies(a, b Expression) (bool, MapParams, error) {
+	witness, ok, err := satisfy(call(0, function.FuncAnd, a.exp, call(0, function.FuncNot, b.exp)))
+	if err != nil {
+		return false, nil, err
+	}
+	return !ok, witness, nil
+}
+
+// Equivalent returns whether a and b are always the same. The counterexample making them different is returned if not
+func Equivalent(a, b Expression) (bool, MapParams, error) {
+	ok, witness, err := Implies(a, b)
+	if err != nil || !ok {
+		return ok, witness, err
+	}
+	return Implies(b, a)
+}
+
+// variable kinds, which decide the default value of the variable within witness
+const (
+	kindBool   = "bool"
+	kindNumber = "number"
+	kindString = "string"
+	kindList   = "list"
+)
+
+// atom is the constraint on a single variable
+type atom struct {
+	name     string
+	variable string
+	values   []interface{}
+}
+
+// parseAtom parses exp into atom, ok is false if exp is not a constraint
+func parseAtom(exp sexp) (atom, bool) {
+	if name, ok := exp.variable(); ok {
+		return atom{name: kindBool, variable: name}, true
+	}
+	name := exp.canonical()
+	if name == "" {
+		return atom{}, false
+	}
+	args := exp.args()
+	switch name {
+	case function.FuncEqual, function.FuncNotEqual:
+		if len(args) != 2 {
+			return atom{}, false
+		}
+		for i := 0; i < 2; i++ {
+			if v, ok := args[i].variable(); ok && args[1-i].isScalar() {
+				return atom{name: name, variable: v, values: []interface{}{args[1-i].constant()}}, true
+			}
+		}
+	case function.FuncGreaterThan, function.FuncLessThan, function.FuncGreaterThanOrEqualTo, function.FuncLessThanOrEqualTo:
+		if len(args) != 2 {
+			return atom{}, false
+		}
+		if v, ok := args[0].variable(); ok && isOrdered(args[1]) {
+			return atom{name: name, variable: v, values: []interface{}{args[1].constant()}}, true
+		}
+		if v, ok := args[1].variable(); ok && isOrdered(args[0]) {
+			return atom{name: flip[name], variable: v, values: []interface{}{args[0].constant()}}, true
+		}
+	case function.FuncBetween:
+		if len(args) != 3 {
+			return atom{}, false
+		}
+		if v, ok := args[0].variable(); ok && isOrdered(args[1]) && isOrdered(args[2]) {
+			return atom{name: name, variable: v, values: []interface{}{args[1].constant(), args[2].constant()}}, true
+		}
+	case function.FuncIn:
+		if len(args) != 2 {
+			return atom{}, false
+		}
+		if v, ok := args[0].variable(); ok {
+			if values, ok := scalars(args[1]); ok {
+				return atom{name: name, variable: v, values: values}, true
+			}
+		}
+	case function.FuncOverlap:
+		if len(args) != 2 {
+			return atom{}, false
+		}
+		for i := 0; i < 2; i++ {
+			if v, ok := args[i].variable(); ok {
+				if values, ok := scalars(args[1-i]); ok {
+					return atom{name: name, variable: v, values: values}, true
+				}
+			}
+		}
+	}
+	return atom{}, false
+}
+
+// flip maps the comparison onto the one with params swapped
+var flip = map[string]string{
+	function.FuncGreaterThan:          function.FuncLessThan,
+	function.FuncLessThan:             function.FuncGreaterThan,
+	function.FuncGreaterThanOrEqualTo: function.FuncLessThanOrEqualTo,
+	function.FuncLessThanOrEqualTo:    function.FuncGreaterThanOrEqualTo,
+}
+
+// negation maps the comparison onto its negation
+var negation = map[string]string{
+	function.FuncEqual:                function.FuncNotEqual,
+	function.FuncNotEqual:             function.FuncEqual,
+	function.FuncGreaterThan:          function.FuncLessThanOrEqualTo,
+	function.FuncLessThan:             function.FuncGreaterThanOrEqualTo,
+	function.FuncGreaterThanOrEqualTo: function.FuncLessThan,
+	function.FuncLessThanOrEqualTo:    function.FuncGreaterThan,
+}
+
+// isOrdered returns whether exp is a constant of number or string
+func isOrdered(exp sexp) bool {
+	if !exp.isConstant() {
+		return false
+	}
+	switch exp.constant().(type) {
+	case float64, string:
+		return true
+	}
+	return false
+}
+
+// scalars returns the values if exp is a list of scalar constants
+func scalars(exp sexp) ([]interface{}, bool) {
+	if f, ok := exp.i.(folded); ok {
+		exp = f.src
+	}
+	l, ok := exp.i.(list)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := exp.function(); ok {
+		return nil, false
+	}
+	values := make([]interface{}, len(l))
+	for i, e := range l {
+		if !e.isScalar() {
+			return nil, false
+		}
+		values[i] = e.constant()
+	}
+	return values, true
+}
+
+// compare returns the order of a and b, ok is false if they are not the same type
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+// domain describes the possible values of a variable
+type domain struct {
+	// allowed is the only possible values if restricted
+	restricted bool
+	allowed    []interface{}
+	excluded   []interface{}
+
+	// nil means unbounded
+	low, high         interface{}
+	lowOpen, highOpen bool
+
+	// for list variables, some element of each overlaps must be within the list
+	overlaps [][]interface{}
+	disjoint []interface{}
+}
+
+func (d *domain) clone() *domain {
+	c := *d
+	c.allowed = append([]interface{}(nil), d.allowed...)
+	c.excluded = append([]interface{}(nil), d.excluded...)
+	c.overlaps = append([][]interface{}(nil), d.overlaps...)
+	c.disjoint = append([]interface{}(nil), d.disjoint...)
+	return &c
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// restrict keeps only the allowed values within values
+func (d *domain) restrict(values []interface{}) {
+	if !d.restricted {
+		d.restricted = true
+		d.allowed = values
+		return
+	}
+	var res []interface{}
+	for _, v := range d.allowed {
+		if contains(values, v) {
+			res = append(res, v)
+		}
+	}
+	d.allowed = res
+}
+
+// bound tightens the lower or upper bound, false is returned if the type of v mismatches the bounds
+func (d *domain) bound(v interface{}, lower, open bool) bool {
+	for _, b := range []interface{}{d.low, d.high} {
+		if b == nil {
+			continue
+		}
+		if _, ok := compare(b, v); !ok {
+			return false
+		}
+	}
+	if lower {
+		c, _ := compare(v, d.low)
+		if d.low == nil || c > 0 || (c == 0 && open) {
+			d.low, d.lowOpen = v, open
+		}
+		return true
+	}
+	c, _ := compare(v, d.high)
+	if d.high == nil || c < 0 || (c == 0 && open) {
+		d.high, d.highOpen = v, open
+	}
+	return true
+}
+
+func (d *domain) within(v interface{}) bool {
+	if contains(d.excluded, v) {
+		return false
+	}
+	if d.low != nil {
+		c, ok := compare(v, d.low)
+		if !ok || c < 0 || (c == 0 && d.lowOpen) {
+			return false
+		}
+	}
+	if d.high != nil {
+		c, ok := compare(v, d.high)
+		if !ok || c > 0 || (c == 0 && d.highOpen) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick returns a possible value of the variable, ok is false if there is none
+func (d *domain) pick(kind string) (interface{}, bool) {
+	if kind == kindList {
+		var res []interface{}
+		for _, values := range d.overlaps {
+			found := false
+			for _, v := range values {
+				if !contains(d.disjoint, v) {
+					if !contains(res, v) {
+						res = append(res, v)
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+		if res == nil {
+			res = []interface{}{}
+		}
+		return res, true
+	}
+	for _, v := range d.candidates(kind) {
+		if d.within(v) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// candidates returns the values to try, there is always one within the domain among them if the domain is not empty
+func (d *domain) candidates(kind string) []interface{} {
+	if d.restricted {
+		return d.allowed
+	}
+	// the excluded values rule out at most n-1 candidates
+	n := len(d.excluded) + 1
+	var res []interface{}
+	if d.low != nil {
+		res = append(res, d.low)
+	}
+	if d.high != nil {
+		res = append(res, d.high)
+	}
+	bound := d.low
+	if bound == nil {
+		bound = d.high
+	}
+	if bound != nil {
+		switch bound.(type) {
+		case float64:
+			kind = kindNumber
+		case string:
+			kind = kindString
+		}
+	}
+	switch kind {
+	case kindBool:
+		res = append(res, false, true)
+	case kindNumber:
+		low, high := math.Inf(-1), math.Inf(1)
+		if d.low != nil {
+			low = d.low.(float64)
+		}
+		if d.high != nil {
+			high = d.high.(float64)
+		}
+		start := 0.0
+		if !math.IsInf(low, 0) {
+			start = math.Ceil(low)
+		} else if !math.IsInf(high, 0) {
+			start = math.Floor(high) - float64(n)
+		}
+		for i := 0; i <= n; i++ {
+			res = append(res, start+float64(i))
+		}
+		if !math.IsInf(low, 0) && !math.IsInf(high, 0) {
+			for i := 1; i <= n+1; i++ {
+				res = append(res, low+(high-low)*float64(i)/float64(n+2))
+			}
+		}
+	default:
+		base := ""
+		if d.low != nil {
+			base = d.low.(string)
+		}
+		for i := 0; i <= n; i++ {
+			res = append(res, base+strings.Repeat("\x00", i))
+		}
+		for r := 'a'; r <= 'z'; r++ {
+			res = append(res, base+string(r))
+		}
+	}
+	return res
+}
+
+// apply adds the positive or negative constraint of a onto d, false is returned if it turns out to be unsatisfiable
+func (d *domain) apply(a atom, positive bool) bool {
+	name := a.name
+	if !positive {
+		if n, ok := negation[name]; ok {
+			name, positive = n, true
+		}
+	}
+	switch name {
+	case kindBool:
+		d.restrict([]interface{}{positive})
+	case function.FuncEqual:
+		d.restrict(a.values)
+	case function.FuncNotEqual:
+		d.excluded = append(d.excluded, a.values...)
+	case function.FuncGreaterThan:
+		return d.bound(a.values[0], true, true)
+	case function.FuncGreaterThanOrEqualTo:
+		return d.bound(a.values[0], true, false)
+	case function.FuncLessThan:
+		return d.bound(a.values[0], false, true)
+	case function.FuncLessThanOrEqualTo:
+		return d.bound(a.values[0], false, false)
+	case function.FuncBetween:
+		return d.bound(a.values[0], true, false) && d.bound(a.values[1], false, false)
+	case function.FuncIn:
+		if positive {
+			d.restrict(a.values)
+		} else {
+			d.excluded = append(d.excluded, a.values...)
+		}
+	case function.FuncOverlap:
+		if positive {
+			d.overlaps = append(d.overlaps, a.values)
+		} else {
+			d.disjoint = append(d.disjoint, a.values...)
+		}
+	}
+	return true
+}
+
+// goal is the expression expected to be true if positive, or false if not
+type goal struct {
+	exp      sexp
+	positive bool
+}
+
+type solver struct {
+	kinds map[string]string
+}
+
+func satisfy(exp sexp) (MapParams, bool, error) {
+	s := solver{kinds: make(map[string]string)}
+	if err := s.collect(exp); err != nil {
+		return nil, false, err
+	}
+	state, ok := s.solve([]goal{{exp: exp, positive: true}}, make(map[string]*domain))
+	if !ok {
+		return nil, false, nil
+	}
+	witness := make(MapParams, len(s.kinds))
+	for name, kind := range s.kinds {
+		d, ok := state[name]
+		if !ok {
+			d = &domain{}
+		}
+		v, _ := d.pick(kind)
+		witness[name] = v
+	}
+	return witness, true, nil
+}
+
+// collect checks whether exp is analyzable and records the kinds of variables
+func (s *solver) collect(exp sexp) error {
+	if exp.isConstant() {
+		if _, ok := exp.constant().(bool); ok {
+			return nil
+		}
+	}
+	switch exp.canonical() {
+	case function.FuncAnd, function.FuncOr, function.FuncNot:
+		for _, arg := range exp.args() {
+			if err := s.collect(arg); err != nil {
+				return err
+			}
+		}
+		if exp.canonical() == function.FuncNot && len(exp.args()) != 1 {
+			break
+		}
+		return nil
+	}
+	a, ok := parseAtom(exp)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotAnalyzable, exp.source())
+	}
+	kind := kindBool
+	switch a.name {
+	case function.FuncOverlap:
+		kind = kindList
+	case kindBool:
+	default:
+		kind = kindString
+		if _, ok := a.values[0].(float64); ok {
+			kind = kindNumber
+		}
+		if _, ok := a.values[0].(bool); ok {
+			kind = kindBool
+		}
+	}
+	if k, ok := s.kinds[a.variable]; ok && (k == kindList) != (kind == kindList) {
+		return fmt.Errorf("%w: %s is used as both list and scalar", ErrNotAnalyzable, a.variable)
+	} else if !ok {
+		s.kinds[a.variable] = kind
+	}
+	return nil
+}
+
+// solve returns the domains of variables making all the goals achieved, false is returned if impossible
+func (s *solver) solve(goals []goal, state map[string]*domain) (map[string]*domain, bool) {
+	for len(goals) > 0 {
+		g := goals[0]
+		goals = goals[1:]
+		if g.exp.isConstant() {
+			if b, _ := g.exp.constant().(bool); b != g.positive {
+				return nil, false
+			}
+			continue
+		}
+		switch name := g.exp.canonical(); name {
+		case function.FuncNot:
+			goals = append([]goal{{exp: g.exp.args()[0], positive: !g.positive}}, goals...)
+			continue
+		case function.FuncAnd, function.FuncOr:
+			args := g.exp.args()
+			if (name == function.FuncAnd) == g.positive {
+				sub := make([]goal, 0, len(args)+len(goals))
+				for _, arg := range args {
+					sub = append(sub, goal{exp: arg, positive: g.positive})
+				}
+				goals = append(sub, goals...)
+				continue
+			}
+			for _, arg := range args {
+				branch := append([]goal{{exp: arg, positive: g.positive}}, goals...)
+				if res, ok := s.solve(branch, clone(state)); ok {
+					return res, true
+				}
+			}
+			return nil, false
+		}
+
+		a, _ := parseAtom(g.exp)
+		if a.name == function.FuncBetween && !g.positive {
+			v := sexp{i: varString(a.variable)}
+			outside := call(0, function.FuncOr,
+				call(0, function.FuncLessThan, v, sexp{i: a.values[0]}),
+				call(0, function.FuncGreaterThan, v, sexp{i: a.values[1]}))
+			goals = append([]goal{{exp: outside, positive: true}}, goals...)
+			continue
+		}
+		d, ok := state[a.variable]
+		if !ok {
+			d = &domain{}
+			state[a.variable] = d
+		}
+		if !d.apply(a, g.positive) {
+			return nil, false
+		}
+		if _, ok := d.pick(s.kinds[a.variable]); !ok {
+			return nil, false
+		}
+	}
+	return state, true
+}
+
+func clone(state map[string]*domain) map[string]*domain {
+	c := make(map[string]*domain, len(state))
+	for k, v := range state {
+		c[k] = v.clone()
+	}
+	return c
+}
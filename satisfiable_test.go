@@ -0,0 +1,161 @@
+package evaluator
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestIsSatisfiable(t *testing.T) {
+	type input struct {
+		expr string
+		ok   bool
+	}
+	inputs := []input{
+		{`(eq os "ios")`, true},
+		{`(and (eq os "ios") (eq os "android"))`, false},
+		{`(and (eq os "ios") (ne os "ios"))`, false},
+		{`(and (in os ("ios" "android")) (ne os "ios"))`, true},
+		{`(and (in os ("ios" "android")) (not (in os ("android" "ios"))))`, false},
+		{`(and (gt years 18) (lt years 18))`, false},
+		{`(and (ge years 18) (le years 18))`, true},
+		{`(and (ge years 18) (le years 18) (ne years 18))`, false},
+		{`(and (gt years 18) (lt years 19) (ne years 18.5))`, true},
+		{`(and (between years 18 30) (not (between years 10 40)))`, false},
+		{`(and (between years 18 30) (not (between years 20 40)))`, true},
+		{`(and (gt 18 years) (gt years 17))`, true},
+		{`(and (between app_version (t_version "2.7.1") (t_version "2.9.1")) (lt app_version (t_version "2.7.0")))`, false},
+		{`(and (between app_version (t_version "2.7.1") (t_version "2.9.1")) (lt app_version (t_version "2.8")))`, true},
+		{`(and (gt name "b") (lt name "c"))`, true},
+		{"(and (gt name \"b\") (lt name \"b\x00\"))", false},
+		{`(and (overlap region (1 2)) (not (overlap region (1 2 3))))`, false},
+		{`(and (overlap region (1 2)) (overlap region (3 4)) (not (overlap region (1 3))))`, true},
+		{`(and vip (not vip))`, false},
+		{`(or (and vip (not vip)) (eq os "ios"))`, true},
+		{`(not (or (eq os "ios") (ne os "ios")))`, false},
+		{`(and (eq years 1) (eq years "1"))`, false},
+		{`(and (eq day 3) (eq day 4))`, false},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		witness, ok, err := IsSatisfiable(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != input.ok {
+			t.Errorf("expression `%s` wanna: %v, got: %v", input.expr, input.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		r, err := e.EvalBool(witness)
+		if err != nil || !r {
+			t.Errorf("expression `%s` with witness %v got: %v, %v", input.expr, witness, r, err)
+		}
+	}
+
+	for _, expr := range []string{`(eq years age_limit)`, `(lt now (td_time "2017-09-09 12:00:00"))`, `(eq (mod years 2) 0)`} {
+		e, err := New(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := IsSatisfiable(e); !errors.Is(err, ErrNotAnalyzable) {
+			t.Errorf("expression `%s` wanna: %v, got: %v", expr, ErrNotAnalyzable, err)
+		}
+	}
+}
+
+func TestImplies(t *testing.T) {
+	type input struct {
+		a, b       string
+		implies    bool
+		equivalent bool
+	}
+	inputs := []input{
+		{`(eq os "ios")`, `(in os ("ios" "android"))`, true, false},
+		{`(between years 18 30)`, `(ge years 18)`, true, false},
+		{`(and (ge years 18) (le years 30))`, `(between years 18 30)`, true, true},
+		{`(not (and (eq os "ios") (gt years 18)))`, `(or (ne os "ios") (le years 18))`, true, true},
+		{`(or (eq os "ios") (eq os "android"))`, `(in os ("android" "ios"))`, true, true},
+		{`(gt years 18)`, `(gt years 20)`, false, false},
+		{`(eq os "ios")`, `(eq os "android")`, false, false},
+	}
+	for _, input := range inputs {
+		a, err := New(input.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := New(input.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, counterexample, err := Implies(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != input.implies {
+			t.Errorf("`%s` implies `%s` wanna: %v, got: %v", input.a, input.b, input.implies, ok)
+		}
+		if !ok {
+			ra, _ := a.EvalBool(counterexample)
+			rb, _ := b.EvalBool(counterexample)
+			if !ra || rb {
+				t.Errorf("`%s` implies `%s` got wrong counterexample %v", input.a, input.b, counterexample)
+			}
+		}
+		ok, counterexample, err = Equivalent(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != input.equivalent {
+			t.Errorf("`%s` equivalent to `%s` wanna: %v, got: %v", input.a, input.b, input.equivalent, ok)
+		}
+		if !ok {
+			ra, _ := a.EvalBool(counterexample)
+			rb, _ := b.EvalBool(counterexample)
+			if ra == rb {
+				t.Errorf("`%s` equivalent to `%s` got wrong counterexample %v", input.a, input.b, counterexample)
+			}
+		}
+	}
+}
+
+func TestIsSatisfiableRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	var params []MapParams
+	for _, a := range []bool{true, false} {
+		for _, b := range []bool{true, false} {
+			for x := -1; x <= 8; x++ {
+				for _, s := range []string{"a", "b", "c", "d"} {
+					params = append(params, MapParams{"a": a, "b": b, "x": x, "s": s})
+				}
+			}
+		}
+	}
+	for i := 0; i < 500; i++ {
+		expr := randomExpr(r, 4)
+		e, err := New(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		witness, ok, err := IsSatisfiable(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			if r, err := e.EvalBool(witness); err != nil || !r {
+				t.Fatalf("expression `%s` with witness %v got: %v, %v", expr, witness, r, err)
+			}
+			continue
+		}
+		for _, p := range params {
+			if r, _ := e.EvalBool(p); r {
+				t.Fatalf("expression `%s` is satisfied by %v", expr, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package evaluator
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// ErrTooComplex means the normal form of the expression has too many clauses
+var ErrTooComplex = errors.New("expression is too complex")
+
+// MaxNormalFormClauses limits the count of clauses when converting to DNF or CNF
+var MaxNormalFormClauses = 1024
+
+// canonicalNames maps the operators onto the function names
+var canonicalNames = map[string]string{
+	function.OperatorAnd:                  function.FuncAnd,
+	function.OperatorOr:                   function.FuncOr,
+	function.OperatorNot:                  function.FuncNot,
+	function.OperatorEqual:                function.FuncEqual,
+	function.OperatorNotEqual:             function.FuncNotEqual,
+	function.OperatorGreaterThan:          function.FuncGreaterThan,
+	function.OperatorLessThan:             function.FuncLessThan,
+	function.OperatorGreaterThanOrEqualTo: function.FuncGreaterThanOrEqualTo,
+	function.OperatorLessThanOrEqualTo:    function.FuncLessThanOrEqualTo,
+	function.OperatorModulo:               function.FuncModulo,
+}
+
+// canonical returns the function name of exp if it is a call, operators are replaced by the function names
+func (exp sexp) canonical() string {
+	name, _ := exp.function()
+	return canonicalName(name)
+}
+
+// canonicalName returns the function name if name is an operator
+func canonicalName(name string) string {
+	if c, ok := canonicalNames[name]; ok {
+		return c
+	}
+	return name
+}
+
+// args returns the params of a call
+func (exp sexp) args() list {
+	return exp.i.(list)[1:]
+}
+
+// variable returns the name if exp is a variable. exp is the argument of function, so a symbol sharing
+// the name with a function is variable as well, which is read from params first
+func (exp sexp) variable() (string, bool) {
+	v, ok := exp.i.(varString)
+	if !ok {
+		return "", false
+	}
+	return string(v), true
+}
+
+// Simplify returns the simplified Expression which is equivalent to e whenever e is evaluated without error.
+// Nested and, or are flattened, duplicate clauses are removed, absorption and De Morgan's laws are applied,
+// double negation is folded, eq disjunctions on the same variable are merged into in,
+// and between ranges with number bounds on the same variable are merged
+func (e Expression) Simplify() Expression {
+	return Expression{exp: e.exp.simplify().optimize(), src: e.src}
+}
+
+func (exp sexp) simplify() sexp {
+	if _, ok := exp.function(); !ok {
+		return exp
+	}
+	l := exp.i.(list)
+	args := make(list, len(l)-1)
+	for i, arg := range l[1:] {
+		args[i] = arg.simplify()
+	}
+	switch name := exp.canonical(); name {
+	case function.FuncNot:
+		if len(args) == 1 {
+			return negate(args[0], exp.pos)
+		}
+	case function.FuncAnd, function.FuncOr:
+		if len(args) >= 2 {
+			return junction(name, args, exp.pos)
+		}
+	}
+	return sexp{i: append(list{l[0]}, args...), pos: exp.pos}
+}
+
+// negate returns the simplified negation of the simplified exp
+func negate(exp sexp, pos int) sexp {
+	if b, ok := exp.constant().(bool); ok && exp.isConstant() {
+		return sexp{i: !b, pos: pos}
+	}
+	switch exp.canonical() {
+	case function.FuncNot:
+		if args := exp.args(); len(args) == 1 {
+			return args[0]
+		}
+	case function.FuncAnd, function.FuncOr:
+		if args := exp.args(); len(args) >= 2 {
+			negated := make(list, len(args))
+			for i, arg := range args {
+				negated[i] = negate(arg, arg.pos)
+			}
+			return junction(dual(exp.canonical()), negated, pos)
+		}
+	case function.FuncEqual:
+		if args := exp.args(); len(args) == 2 {
+			return call(exp.pos, function.FuncNotEqual, args...)
+		}
+	case function.FuncNotEqual:
+		if args := exp.args(); len(args) == 2 {
+			return call(exp.pos, function.FuncEqual, args...)
+		}
+	}
+	return call(pos, function.FuncNot, exp)
+}
+
+func dual(name string) string {
+	if name == function.FuncAnd {
+		return function.FuncOr
+	}
+	return function.FuncAnd
+}
+
+// junction returns the simplified and, or of the simplified args
+func junction(name string, args list, pos int) sexp {
+	// the value which decides the result by itself, false for and, true for or
+	decisive := name == function.FuncOr
+
+	var flattened list
+	for _, arg := range args {
+		if arg.canonical() == name {
+			flattened = append(flattened, arg.args()...)
+		} else {
+			flattened = append(flattened, arg)
+		}
+	}
+
+	clauses := make(list, 0, len(flattened))
+	for _, arg := range flattened {
+		if b, ok := arg.constant().(bool); ok && arg.isConstant() {
+			if b == decisive {
+				return sexp{i: decisive, pos: pos}
+			}
+			continue
+		}
+		clauses = append(clauses, arg)
+	}
+
+	seen := make(map[string]bool, len(clauses))
+	unique := clauses[:0]
+	for _, c := range clauses {
+		if key := c.source(); !seen[key] {
+			seen[key] = true
+			unique = append(unique, c)
+		}
+	}
+	clauses = unique
+
+	// complement law: (and a (not a)) is false, (or a (not a)) is true
+	for _, c := range clauses {
+		if seen[negate(c, c.pos).source()] {
+			return sexp{i: decisive, pos: pos}
+		}
+	}
+
+	if reduced, ok := resolve(dual(name), clauses); ok {
+		return junction(name, reduced, pos)
+	}
+	clauses = absorb(dual(name), clauses)
+
+	if name == function.FuncOr {
+		clauses = mergeEqual(clauses)
+	}
+	clauses, empty := mergeBetween(clauses, name == function.FuncOr)
+	if empty {
+		return sexp{i: false, pos: pos}
+	}
+
+	switch len(clauses) {
+	case 0:
+		return sexp{i: !decisive, pos: pos}
+	case 1:
+		return clauses[0]
+	}
+	return call(pos, name, clauses...)
+}
+
+// resolve removes the terms within clauses which are negations of the sibling clauses, e.g. (and a (or (not a) b)) is (and a b).
+// inner is the operator within the clauses, ok is false if nothing is removed
+func resolve(inner string, clauses list) (list, bool) {
+	siblings := make(map[string]bool)
+	for _, c := range clauses {
+		if c.canonical() != inner {
+			siblings[c.source()] = true
+		}
+	}
+	if len(siblings) == 0 {
+		return nil, false
+	}
+	res := make(list, len(clauses))
+	changed := false
+	for i, c := range clauses {
+		res[i] = c
+		if c.canonical() != inner {
+			continue
+		}
+		var rest list
+		for _, arg := range c.args() {
+			if !siblings[negate(arg, arg.pos).source()] {
+				rest = append(rest, arg)
+			}
+		}
+		if len(rest) < len(c.args()) {
+			res[i] = junction(inner, rest, c.pos)
+			changed = true
+		}
+	}
+	return res, changed
+}
+
+// absorb removes the clauses subsumed by others, e.g. (and a (or a b)) is a, (or a (and a b)) is a as well.
+// inner is the operator within the clauses
+func absorb(inner string, clauses list) list {
+	sets := make([]map[string]bool, len(clauses))
+	for i, c := range clauses {
+		sets[i] = make(map[string]bool)
+		if c.canonical() == inner {
+			for _, arg := range c.args() {
+				sets[i][arg.source()] = true
+			}
+		} else {
+			sets[i][c.source()] = true
+		}
+	}
+	subset := func(a, b map[string]bool) bool {
+		if len(a) > len(b) {
+			return false
+		}
+		for k := range a {
+			if !b[k] {
+				return false
+			}
+		}
+		return true
+	}
+	removed := make([]bool, len(clauses))
+	res := make(list, 0, len(clauses))
+	for i := range clauses {
+		for j := range clauses {
+			if i == j || removed[j] || !subset(sets[j], sets[i]) {
+				continue
+			}
+			if len(sets[j]) < len(sets[i]) || j < i {
+				removed[i] = true
+				break
+			}
+		}
+		if !removed[i] {
+			res = append(res, clauses[i])
+		}
+	}
+	return res
+}
+
+// isScalar returns whether exp is a constant of number, string or boolean
+func (exp sexp) isScalar() bool {
+	if !exp.isConstant() {
+		return false
+	}
+	switch exp.constant().(type) {
+	case float64, string, bool:
+		return true
+	}
+	return false
+}
+
+// membership returns the variable and values if exp is (eq variable value) or (in variable (values...))
+func (exp sexp) membership() (string, list, bool) {
+	switch exp.canonical() {
+	case function.FuncEqual:
+		args := exp.args()
+		if len(args) != 2 {
+			return "", nil, false
+		}
+		for i := 0; i < 2; i++ {
+			if name, ok := args[i].variable(); ok && args[1-i].isScalar() {
+				return name, list{args[1-i]}, true
+			}
+		}
+	case function.FuncIn:
+		args := exp.args()
+		if len(args) != 2 {
+			return "", nil, false
+		}
+		name, ok := args[0].variable()
+		if !ok {
+			return "", nil, false
+		}
+		values := args[1]
+		if f, ok := values.i.(folded); ok {
+			values = f.src
+		}
+		l, ok := values.i.(list)
+		if !ok {
+			return "", nil, false
+		}
+		if _, ok := values.function(); ok {
+			return "", nil, false
+		}
+		for _, v := range l {
+			if !v.isScalar() {
+				return "", nil, false
+			}
+		}
+		return name, l, true
+	}
+	return "", nil, false
+}
+
+// mergeEqual merges the eq and in disjunctions on the same variable into one in
+func mergeEqual(clauses list) list {
+	type group struct {
+		first  int
+		count  int
+		values list
+	}
+	groups := make(map[string]*group)
+	for i, c := range clauses {
+		name, values, ok := c.membership()
+		if !ok {
+			continue
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &group{first: i}
+			groups[name] = g
+		}
+		g.count++
+		g.values = append(g.values, values...)
+	}
+	res := make(list, 0, len(clauses))
+	for i, c := range clauses {
+		name, _, ok := c.membership()
+		if !ok || groups[name].count < 2 {
+			res = append(res, c)
+			continue
+		}
+		g := groups[name]
+		if g.first != i {
+			continue
+		}
+		seen := make(map[string]bool)
+		values := make(list, 0, len(g.values))
+		for _, v := range g.values {
+			if key := v.source(); !seen[key] {
+				seen[key] = true
+				values = append(values, v)
+			}
+		}
+		res = append(res, call(c.pos, function.FuncIn, sexp{i: varString(name), pos: c.pos}, sexp{i: values, pos: c.pos}))
+	}
+	return res
+}
+
+type numberRange struct {
+	low, high   sexp
+	left, right float64
+}
+
+// numberRange returns the variable and range if exp is (between variable low high) with number bounds
+func (exp sexp) numberRange() (string, numberRange, bool) {
+	if exp.canonical() != function.FuncBetween {
+		return "", numberRange{}, false
+	}
+	args := exp.args()
+	if len(args) != 3 {
+		return "", numberRange{}, false
+	}
+	name, ok := args[0].variable()
+	if !ok || !args[1].isConstant() || !args[2].isConstant() {
+		return "", numberRange{}, false
+	}
+	left, ok1 := args[1].constant().(float64)
+	right, ok2 := args[2].constant().(float64)
+	if !ok1 || !ok2 {
+		return "", numberRange{}, false
+	}
+	return name, numberRange{low: args[1], high: args[2], left: left, right: right}, true
+}
+
+// mergeBetween merges the between ranges on the same variable, the union is taken for or and the intersection for and.
+// empty is true if the intersection is empty
+func mergeBetween(clauses list, union bool) (res list, empty bool) {
+	type group struct {
+		first  int
+		ranges []numberRange
+	}
+	groups := make(map[string]*group)
+	for i, c := range clauses {
+		name, r, ok := c.numberRange()
+		if !ok {
+			continue
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &group{first: i}
+			groups[name] = g
+		}
+		g.ranges = append(g.ranges, r)
+	}
+	res = make(list, 0, len(clauses))
+	for i, c := range clauses {
+		name, _, ok := c.numberRange()
+		if !ok || len(groups[name].ranges) < 2 {
+			res = append(res, c)
+			continue
+		}
+		g := groups[name]
+		if g.first != i {
+			continue
+		}
+		v := sexp{i: varString(name), pos: c.pos}
+		rs := g.ranges
+		if union {
+			sort.Slice(rs, func(i, j int) bool { return rs[i].left < rs[j].left })
+			merged := []numberRange{rs[0]}
+			for _, r := range rs[1:] {
+				last := &merged[len(merged)-1]
+				if r.left > last.right {
+					merged = append(merged, r)
+				} else if r.right > last.right {
+					last.high, last.right = r.high, r.right
+				}
+			}
+			for _, r := range merged {
+				res = append(res, call(c.pos, function.FuncBetween, v, r.low, r.high))
+			}
+			continue
+		}
+		r := rs[0]
+		for _, o := range rs[1:] {
+			if o.left > r.left {
+				r.low, r.left = o.low, o.left
+			}
+			if o.right < r.right {
+				r.high, r.right = o.high, o.right
+			}
+		}
+		if r.left > r.right {
+			return nil, true
+		}
+		res = append(res, call(c.pos, function.FuncBetween, v, r.low, r.high))
+	}
+	return res, false
+}
+
+// DNF returns the Expression in disjunctive normal form, which is or of ands.
+// ErrTooComplex is returned if there are more than MaxNormalFormClauses clauses
+func (e Expression) DNF() (Expression, error) {
+	return e.normalForm(function.FuncOr)
+}
+
+// CNF returns the Expression in conjunctive normal form, which is and of ors.
+// ErrTooComplex is returned if there are more than MaxNormalFormClauses clauses
+func (e Expression) CNF() (Expression, error) {
+	return e.normalForm(function.FuncAnd)
+}
+
+func (e Expression) normalForm(outer string) (Expression, error) {
+	exp := e.exp.simplify()
+	clauses, err := normalClauses(exp, outer)
+	if err != nil {
+		return Expression{}, err
+	}
+	inner := dual(outer)
+	terms := make(list, 0, len(clauses))
+	for _, c := range clauses {
+		if len(c) == 1 {
+			terms = append(terms, c[0])
+		} else {
+			terms = append(terms, junction(inner, c, c[0].pos))
+		}
+	}
+	if len(terms) == 1 {
+		exp = terms[0]
+	} else {
+		exp = junction(outer, terms, exp.pos)
+	}
+	return Expression{exp: exp.optimize(), src: e.src}, nil
+}
+
+// normalClauses returns the clauses of the normal form, the terms within each clause are joined by the inner operator
+func normalClauses(exp sexp, outer string) ([]list, error) {
+	switch exp.canonical() {
+	case outer:
+		var res []list
+		for _, arg := range exp.args() {
+			cs, err := normalClauses(arg, outer)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, cs...)
+			if len(res) > MaxNormalFormClauses {
+				return nil, ErrTooComplex
+			}
+		}
+		return res, nil
+	case dual(outer):
+		res := []list{{}}
+		for _, arg := range exp.args() {
+			cs, err := normalClauses(arg, outer)
+			if err != nil {
+				return nil, err
+			}
+			if len(res)*len(cs) > MaxNormalFormClauses {
+				return nil, ErrTooComplex
+			}
+			product := make([]list, 0, len(res)*len(cs))
+			for _, r := range res {
+				for _, c := range cs {
+					p := make(list, 0, len(r)+len(c))
+					product = append(product, append(append(p, r...), c...))
+				}
+			}
+			res = product
+		}
+		return res, nil
+	}
+	return []list{{exp}}, nil
+}
@@ -0,0 +1,189 @@
+package evaluator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	type input struct {
+		expr       string
+		simplified string
+	}
+	inputs := []input{
+		{`(and a (and b c))`, `(and a b c)`},
+		{`(or a (| b c) a)`, `(or a b c)`},
+		{`(and a b a)`, `(and a b)`},
+		{`(not (not a))`, `a`},
+		{`(! (! (! a)))`, `(not a)`},
+		{`(not (and a b))`, `(or (not a) (not b))`},
+		{`(not (or a (eq x 1)))`, `(and (not a) (ne x 1))`},
+		{`(and a (or a b))`, `a`},
+		{`(or a (and b a))`, `a`},
+		{`(and (or a b) (or a b c))`, `(or a b)`},
+		{`(and a (not a))`, `false`},
+		{`(or a (not a))`, `true`},
+		{`(and a (or (not a) b))`, `(and a b)`},
+		{`(and a (eq 1 1))`, `a`},
+		{`(or a (eq 1 1))`, `true`},
+		{`(or (eq os "ios") (eq os "android") (= "web" os))`, `(in os ("ios" "android" "web"))`},
+		{`(or (eq os "ios") (in os ("android" "ios")) a)`, `(or (in os ("ios" "android")) a)`},
+		{`(or (eq os "ios") (eq version 1))`, `(or (eq os "ios") (eq version 1))`},
+		{`(or (between x 1 5) (between x 3 8) (between x 10 12))`, `(or (between x 1 8) (between x 10 12))`},
+		{`(or (between x 1 5) (between x 5 8))`, `(between x 1 8)`},
+		{`(and (between x 1 5) (between x 3 8))`, `(between x 3 5)`},
+		{`(and (between x 1 5) (between x 6 8))`, `false`},
+		{`(or (between v (t_version "1.0") (t_version "2.0")) (between v (t_version "1.5") (t_version "3.0")))`, `(between v (t_version "1.0") (t_version "3.0"))`},
+		{`(and
+  (and (eq os "android") (ge app_version (t_version "4.0.6") ))
+  (or (and (eq os "android") (ne affiliate "googleplay")) (ne os "android"))
+  (eq language "zh-Hans")
+	)`, `(and (eq os "android") (ge app_version (t_version "4.0.6")) (ne affiliate "googleplay") (eq language "zh-Hans"))`},
+		{`(eq x 1)`, `(eq x 1)`},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.Simplify().String(); got != input.simplified {
+			t.Errorf("expression `%s` wanna: %s, got: %s", input.expr, input.simplified, got)
+		}
+	}
+}
+
+func TestNormalForm(t *testing.T) {
+	type input struct {
+		expr string
+		dnf  string
+		cnf  string
+	}
+	inputs := []input{
+		{`(and a (or b c))`, `(or (and a b) (and a c))`, `(and a (or b c))`},
+		{`(or a (and b c))`, `(or a (and b c))`, `(and (or a b) (or a c))`},
+		{`(not (or a (and b c)))`, `(or (and (not a) (not b)) (and (not a) (not c)))`, `(and (not a) (or (not b) (not c)))`},
+		{`a`, `a`, `a`},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dnf, err := e.DNF()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dnf.String() != input.dnf {
+			t.Errorf("expression `%s` wanna dnf: %s, got: %s", input.expr, input.dnf, dnf.String())
+		}
+		cnf, err := e.CNF()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cnf.String() != input.cnf {
+			t.Errorf("expression `%s` wanna cnf: %s, got: %s", input.expr, input.cnf, cnf.String())
+		}
+	}
+
+	clauses := make([]string, 12)
+	for i := range clauses {
+		clauses[i] = fmt.Sprintf("(or a%d b%d)", i, i)
+	}
+	e, err := New(`(and ` + strings.Join(clauses, " ") + `)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.DNF(); err != ErrTooComplex {
+		t.Errorf("wanna: %v, got: %v", ErrTooComplex, err)
+	}
+}
+
+// randomExpr generates boolean expression with variables a, b (boolean), x (number) and s (string)
+func randomExpr(r *rand.Rand, depth int) string {
+	if depth == 0 || r.Intn(4) == 0 {
+		switch r.Intn(7) {
+		case 0:
+			return []string{"a", "b"}[r.Intn(2)]
+		case 1:
+			return fmt.Sprintf("(eq x %d)", r.Intn(6))
+		case 2:
+			return fmt.Sprintf("(ne s %q)", string(rune('a'+r.Intn(3))))
+		case 3:
+			return fmt.Sprintf("(= s %q)", string(rune('a'+r.Intn(3))))
+		case 4:
+			lo := r.Intn(6)
+			return fmt.Sprintf("(between x %d %d)", lo, lo+r.Intn(3))
+		case 5:
+			return fmt.Sprintf("(in s (%q %q))", string(rune('a'+r.Intn(3))), string(rune('a'+r.Intn(3))))
+		default:
+			return fmt.Sprintf("(gt x %d)", r.Intn(6))
+		}
+	}
+	switch r.Intn(3) {
+	case 0:
+		return fmt.Sprintf("(not %s)", randomExpr(r, depth-1))
+	case 1:
+		n := 2 + r.Intn(3)
+		args := make([]string, n)
+		for i := range args {
+			args[i] = randomExpr(r, depth-1)
+		}
+		return fmt.Sprintf("(and %s)", strings.Join(args, " "))
+	default:
+		n := 2 + r.Intn(3)
+		args := make([]string, n)
+		for i := range args {
+			args[i] = randomExpr(r, depth-1)
+		}
+		return fmt.Sprintf("(or %s)", strings.Join(args, " "))
+	}
+}
+
+func TestSimplifyEquivalence(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var params []MapParams
+	for _, a := range []bool{true, false} {
+		for _, b := range []bool{true, false} {
+			for x := -1; x <= 8; x++ {
+				for _, s := range []string{"a", "b", "c", "d"} {
+					params = append(params, MapParams{"a": a, "b": b, "x": x, "s": s})
+				}
+			}
+		}
+	}
+	for i := 0; i < 500; i++ {
+		expr := randomExpr(r, 4)
+		e, err := New(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		forms := map[string]Expression{"simplified": e.Simplify()}
+		if dnf, err := e.DNF(); err == nil {
+			forms["dnf"] = dnf
+		} else if err != ErrTooComplex {
+			t.Fatal(err)
+		}
+		if cnf, err := e.CNF(); err == nil {
+			forms["cnf"] = cnf
+		} else if err != ErrTooComplex {
+			t.Fatal(err)
+		}
+		for _, p := range params {
+			want, err := e.EvalBool(p)
+			if err != nil {
+				t.Fatalf("expression `%s` with %v: %v", expr, p, err)
+			}
+			for name, f := range forms {
+				got, err := f.EvalBool(p)
+				if err != nil {
+					t.Fatalf("%s `%s` of `%s` with %v: %v", name, f, expr, p, err)
+				}
+				if got != want {
+					t.Fatalf("%s `%s` of `%s` with %v wanna: %v, got: %v", name, f, expr, p, want, got)
+				}
+			}
+		}
+	}
+}
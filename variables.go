@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"reflect"
+
+	"github.com/nullne/evaluator/function"
+)
+
+// Variable describes how a variable is used within Expression
+type Variable struct {
+	Name string
+	// Positions are where the variable occurs within the source
+	Positions []Position
+	// Funcs are the functions which the variable is passed to, operators are given by the function names
+	Funcs []string
+	// Values are the literal values which the variable is compared against
+	Values []interface{}
+}
+
+// comparisons are the functions whose literal params are collected as the values compared against
+var comparisons = map[string]bool{
+	function.FuncEqual:                true,
+	function.FuncNotEqual:             true,
+	function.FuncGreaterThan:          true,
+	function.FuncLessThan:             true,
+	function.FuncGreaterThanOrEqualTo: true,
+	function.FuncLessThanOrEqualTo:    true,
+	function.FuncBetween:              true,
+	function.FuncIn:                   true,
+	function.FuncOverlap:              true,
+}
+
+// Variables returns the unique variables within Expression in the order of first occurrence.
+// Unlike Properties, a symbol passed to function is regarded as variable even if it shares the name with a function,
+// unless the function declares the param as type of func
+func (e Expression) Variables() []Variable {
+	var vs []*Variable
+	index := make(map[string]*Variable)
+	var walk func(exp sexp, arg bool)
+	walk = func(exp sexp, arg bool) {
+		switch v := exp.i.(type) {
+		case varString:
+			name := string(v)
+			if _, err := function.Get(name); err == nil && !arg {
+				return
+			}
+			variable, ok := index[name]
+			if !ok {
+				variable = &Variable{Name: name}
+				index[name] = variable
+				vs = append(vs, variable)
+			}
+			variable.Positions = append(variable.Positions, e.position(exp.pos))
+		case list:
+			name, isCall := exp.function()
+			if !isCall {
+				for i, elem := range v {
+					walk(elem, i > 0)
+				}
+				return
+			}
+			for i, arg := range v[1:] {
+				if _, ok := arg.i.(varString); ok && funcParam(name, i) {
+					continue
+				}
+				walk(arg, true)
+				variable, ok := arg.variableOf(index)
+				if !ok {
+					continue
+				}
+				variable.addFunc(canonicalName(name))
+				if !comparisons[canonicalName(name)] {
+					continue
+				}
+				for j, other := range v[1:] {
+					if j != i && other.isConstant() {
+						variable.addValues(other.value())
+					}
+				}
+			}
+		}
+	}
+	walk(e.exp, false)
+
+	res := make([]Variable, len(vs))
+	for i, v := range vs {
+		res[i] = *v
+	}
+	return res
+}
+
+// variableOf returns the Variable if exp is a variable
+func (exp sexp) variableOf(index map[string]*Variable) (*Variable, bool) {
+	v, ok := exp.i.(varString)
+	if !ok {
+		return nil, false
+	}
+	variable, ok := index[string(v)]
+	return variable, ok
+}
+
+// funcParam returns whether the function declares the ith param as type of func
+func funcParam(name string, i int) bool {
+	spec, _ := function.Describe(name)
+	return spec.HasSignature() && paramType(spec.Signature, i) == function.FuncType
+}
+
+// paramType returns the type of the ith param within the signature
+func paramType(sig function.Signature, i int) function.Type {
+	if i < len(sig.Params) {
+		return sig.Params[i]
+	}
+	if sig.Variadic && len(sig.Params) > 0 {
+		return sig.Params[len(sig.Params)-1]
+	}
+	return ""
+}
+
+func (v *Variable) addFunc(name string) {
+	for _, f := range v.Funcs {
+		if f == name {
+			return
+		}
+	}
+	v.Funcs = append(v.Funcs, name)
+}
+
+// addValues adds the value, or the elements if the value is list
+func (v *Variable) addValues(value interface{}) {
+	if l, ok := value.([]interface{}); ok {
+		for _, e := range l {
+			if _, ok := e.([]interface{}); !ok {
+				v.addValues(e)
+			}
+		}
+		return
+	}
+	if value == nil || !reflect.TypeOf(value).Comparable() {
+		return
+	}
+	for _, e := range v.Values {
+		if e == value {
+			return
+		}
+	}
+	v.Values = append(v.Values, value)
+}
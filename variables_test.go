@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nullne/evaluator/function"
+)
+
+func TestVariables(t *testing.T) {
+	type input struct {
+		expr string
+		res  []Variable
+	}
+	pos := func(offsets ...int) []Position {
+		ps := make([]Position, len(offsets))
+		for i, o := range offsets {
+			ps[i] = Position{Offset: o, Line: 1, Column: o + 1}
+		}
+		return ps
+	}
+	inputs := []input{
+		{
+			expr: `(and (and (eq os "android") (ge app_version (t_version "4.0.6"))) (or (ne os "android") (in os ("ios" "web"))) (eq language "zh-Hans"))`,
+			res: []Variable{
+				{Name: "os", Positions: pos(14, 74, 92), Funcs: []string{"eq", "ne", "in"}, Values: []interface{}{"android", "ios", "web"}},
+				{Name: "app_version", Positions: pos(32), Funcs: []string{"ge"}, Values: []interface{}{4.00000006e+20}},
+				{Name: "language", Positions: pos(115), Funcs: []string{"eq"}, Values: []interface{}{"zh-Hans"}},
+			},
+		},
+		{
+			expr: `(& (between years 18 80) (> years 20) (overlap region (2890 3780)))`,
+			res: []Variable{
+				{Name: "years", Positions: pos(12, 28), Funcs: []string{"between", "gt"}, Values: []interface{}{18.0, 80.0, 20.0}},
+				{Name: "region", Positions: pos(47), Funcs: []string{"overlap"}, Values: []interface{}{2890.0, 3780.0}},
+			},
+		},
+		{
+			expr: `(eq (mod years 7) base)`,
+			res: []Variable{
+				{Name: "years", Positions: pos(9), Funcs: []string{"mod"}},
+				{Name: "base", Positions: pos(18), Funcs: []string{"eq"}},
+			},
+		},
+		{
+			expr: `(eq 1 1)`,
+			res:  nil,
+		},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := e.Variables()
+		if len(res) != len(input.res) {
+			t.Errorf("expression `%s` wanna: %+v, got: %+v", input.expr, input.res, res)
+			continue
+		}
+		for i := range res {
+			if !reflect.DeepEqual(res[i], input.res[i]) {
+				t.Errorf("expression `%s` wanna: %+v, got: %+v", input.expr, input.res[i], res[i])
+			}
+		}
+	}
+}
+
+func TestVariablesSharingFunctionName(t *testing.T) {
+	if err := function.Regist("test_level", func(params ...interface{}) (interface{}, error) {
+		return 1.0, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(`(and (eq (test_level) 1) (between test_level 18 80))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := e.Variables()
+	if len(vs) != 1 || vs[0].Name != "test_level" || len(vs[0].Positions) != 1 || vs[0].Positions[0].Offset != 34 {
+		t.Errorf("wanna variable test_level at 34, got: %+v", vs)
+	}
+	if props := e.Properties(); !reflect.DeepEqual(props, []string{"test_level"}) {
+		t.Errorf("wanna properties: [test_level], got: %v", props)
+	}
+	r, err := e.EvalBool(MapParams{"test_level": 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r {
+		t.Errorf("wanna: %v, got: %v", true, r)
+	}
+	// the function is passed to between if params miss it, and params are looked up only once
+	params := &countingParams{MapParams: MapParams{}, count: make(map[string]int)}
+	if _, err := e.EvalBool(params); err == nil {
+		t.Errorf("wanna error of comparing the function")
+	}
+	if params.count["test_level"] != 1 {
+		t.Errorf("wanna test_level got once, got: %d", params.count["test_level"])
+	}
+}
+
+func TestVariablesNamedAfterBuiltins(t *testing.T) {
+	type input struct {
+		expr   string
+		props  []string
+		params MapParams
+	}
+	inputs := []input{
+		{`(and (eq map 1) (has_key labels "env"))`, []string{"map", "labels"}, MapParams{"map": 1, "labels": map[string]string{"env": "prod"}}},
+		{`(eq (get get "a") 1)`, []string{"get"}, MapParams{"get": map[string]int{"a": 1}}},
+		{`(in keys ("a" "b"))`, []string{"keys"}, MapParams{"keys": "a"}},
+		{`(and (eq values 2) (gt merge 1))`, []string{"values", "merge"}, MapParams{"values": 2, "merge": 3}},
+		{`(eq set 1)`, []string{"set"}, MapParams{"set": 1}},
+		{`(and (overlap union ("a")) (not (in "b" difference)))`, []string{"union", "difference"}, MapParams{"union": []string{"a"}, "difference": []string{"c"}}},
+		{`(subset (intersect tags intersect) ("a" "b"))`, []string{"tags", "intersect"}, MapParams{"tags": []string{"a", "c"}, "intersect": []string{"a"}}},
+	}
+	for _, input := range inputs {
+		e, err := New(input.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if props := e.Properties(); !reflect.DeepEqual(props, input.props) {
+			t.Errorf("expression `%s` wanna properties: %v, got: %v", input.expr, input.props, props)
+		}
+		var names []string
+		for _, v := range e.Variables() {
+			names = append(names, v.Name)
+		}
+		if !reflect.DeepEqual(names, input.props) {
+			t.Errorf("expression `%s` wanna variables: %v, got: %v", input.expr, input.props, names)
+		}
+		if r, err := e.EvalBool(input.params); err != nil || !r {
+			t.Errorf("expression `%s` wanna: true, got: %v, %v", input.expr, r, err)
+		}
+	}
+}